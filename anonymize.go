@@ -0,0 +1,165 @@
+package littleorm
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 每批处理的默认行数，可以用`Limit`修改
+const defaultAnonymizeBatch = 500
+
+// 匿名化函数，`key`是这一行的主键，`value`是原始值，返回替换以后的值
+// `NULL`不会调用匿名化函数，保持`NULL`
+type AnonymizeFunc func(key, value string) string
+
+// 把表中指定字段的数据匿名化，一般是从生产环境同步数据到测试环境以后清洗敏感数据
+// 按主键`id`分批处理，每批在一个事务中更新，批大小用`Limit`指定，默认500，可以用`Where`限定范围
+// 返回更新的行数，出错时前面已经提交的批次不会回滚，重新执行会再处理一遍
+func (ctx *Context) Anonymize(rules map[string]AnonymizeFunc) (rowsAffected int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	var (
+		db        = ctx.db
+		table     = ctx.name
+		wheres    = append([]string(nil), ctx.wheres...)
+		whereArgs = append([]interface{}(nil), ctx.whereArgs...)
+		batch     = ctx.limit
+		parent    = ctx.parent
+	)
+	err = ctx.err
+	ctx.release()
+	if err != nil {
+		return
+	}
+	if db.IsReadOnly() {
+		return 0, ErrReadOnly
+	}
+	if batch <= 0 {
+		batch = defaultAnonymizeBatch
+	}
+
+	columns := make([]string, 0, len(rules))
+	for column := range rules {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	query := fmt.Sprintf("select id, %s from %s %s order by id limit %d",
+		sqljoin(columns, SeqComma), table, sqlwhere(append(wheres, "id > ?"), Grouping), batch)
+
+	if parent == nil {
+		parent = context.Background()
+	}
+	var last interface{} = 0
+	for {
+		var n int
+		n, last, err = db.anonymizeBatch(parent, table, query, append(whereArgs, last), columns, rules)
+		rowsAffected += int64(n)
+		if err != nil || n < int(batch) {
+			return
+		}
+	}
+}
+
+// 处理一批数据，返回这一批的行数和最后一行的主键
+func (db *DB) anonymizeBatch(parent context.Context, table, query string, args []interface{}, columns []string, rules map[string]AnonymizeFunc) (n int, last interface{}, err error) {
+	db.logDebug("littleorm anonymize sql", sqlFields(query, args)...)
+	ttx, cancel := context.WithTimeout(parent, db.timeout)
+	defer cancel()
+	rows, err := db.bound(db.Pool()).QueryContext(ttx, query, args...)
+	if err != nil {
+		return
+	}
+	var (
+		keys   []string
+		values [][]sql.NullString
+	)
+	for rows.Next() {
+		var key string
+		row := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, 0, len(columns)+1)
+		dest = append(dest, &key)
+		for i := range row {
+			dest = append(dest, &row[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			rows.Close()
+			return
+		}
+		keys = append(keys, key)
+		values = append(values, row)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(keys) == 0 {
+		return
+	}
+
+	tx, err := db.Pool().BeginTxx(parent, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+	sets := make([]string, len(columns))
+	for i, column := range columns {
+		sets[i] = column + "=" + ParamMarker
+	}
+	update := fmt.Sprintf("update %s set %s where id=%s", table, sqljoin(sets, SeqComma), ParamMarker)
+	for i, key := range keys {
+		params := make([]interface{}, 0, len(columns)+1)
+		for j, column := range columns {
+			if values[i][j].Valid {
+				params = append(params, rules[column](key, values[i][j].String))
+			} else {
+				params = append(params, nil)
+			}
+		}
+		if _, err = db.AcquireTx(tx).WithContext(parent).Exec(update, append(params, key)...); err != nil {
+			return
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	return len(keys), keys[len(keys)-1], nil
+}
+
+// 哈希替换，相同的值哈希以后也相同，关联关系不会被破坏，`salt`避免被彩虹表反查
+func AnonHash(salt string) AnonymizeFunc {
+	return func(key, value string) string {
+		sum := sha256.Sum256([]byte(salt + value))
+		return hex.EncodeToString(sum[:8])
+	}
+}
+
+// 按主键生成名字，eg: AnonName("user") => user_1
+func AnonName(prefix string) AnonymizeFunc {
+	return func(key, value string) string {
+		return prefix + "_" + key
+	}
+}
+
+// 按主键生成邮箱，eg: AnonEmail("example.com") => user_1@example.com
+func AnonEmail(domain string) AnonymizeFunc {
+	return func(key, value string) string {
+		return "user_" + key + "@" + strings.TrimPrefix(domain, "@")
+	}
+}
+
+// 替换成固定值
+func AnonFixed(fixed string) AnonymizeFunc {
+	return func(key, value string) string {
+		return fixed
+	}
+}
+
+// 使用脱敏函数，eg: AnonMask(MaskPhone)
+func AnonMask(fn MaskFunc) AnonymizeFunc {
+	return func(key, value string) string {
+		return fn(value)
+	}
+}
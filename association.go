@@ -0,0 +1,348 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrUnknownRelation = errors.New("littleorm: unknown relation")
+
+// 多对多关联，两张表通过中间表关联，eg: 文章和标签
+type ManyToMany struct {
+	Field      string //`Preload`保存关联数据的字段名，类型是结构体数组或者结构体指针数组，eg: Tags
+	Pivot      string //中间表，eg: post_tag
+	ForeignKey string //中间表中指向父模型的字段，eg: post_id
+	RelatedKey string //中间表中指向关联表的字段，eg: tag_id
+	Related    string //关联表，eg: tag
+	ParentPK   string //父模型主键的`db`标签，默认`id`
+	RelatedPK  string //关联表的主键，默认`id`
+	Order      string //预加载时的排序，可以用中间表的字段，eg: post_tag.position
+}
+
+// 给模型注册多对多关联，和其他注册一样在启动时调用
+// eg: littleorm.RegisterManyToMany(Post{}, "tags", littleorm.ManyToMany{Field: "Tags", Pivot: "post_tag", ForeignKey: "post_id", RelatedKey: "tag_id", Related: "tag"})
+func RegisterManyToMany(value interface{}, relation string, r ManyToMany) {
+	t := structType(value)
+	if t == nil {
+		panic(fmt.Sprintf("littleorm: RegisterManyToMany must be registered on a struct, got %T", value))
+	}
+	if r.ParentPK == "" {
+		r.ParentPK = "id"
+	}
+	if r.RelatedPK == "" {
+		r.RelatedPK = "id"
+	}
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	m := *lookupModel(t)
+	if _, ok := m.index[r.ParentPK]; !ok {
+		panic(fmt.Sprintf("littleorm: primary key %q is not a field of %s", r.ParentPK, t))
+	}
+	if field, ok := t.FieldByName(r.Field); r.Field != "" && (!ok || field.Type.Kind() != reflect.Slice) {
+		panic(fmt.Sprintf("littleorm: relation field %q of %s must be a slice", r.Field, t))
+	}
+	relations := make(map[string]*ManyToMany, len(m.relations)+1)
+	for k, v := range m.relations {
+		relations[k] = v
+	}
+	relations[relation] = &r
+	m.relations = relations
+	m.countColumn(relation)
+	models.Store(t, &m)
+}
+
+// 一对多关联，eg: 用户和订单
+type HasMany struct {
+	Table      string //关联表，eg: orders
+	ForeignKey string //关联表中指向父模型的字段，eg: user_id
+	ParentPK   string //父模型的主键，默认`id`
+}
+
+// 给模型注册一对多关联，和其他注册一样在启动时调用
+// eg: littleorm.RegisterHasMany(User{}, "orders", littleorm.HasMany{Table: "orders", ForeignKey: "user_id"})
+func RegisterHasMany(value interface{}, relation string, r HasMany) {
+	t := structType(value)
+	if t == nil {
+		panic(fmt.Sprintf("littleorm: RegisterHasMany must be registered on a struct, got %T", value))
+	}
+	if r.ParentPK == "" {
+		r.ParentPK = "id"
+	}
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	m := *lookupModel(t)
+	hasMany := make(map[string]*HasMany, len(m.hasMany)+1)
+	for k, v := range m.hasMany {
+		hasMany[k] = v
+	}
+	hasMany[relation] = &r
+	m.hasMany = hasMany
+	m.countColumn(relation)
+	models.Store(t, &m)
+}
+
+// 模型中有`关联名_count`字段的话，默认查询`0`，用`WithCount`时才统计，字段是只读的
+func (m *model) countColumn(relation string) {
+	if _, ok := m.index[relation+"_count"]; ok {
+		m.setComputed(relation+"_count", "0")
+	}
+}
+
+// 统计关联数据的条数，保存到`关联名_count`字段中，eg: WithCount("orders") => orders_count
+// 用关联子查询统计，不需要把关联数据都查出来，关联需要先用`RegisterHasMany`或者`RegisterManyToMany`注册
+func (ctx *Context) WithCount(relations ...string) *Context {
+	ctx.counts = append(ctx.counts, relations...)
+	return ctx
+}
+
+// 把`WithCount`的关联替换成子查询，填充到`what`中
+func (ctx *Context) resolveCounts(dest interface{}) error {
+	if len(ctx.counts) == 0 {
+		return nil
+	}
+	m := modelOf(dest)
+	if m == nil {
+		return fmt.Errorf("%w: WithCount needs a struct dest, got %T", ErrUnknownRelation, dest)
+	}
+	what := ctx.what
+	if len(what) == 0 {
+		what = m.selectColumns(m.columns)
+	}
+	what = append([]string(nil), what...)
+	for _, relation := range ctx.counts {
+		var expr string
+		if r, ok := m.hasMany[relation]; ok {
+			expr = fmt.Sprintf("(select count(*) from %s where %s.%s = %s.%s)", r.Table, r.Table, r.ForeignKey, ctx.name, r.ParentPK)
+		} else if r, ok := m.relations[relation]; ok {
+			expr = fmt.Sprintf("(select count(*) from %s where %s.%s = %s.%s)", r.Pivot, r.Pivot, r.ForeignKey, ctx.name, r.ParentPK)
+		} else {
+			return fmt.Errorf("%w: %T has no relation %q", ErrUnknownRelation, dest, relation)
+		}
+		column := relation + "_count"
+		selected := expr + " as " + column
+		replaced := false
+		for i, item := range what {
+			if item == column || strings.HasSuffix(item, " as "+column) {
+				what[i], replaced = selected, true
+			}
+		}
+		if !replaced {
+			what = append(what, selected)
+		}
+	}
+	ctx.what = what
+	return nil
+}
+
+// 获取父模型的关联和主键
+func relationOf(parent interface{}, relation string) (*ManyToMany, interface{}, error) {
+	m := modelOf(parent)
+	if m == nil || m.relations[relation] == nil {
+		return nil, nil, fmt.Errorf("%w: %T has no relation %q", ErrUnknownRelation, parent, relation)
+	}
+	r := m.relations[relation]
+	v := reflect.Indirect(reflect.ValueOf(parent))
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("%w: parent must be a struct, got %T", ErrUnknownRelation, parent)
+	}
+	id, _ := fieldByTag(v, r.ParentPK)
+	return r, id.Interface(), nil
+}
+
+// 关联`ids`，已经关联的会跳过，在事务中执行，`ctx`中有事务的话加入这个事务
+// eg: db.Attach(ctx, &post, "tags", 1, 2, 3)
+func (db *DB) Attach(ctx context.Context, parent interface{}, relation string, ids ...interface{}) error {
+	r, id, err := relationOf(parent, relation)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return db.Transaction(ctx, PropagationRequired, func(ctx context.Context) error {
+		existing, err := db.pivotIDs(ctx, r, id)
+		if err != nil {
+			return err
+		}
+		return db.attach(ctx, r, id, existing, ids)
+	})
+}
+
+// 取消关联`ids`，不传`ids`取消所有关联，返回删除的行数
+func (db *DB) Detach(ctx context.Context, parent interface{}, relation string, ids ...interface{}) (int64, error) {
+	r, id, err := relationOf(parent, relation)
+	if err != nil {
+		return 0, err
+	}
+	q := db.From(ctx).Name(r.Pivot).Where(r.ForeignKey+"=?", id)
+	if len(ids) > 0 {
+		q.WhereIn(r.RelatedKey, ids)
+	}
+	return q.Delete()
+}
+
+// 同步关联，执行以后只关联`ids`，多余的删除，缺少的插入，在事务中执行
+func (db *DB) Sync(ctx context.Context, parent interface{}, relation string, ids ...interface{}) error {
+	r, id, err := relationOf(parent, relation)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(ctx, PropagationRequired, func(ctx context.Context) error {
+		existing, err := db.pivotIDs(ctx, r, id)
+		if err != nil {
+			return err
+		}
+		keep := make(map[string]bool, len(ids))
+		for _, related := range ids {
+			keep[fmt.Sprint(related)] = true
+		}
+		var stale []interface{}
+		for key, related := range existing {
+			if !keep[key] {
+				stale = append(stale, related)
+			}
+		}
+		if len(stale) > 0 {
+			if _, err = db.From(ctx).Name(r.Pivot).Where(r.ForeignKey+"=?", id).WhereIn(r.RelatedKey, stale).Delete(); err != nil {
+				return err
+			}
+		}
+		return db.attach(ctx, r, id, existing, ids)
+	})
+}
+
+// 插入没有关联的`ids`
+func (db *DB) attach(ctx context.Context, r *ManyToMany, id interface{}, existing map[string]interface{}, ids []interface{}) error {
+	var rows [][]interface{}
+	for _, related := range ids {
+		key := fmt.Sprint(related)
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		existing[key] = related
+		rows = append(rows, []interface{}{id, related})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := db.From(ctx).Name(r.Pivot).InsertBatch([]string{r.ForeignKey, r.RelatedKey}, rows...)
+	return err
+}
+
+// 已经关联的`id`，字符串 => 原始值
+func (db *DB) pivotIDs(ctx context.Context, r *ManyToMany, id interface{}) (map[string]interface{}, error) {
+	values, err := Pluck[interface{}](db.From(ctx).Name(r.Pivot).Where(r.ForeignKey+"=?", id), r.RelatedKey)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]interface{}, len(values))
+	for _, value := range values {
+		existing[fmt.Sprint(asString(value))] = value
+	}
+	return existing, nil
+}
+
+// 通过中间表预加载关联数据，`dest`是已经查询出来的父模型，eg: &post, &[]Post, &[]*Post
+// 一次查询加载所有父模型的关联，按`ManyToMany.Order`排序，结果保存到`ManyToMany.Field`中
+// 关联表配置了`DB.Mask`的话加载的数据总是脱敏，需要原始数据的话自己查询并指定特权角色
+func (db *DB) Preload(ctx context.Context, dest interface{}, relation string) error {
+	m := modelOf(dest)
+	if m == nil || m.relations[relation] == nil {
+		return fmt.Errorf("%w: %T has no relation %q", ErrUnknownRelation, dest, relation)
+	}
+	r := m.relations[relation]
+	if r.Field == "" {
+		return fmt.Errorf("%w: relation %q has no field to preload into", ErrUnknownRelation, relation)
+	}
+
+	var (
+		ids     []interface{}
+		parents = make(map[string][]reflect.Value)
+	)
+	eachStruct(dest, func(v reflect.Value) {
+		field := v.FieldByName(r.Field)
+		field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		id, _ := fieldByTag(v, r.ParentPK)
+		key := fmt.Sprint(id.Interface())
+		if _, ok := parents[key]; !ok {
+			ids = append(ids, id.Interface())
+		}
+		parents[key] = append(parents[key], field)
+	})
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("select %s.*, %s.%s as littleorm_parent from %s join %s on %s.%s = %s.%s where %s.%s in (?)",
+		r.Related, r.Pivot, r.ForeignKey, r.Related, r.Pivot, r.Pivot, r.RelatedKey, r.Related, r.RelatedPK, r.Pivot, r.ForeignKey)
+	if r.Order != "" {
+		query += " order by " + r.Order
+	}
+	query, args, err := sqlx.In(query, ids)
+	if err != nil {
+		return err
+	}
+	db.logDebug("littleorm preload sql", sqlFields(query, args)...)
+
+	// 有事务用事务，和其他查询一样经过中间件
+	c := db.From(ctx).Name(r.Related)
+	if err = c.inUse(); err != nil {
+		return err
+	}
+	defer c.release()
+	ttx, cancel := c.context()
+	defer cancel()
+	rows, done, err := c.queryRows(ttx, query, args)
+	if err != nil {
+		return err
+	}
+	defer done()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	var (
+		elem       = reflect.TypeOf(dest)
+		values     = make([]interface{}, len(columns))
+		scanners   = make([]interface{}, len(columns))
+		parentKey  = len(columns) - 1
+		traversals [][]int
+	)
+	for i := range values {
+		scanners[i] = &values[i]
+	}
+	for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Slice {
+		elem = elem.Elem()
+	}
+	field, _ := elem.FieldByName(r.Field)
+	itemType := field.Type.Elem()
+	base := itemType
+	if base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	traversals = rows.Mapper.TraversalsByName(base, columns)
+	for rows.Next() {
+		if err = rows.Scan(scanners...); err != nil {
+			return err
+		}
+		item := reflect.New(base)
+		if err = assignColumns(item.Elem(), traversals, columns, values); err != nil {
+			return err
+		}
+		db.bindLazies(item.Interface())
+		if err = runAfterScan(item.Interface()); err != nil {
+			return err
+		}
+		db.mask(r.Related, "", item.Interface())
+		if itemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		for _, field := range parents[fmt.Sprint(asString(values[parentKey]))] {
+			field.Set(reflect.Append(field, item))
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,69 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 分批执行时每批之间的休眠时间，目前用于`DeleteByPKs`
+func (ctx *Context) Interval(d time.Duration) *Context {
+	ctx.interval = d
+	return ctx
+}
+
+// 按主键`id`分批删除，每批最多`batchSize`个，返回累计删除的行数，`Where`的条件会一起带上
+// 适合按应用中的列表大批量清理数据，每批单独执行，中途失败时已经删除的不会回滚，需要的话自己开事务
+// eg: n, err := db.Acquire().Name("op_log").Interval(100 * time.Millisecond).DeleteByPKs(ids, 500)
+func (ctx *Context) DeleteByPKs(keys []interface{}, batchSize int) (rowsAffected int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	var (
+		db        = ctx.db
+		tx        = ctx.tx
+		name      = ctx.name
+		parent    = ctx.parent
+		interval  = ctx.interval
+		wheres    = append([]string(nil), ctx.wheres...)
+		whereArgs = append([]interface{}(nil), ctx.whereArgs...)
+	)
+	err = ctx.err
+	ctx.release()
+	if err != nil {
+		return
+	}
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("littleorm: batch size must be positive, got %d", batchSize)
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	for start := 0; start < len(keys); start += batchSize {
+		if start > 0 && interval > 0 {
+			select {
+			case <-parent.Done():
+				return rowsAffected, parent.Err()
+			case <-time.After(interval):
+			}
+		}
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+		query := fmt.Sprintf("delete from %s %s", name, sqlwhere(append(wheres[:len(wheres):len(wheres)], inWhere("id", len(batch))), Grouping))
+		args := append(whereArgs[:len(whereArgs):len(whereArgs)], batch...)
+		result, err := db.AcquireTx(tx).WithContext(parent).Name(name).Exec(query, args...)
+		if err != nil {
+			return rowsAffected, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return rowsAffected, err
+		}
+		rowsAffected += n
+	}
+	return
+}
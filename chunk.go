@@ -0,0 +1,96 @@
+package littleorm
+
+import (
+	"reflect"
+)
+
+// 需要拆分执行的`in`条件，同一个`Context`只拆分第一个超过上限的`WhereIn`
+type inChunk struct {
+	where  int           //条件在`wheres`中的位置
+	arg    int           //参数在`whereArgs`中的开始位置
+	field  string        //字段
+	values []interface{} //全部的值
+}
+
+// 设置`WhereIn`一次最多带多少个值，超过的话自动拆成多次执行，避免超过`max_allowed_packet`或者执行计划变差，`0`表示不拆分，默认不拆分
+// 查询会把每次的结果合并起来，更新和删除会执行多次并累加影响的行数
+// 多次执行不是原子的，需要的话自己开事务；带了`Order`、`Limit`、`Offset`或者分组的查询合并以后结果不对，不会拆分
+func (db *DB) InChunkSize(size int) *DB {
+	db.inChunkSize = size
+	return db
+}
+
+// 是否可以拆分查询，只有查询多条记录并且合并以后结果不变的时候才拆分
+func (ctx *Context) chunkable(dest interface{}) bool {
+	if ctx.chunk == nil || ctx.sql != "" {
+		return false
+	}
+	if ctx.order != "" || ctx.limit > 0 || ctx.offset > 0 || len(ctx.groups) > 0 || len(ctx.havings) > 0 {
+		return false
+	}
+	t := reflect.TypeOf(dest)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice
+}
+
+// 按拆分以后的值依次修改`in`条件，然后执行`fn`
+func (ctx *Context) eachChunk(fn func() error) error {
+	c, size := ctx.chunk, ctx.db.inChunkSize
+	whereArgs := append([]interface{}(nil), ctx.whereArgs...)
+	head, tail := whereArgs[:c.arg], whereArgs[c.arg+len(c.values):]
+	for start := 0; start < len(c.values); start += size {
+		end := start + size
+		if end > len(c.values) {
+			end = len(c.values)
+		}
+		part := c.values[start:end]
+		ctx.wheres[c.where] = inWhere(c.field, len(part))
+		ctx.whereArgs = append(append(append(ctx.whereArgs[:0], head...), part...), tail...)
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 拆分查询，合并每次查询的结果
+func (ctx *Context) findChunks(dest interface{}, fn selectFunc) error {
+	rows := reflect.ValueOf(dest).Elem()
+	merged := reflect.MakeSlice(rows.Type(), 0, len(ctx.chunk.values))
+	err := ctx.eachChunk(func() error {
+		part := reflect.New(rows.Type())
+		ctx.sql = ""
+		if err := ctx.query(part.Interface(), fn); err != nil {
+			return err
+		}
+		merged = reflect.AppendSlice(merged, part.Elem())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	rows.Set(merged)
+	return nil
+}
+
+// 拆分更新或者删除，`build`根据当前的条件生成语句，返回累计影响的行数
+func (ctx *Context) execChunks(build func() (string, []interface{})) (rowsAffected int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	defer ctx.release()
+	if ctx.err != nil {
+		return 0, ctx.err
+	}
+	err = ctx.eachChunk(func() error {
+		query, args := build()
+		ctx.db.logDebug("littleorm exec sql", sqlFields(query, args)...)
+		result, err := ctx.execute(query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		rowsAffected += n
+		return err
+	})
+	return
+}
@@ -0,0 +1,187 @@
+package littleorm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrCoercion = errors.New("littleorm: cannot coerce column value")
+
+// 整数的转换方式
+type IntMode int
+
+const (
+	IntAsIs     IntMode = iota //驱动返回什么就是什么，mysql的无符号大整数可能是`uint64`
+	IntAsInt64                 //统一成`int64`，超过范围返回`ErrCoercion`
+	IntAsUint64                //统一成`uint64`，负数返回`ErrCoercion`
+)
+
+// `DECIMAL`、`NUMERIC`字段的转换方式
+type DecimalMode int
+
+const (
+	DecimalAsIs      DecimalMode = iota //驱动返回什么就是什么，mysql是`[]byte`，SQLite是`float64`
+	DecimalAsString                     //统一成字符串，不丢精度
+	DecimalAsFloat64                    //统一成`float64`，可能丢精度
+)
+
+// 扫描时的类型转换，只对扫描到`interface{}`的值生效：`map[string]interface{}`的值、结构体中`interface{}`类型的字段、单个`interface{}`
+// 结构体中有具体类型的字段按`database/sql`的规则转换，不受影响
+type Coercion struct {
+	Ints     IntMode
+	Decimals DecimalMode
+	Text     bool //文本字段的`[]byte`转成字符串，mysql驱动的文本字段默认是`[]byte`，二进制字段不转换
+
+	ZeroDates ZeroDateMode //零值日期的处理方式，没有指定时用`DB.ZeroDates`的设置
+}
+
+// 指定本次查询扫描时的类型转换，对`FindMany`、`FindOne`和`FindSeq`有效
+// 设置以后`FindMany`、`FindOne`还可以扫描到`[]map[string]interface{}`和`map[string]interface{}`，不走查询结果缓存
+// eg: db.Acquire().Name("order").Coerce(littleorm.Coercion{Ints: littleorm.IntAsInt64, Decimals: littleorm.DecimalAsString, Text: true}).FindMany(&rows)
+func (ctx *Context) Coerce(c Coercion) *Context {
+	ctx.coerce = &c
+	return ctx
+}
+
+// 按`Coercion`扫描一行，`row`是目标对象的指针，eg: *User, *map[string]interface{}, *int64
+func (c *Coercion) scan(rows *sqlx.Rows, types []*sql.ColumnType, row reflect.Value, unsafe bool) error {
+	var (
+		base     = row.Elem()
+		values   = make([]interface{}, len(types))
+		scanners = make([]interface{}, len(types))
+	)
+	for i, ct := range types {
+		scanners[i] = &coerced{c: c, column: ct, dest: &values[i]}
+	}
+	switch {
+	case base.Kind() == reflect.Map:
+		if err := rows.Scan(scanners...); err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(base.Type(), len(types))
+		for i, ct := range types {
+			m.SetMapIndex(reflect.ValueOf(ct.Name()), reflect.ValueOf(&values[i]).Elem())
+		}
+		base.Set(m)
+		return nil
+	case !scannable(base.Type()):
+		model := lookupModel(base.Type())
+		for i, ct := range types {
+			idx, ok := model.index[ct.Name()]
+			switch {
+			case !ok && unsafe:
+			case !ok:
+				return fmt.Errorf("littleorm: missing destination name %s in %s", ct.Name(), base.Type())
+			case base.Field(idx).Kind() == reflect.Interface:
+				scanners[i] = &coerced{c: c, column: ct, dest: base.Field(idx).Addr().Interface().(*interface{})}
+			case c.ZeroDates != ZeroDateAsIs && isTimeType(base.Field(idx).Type()):
+				scanners[i] = &zeroDate{mode: c.ZeroDates, dest: base.Field(idx)}
+			default:
+				scanners[i] = base.Field(idx).Addr().Interface()
+			}
+		}
+		return rows.Scan(scanners...)
+	case base.Kind() == reflect.Interface && len(types) == 1:
+		scanners[0] = &coerced{c: c, column: types[0], dest: row.Interface().(*interface{})}
+		return rows.Scan(scanners...)
+	case c.ZeroDates != ZeroDateAsIs && isTimeType(base.Type()) && len(types) == 1:
+		return rows.Scan(&zeroDate{mode: c.ZeroDates, dest: base})
+	}
+	return rows.Scan(row.Interface())
+}
+
+// 扫描到`interface{}`时按`Coercion`转换驱动返回的值
+type coerced struct {
+	c      *Coercion
+	column *sql.ColumnType
+	dest   *interface{}
+}
+
+func (s *coerced) Scan(src interface{}) (err error) {
+	name := strings.ToUpper(s.column.DatabaseTypeName())
+	if b, ok := src.([]byte); ok {
+		// 驱动复用的缓冲区，和`sql.RawBytes`一样需要复制
+		src = append([]byte(nil), b...)
+	}
+	if c := s.c; c.ZeroDates != ZeroDateAsIs && isDateType(name) && isZeroDate(src) {
+		src = nil
+		if c.ZeroDates == ZeroDateAsZero {
+			src = time.Time{}
+		}
+	}
+	switch {
+	case src == nil:
+	case isDecimal(name):
+		src, err = s.c.decimal(src)
+	default:
+		src, err = s.c.value(name, src)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrCoercion, s.column.Name(), err)
+	}
+	*s.dest = src
+	return nil
+}
+
+// 转换整数和文本
+func (c *Coercion) value(name string, src interface{}) (interface{}, error) {
+	switch v := src.(type) {
+	case int64:
+		if c.Ints == IntAsUint64 {
+			if v < 0 {
+				return nil, fmt.Errorf("%d is negative", v)
+			}
+			return uint64(v), nil
+		}
+	case uint64:
+		if c.Ints == IntAsInt64 {
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("%d overflows int64", v)
+			}
+			return int64(v), nil
+		}
+	case []byte:
+		if c.Text && !strings.Contains(name, "BLOB") && !strings.Contains(name, "BINARY") {
+			return string(v), nil
+		}
+	}
+	return src, nil
+}
+
+// 转换`DECIMAL`，驱动可能返回文本、浮点数或者整数
+func (c *Coercion) decimal(src interface{}) (interface{}, error) {
+	if c.Decimals == DecimalAsIs {
+		return src, nil
+	}
+	var text string
+	switch v := src.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	case uint64:
+		text = strconv.FormatUint(v, 10)
+	default:
+		return src, nil
+	}
+	if c.Decimals == DecimalAsString {
+		return text, nil
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+func isDecimal(name string) bool {
+	return strings.HasPrefix(name, "DECIMAL") || strings.HasPrefix(name, "NUMERIC")
+}
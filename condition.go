@@ -0,0 +1,126 @@
+package littleorm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// 条件组，用`WhereGroup`拼接带括号的嵌套条件，组内的条件按顺序用`and`或者`or`连接
+// eg: (a=? or b=?) and c=?
+//
+//	db.Acquire().Name("user").WhereGroup(func(g *littleorm.Condition) {
+//		g.Where("a=?", 1).OrWhere("b=?", 2)
+//	}).Where("c=?", 3)
+type Condition struct {
+	conds []condition
+	args  []interface{}
+	err   error
+}
+
+// 添加条件，和前面的条件用`and`连接
+func (c *Condition) Where(where string, args ...interface{}) *Condition {
+	return c.add(where, args, false)
+}
+
+// 添加条件，和前面的条件用`or`连接
+func (c *Condition) OrWhere(where string, args ...interface{}) *Condition {
+	return c.add(where, args, true)
+}
+
+// 添加嵌套的条件组，和前面的条件用`and`连接
+func (c *Condition) Group(fn func(g *Condition)) *Condition {
+	return c.group(fn, false)
+}
+
+// 添加嵌套的条件组，和前面的条件用`or`连接
+func (c *Condition) OrGroup(fn func(g *Condition)) *Condition {
+	return c.group(fn, true)
+}
+
+// 转换成片段，多个条件时每个条件都加上括号
+func (c *Condition) Fragment() Fragment {
+	var buf bytes.Buffer
+	if len(c.conds) != 0 {
+		writeConditions(&buf, c.conds)
+	}
+	return Fragment{SQL: buf.String(), Args: c.args}
+}
+
+func (c *Condition) add(where string, args []interface{}, or bool) *Condition {
+	if n := countPlaceholders(where); n != len(args) && c.err == nil {
+		c.err = fmt.Errorf("%w: where %q has %d placeholders but %d args", ErrPlaceholderMismatch, where, n, len(args))
+	}
+	c.conds = append(c.conds, condition{sql: where, or: or})
+	c.args = append(c.args, args...)
+	return c
+}
+
+func (c *Condition) group(fn func(g *Condition), or bool) *Condition {
+	g := &Condition{}
+	fn(g)
+	if g.err != nil && c.err == nil {
+		c.err = g.err
+	}
+	if f := g.Fragment(); !f.IsEmpty() {
+		c.conds = append(c.conds, condition{sql: f.SQL, or: or})
+		c.args = append(c.args, f.Args...)
+	}
+	return c
+}
+
+// 添加条件，和前面所有的条件用`or`连接，eg: Where("a=?", 1).Where("b=?", 2).OrWhere("c=?", 3) => ((a=?) and (b=?) or (c=?))
+// 整体加上括号，后面再`Where`的条件和整个`or`用`and`连接，需要改变优先级的话用`WhereGroup`
+// `or`以后`in`条件不会再拆分执行，见`DB.InChunkSize`
+func (ctx *Context) OrWhere(where string, args ...interface{}) *Context {
+	ctx.checkPlaceholders("where", where, args)
+	if len(ctx.wheres) == 0 {
+		return ctx.WhereRaw(where, args...)
+	}
+	conds := make([]condition, 0, len(ctx.wheres)+1)
+	for _, w := range ctx.wheres {
+		conds = append(conds, condition{sql: w})
+	}
+	conds = append(conds, condition{sql: where, or: true})
+	var buf bytes.Buffer
+	buf.WriteByte('(')
+	writeConditions(&buf, conds)
+	buf.WriteByte(')')
+	ctx.chunk = nil
+	ctx.wheres = append(ctx.wheres[:0], buf.String())
+	ctx.whereArgs = append(ctx.whereArgs, args...)
+	return ctx
+}
+
+// 添加一组条件，整组加上括号，和前面的条件用`and`连接，空的条件组不添加
+func (ctx *Context) WhereGroup(fn func(g *Condition)) *Context {
+	g := &Condition{}
+	fn(g)
+	if g.err != nil {
+		if ctx.err == nil {
+			ctx.err = g.err
+		}
+		return ctx
+	}
+	f := g.Fragment()
+	if f.IsEmpty() {
+		return ctx
+	}
+	return ctx.WhereRaw("("+f.SQL+")", f.Args...)
+}
+
+// 添加一组条件，和前面所有的条件用`or`连接
+func (ctx *Context) OrWhereGroup(fn func(g *Condition)) *Context {
+	g := &Condition{}
+	fn(g)
+	if g.err != nil {
+		if ctx.err == nil {
+			ctx.err = g.err
+		}
+		return ctx
+	}
+	f := g.Fragment()
+	if f.IsEmpty() {
+		return ctx
+	}
+	return ctx.OrWhere(f.SQL, f.Args...)
+}
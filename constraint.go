@@ -0,0 +1,185 @@
+package littleorm
+
+import (
+	sqldriver "database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 字段约束，`Check`返回`false`表示不满足，除了`NotEmpty`，内置的约束都允许`nil`
+// 内置了`MaxLength`、`Range`、`Pattern`、`NotEmpty`，也可以自己定义
+type Constraint struct {
+	Rule    string                       //约束名，校验失败时放在`FieldError.Rule`中
+	Message string                       //校验失败的说明
+	Check   func(value interface{}) bool //校验写入的值，`driver.Valuer`和指针已经取出了值
+}
+
+// 字段不满足约束
+type FieldError struct {
+	Column  string
+	Rule    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Column + ": " + e.Message
+}
+
+// 所有不满足约束的字段，按字段名排序，用`errors.As`取出来生成接口的错误信息
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	items := make([]string, len(e))
+	for i, fe := range e {
+		items[i] = fe.Error()
+	}
+	return strings.Join(items, "; ")
+}
+
+// 给表`table`的字段`column`声明约束，`Insert`、`InsertStruct`、`UpdateMap`这些写入前检查，不会执行`SQL`
+// 只检查写入了的字段，所有不满足的字段一起返回，返回的错误同时包含`ErrValidation`和`FieldErrors`
+// eg:
+//
+//	db.Constrain("user", "name", littleorm.NotEmpty(), littleorm.MaxLength(20)).
+//		Constrain("user", "age", littleorm.Range(0, 150))
+func (db *DB) Constrain(table, column string, constraints ...Constraint) *DB {
+	db.validateMu.Lock()
+	defer db.validateMu.Unlock()
+	if db.constraints == nil {
+		db.constraints = make(map[string]map[string][]Constraint)
+	}
+	if db.constraints[table] == nil {
+		db.constraints[table] = make(map[string][]Constraint)
+	}
+	db.constraints[table][column] = append(db.constraints[table][column], constraints...)
+	return db
+}
+
+// 字符串最多`n`个字符，按字符数不是字节数算，和`varchar(n)`一样
+func MaxLength(n int) Constraint {
+	return Constraint{
+		Rule:    "max_length",
+		Message: fmt.Sprintf("must be at most %d characters", n),
+		Check: func(value interface{}) bool {
+			s, ok := constraintString(value)
+			return !ok || utf8.RuneCountInString(s) <= n
+		},
+	}
+}
+
+// 数字在`[min, max]`之间，不是数字也不满足
+func Range(min, max float64) Constraint {
+	return Constraint{
+		Rule:    "range",
+		Message: fmt.Sprintf("must be between %v and %v", min, max),
+		Check: func(value interface{}) bool {
+			if value == nil {
+				return true
+			}
+			v := reflect.ValueOf(value)
+			var f float64
+			switch {
+			case v.CanInt():
+				f = float64(v.Int())
+			case v.CanUint():
+				f = float64(v.Uint())
+			case v.CanFloat():
+				f = v.Float()
+			default:
+				return false
+			}
+			return f >= min && f <= max
+		},
+	}
+}
+
+// 字符串匹配正则`expr`，需要整个匹配的话自己加上`^`和`$`，`expr`不合法时`panic`
+func Pattern(expr string) Constraint {
+	re := regexp.MustCompile(expr)
+	return Constraint{
+		Rule:    "pattern",
+		Message: "must match " + expr,
+		Check: func(value interface{}) bool {
+			s, ok := constraintString(value)
+			return value == nil || ok && re.MatchString(s)
+		},
+	}
+}
+
+// 不能是`nil`或者空字符串，只有空白字符也算空
+func NotEmpty() Constraint {
+	return Constraint{
+		Rule:    "not_empty",
+		Message: "must not be empty",
+		Check: func(value interface{}) bool {
+			if value == nil {
+				return false
+			}
+			s, ok := constraintString(value)
+			return !ok || strings.TrimSpace(s) != ""
+		},
+	}
+}
+
+// 字符串和`[]byte`的值
+func constraintString(value interface{}) (string, bool) {
+	switch s := value.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	}
+	return "", false
+}
+
+// 取出写入的值，`driver.Valuer`取`Value`，指针取指向的值，`nil`指针返回`nil`
+func constraintValue(value interface{}) interface{} {
+	if valuer, ok := value.(sqldriver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			value = v
+		}
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// 检查表`table`写入的字段，没有约束或者都满足时返回`nil`
+func (db *DB) checkConstraints(table string, data map[string]interface{}) error {
+	db.validateMu.RLock()
+	columns := db.constraints[table]
+	db.validateMu.RUnlock()
+	if len(columns) == 0 {
+		return nil
+	}
+	var errs FieldErrors
+	for column, constraints := range columns {
+		value, ok := data[column]
+		if !ok {
+			continue
+		}
+		value = constraintValue(value)
+		for _, c := range constraints {
+			if !c.Check(value) {
+				errs = append(errs, FieldError{Column: column, Rule: c.Rule, Message: c.Message})
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Column < errs[j].Column })
+	return errs
+}
@@ -0,0 +1,93 @@
+// `echo`的中间件，把`DB`保存到请求中，处理函数里用`echoorm.From(c)`获取`Context`，可以选择每个请求开一个事务
+//
+//	e := echo.New()
+//	e.Use(echoorm.Middleware(db, echoorm.WithTx()))
+//	e.POST("/user", func(c echo.Context) error {
+//		_, err := echoorm.From(c).Name("user").Insert(data)
+//		return err
+//	})
+package echoorm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lujin123/littleorm"
+)
+
+// 保存`DB`用的`key`
+const dbKey = "littleorm.db"
+
+type options struct {
+	tx     bool
+	commit func(c echo.Context, err error) bool
+}
+
+type Option func(o *options)
+
+// 每个请求开一个事务，处理完以后根据`CommitWhen`决定提交还是回滚
+func WithTx() Option {
+	return func(o *options) {
+		o.tx = true
+	}
+}
+
+// 决定事务是否提交，`err`是处理函数返回的错误，默认没有错误并且响应状态码小于`400`时提交，否则回滚
+func CommitWhen(fn func(c echo.Context, err error) bool) Option {
+	return func(o *options) {
+		o.commit = fn
+	}
+}
+
+// 不满足提交条件时让`Transaction`回滚
+var errRollback = errors.New("echoorm: rollback")
+
+// 默认的提交条件
+func commitOnSuccess(c echo.Context, err error) bool {
+	return err == nil && c.Response().Status < http.StatusBadRequest
+}
+
+// 中间件，开启事务的话，事务保存在请求的`context.Context`中，用`From`获取的`Context`都使用这个事务
+// 处理函数没有返回错误时事务提交失败会作为错误返回
+// 事务由`DB.Transaction`管理，`DB.AfterCommit`注册的回调(比如实体缓存的清理)在提交以后执行
+func Middleware(db *littleorm.DB, opts ...Option) echo.MiddlewareFunc {
+	o := options{commit: commitOnSuccess}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(dbKey, db)
+			if !o.tx {
+				return next(c)
+			}
+			var handlerErr error
+			err := db.Transaction(c.Request().Context(), littleorm.PropagationRequiresNew, func(ctx context.Context) error {
+				c.SetRequest(c.Request().WithContext(ctx))
+				handlerErr = next(c)
+				if !o.commit(c, handlerErr) {
+					return errRollback
+				}
+				return nil
+			})
+			if err == nil || err == errRollback {
+				return handlerErr
+			}
+			return err
+		}
+	}
+}
+
+// 获取请求的`DB`，没有使用中间件返回`nil`
+func DB(c echo.Context) *littleorm.DB {
+	db, _ := c.Get(dbKey).(*littleorm.DB)
+	return db
+}
+
+// 获取使用请求的`context.Context`的`Context`，有事务的话使用事务，客户端断开连接以后查询会被取消
+func From(c echo.Context) *littleorm.Context {
+	ctx := c.Request().Context()
+	return DB(c).From(ctx).WithContext(ctx)
+}
@@ -0,0 +1,44 @@
+package echoorm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lujin123/littleorm/littleormtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	db := littleormtest.Open(t, "create table user (id integer primary key autoincrement, name varchar(32))")
+	e := echo.New()
+	e.Use(Middleware(db, WithTx()))
+	var committed []string
+	e.POST("/ok", func(c echo.Context) error {
+		if _, err := From(c).Name("user").Insert(map[string]interface{}{"name": "ok"}); err != nil {
+			return err
+		}
+		DB(c).AfterCommit(c.Request().Context(), func() { committed = append(committed, "ok") })
+		assert.Equal(t, 0, len(committed))
+		return c.NoContent(http.StatusOK)
+	})
+	e.POST("/fail", func(c echo.Context) error {
+		if _, err := From(c).Name("user").Insert(map[string]interface{}{"name": "fail"}); err != nil {
+			return err
+		}
+		DB(c).AfterCommit(c.Request().Context(), func() { committed = append(committed, "fail") })
+		return echo.NewHTTPError(http.StatusBadRequest, "bad request")
+	})
+
+	for _, path := range []string{"/ok", "/fail"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	}
+	var names []string
+	err := db.Acquire().Select(&names, "select name from user")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"ok"}, names)
+	// 提交以后才执行回调，回滚的不执行
+	assert.Equal(t, []string{"ok"}, committed)
+}
@@ -0,0 +1,94 @@
+// `gin`的中间件，把`DB`保存到请求中，处理函数里用`ginorm.From(c)`获取`Context`，可以选择每个请求开一个事务
+//
+//	r := gin.New()
+//	r.Use(ginorm.Middleware(db, ginorm.WithTx()))
+//	r.POST("/user", func(c *gin.Context) {
+//		_, err := ginorm.From(c).Name("user").Insert(data)
+//	})
+package ginorm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lujin123/littleorm"
+)
+
+// 保存`DB`用的`key`
+const dbKey = "littleorm.db"
+
+type options struct {
+	tx     bool
+	commit func(c *gin.Context) bool
+}
+
+type Option func(o *options)
+
+// 每个请求开一个事务，处理完以后根据`CommitWhen`决定提交还是回滚
+func WithTx() Option {
+	return func(o *options) {
+		o.tx = true
+	}
+}
+
+// 决定事务是否提交，默认响应状态码小于`400`并且没有`c.Error`时提交，否则回滚
+func CommitWhen(fn func(c *gin.Context) bool) Option {
+	return func(o *options) {
+		o.commit = fn
+	}
+}
+
+// 不满足提交条件时让`Transaction`回滚
+var errRollback = errors.New("ginorm: rollback")
+
+// 默认的提交条件
+func commitOnSuccess(c *gin.Context) bool {
+	return c.Writer.Status() < http.StatusBadRequest && len(c.Errors) == 0
+}
+
+// 中间件，开启事务的话，事务保存在请求的`context.Context`中，用`From`获取的`Context`都使用这个事务
+// 事务在处理函数返回以后才提交，这时候响应可能已经发出去了，提交失败只会记录到`c.Errors`，需要把提交失败返回给客户端的用`DB.Transaction`
+// 事务由`DB.Transaction`管理，`DB.AfterCommit`注册的回调(比如实体缓存的清理)在提交以后执行
+func Middleware(db *littleorm.DB, opts ...Option) gin.HandlerFunc {
+	o := options{commit: commitOnSuccess}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(c *gin.Context) {
+		c.Set(dbKey, db)
+		if !o.tx {
+			c.Next()
+			return
+		}
+		started := false
+		err := db.Transaction(c.Request.Context(), littleorm.PropagationRequiresNew, func(ctx context.Context) error {
+			started = true
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			if !o.commit(c) {
+				return errRollback
+			}
+			return nil
+		})
+		switch {
+		case err == nil || err == errRollback:
+		case !started:
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+		default:
+			_ = c.Error(err)
+		}
+	}
+}
+
+// 获取请求的`DB`，没有使用中间件会`panic`
+func DB(c *gin.Context) *littleorm.DB {
+	return c.MustGet(dbKey).(*littleorm.DB)
+}
+
+// 获取使用请求的`context.Context`的`Context`，有事务的话使用事务，客户端断开连接以后查询会被取消
+func From(c *gin.Context) *littleorm.Context {
+	ctx := c.Request.Context()
+	return DB(c).From(ctx).WithContext(ctx)
+}
@@ -0,0 +1,44 @@
+package ginorm
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lujin123/littleorm/littleormtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := littleormtest.Open(t, "create table user (id integer primary key autoincrement, name varchar(32))")
+	r := gin.New()
+	r.Use(Middleware(db, WithTx()))
+	var committed []string
+	r.POST("/ok", func(c *gin.Context) {
+		_, err := From(c).Name("user").Insert(map[string]interface{}{"name": "ok"})
+		assert.Equal(t, nil, err)
+		DB(c).AfterCommit(c.Request.Context(), func() { committed = append(committed, "ok") })
+		assert.Equal(t, 0, len(committed))
+		c.Status(http.StatusOK)
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		_, err := From(c).Name("user").Insert(map[string]interface{}{"name": "fail"})
+		assert.Equal(t, nil, err)
+		DB(c).AfterCommit(c.Request.Context(), func() { committed = append(committed, "fail") })
+		_ = c.AbortWithError(http.StatusBadRequest, errors.New("bad request"))
+	})
+
+	for _, path := range []string{"/ok", "/fail"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+	}
+	var names []string
+	err := db.Acquire().Select(&names, "select name from user")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"ok"}, names)
+	// 提交以后才执行回调，回滚的不执行
+	assert.Equal(t, []string{"ok"}, committed)
+}
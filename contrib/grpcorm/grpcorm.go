@@ -0,0 +1,126 @@
+// `gRPC`的拦截器，把`DB`和接口名标签保存到请求的`context.Context`中，处理函数里用`grpcorm.FromContext(ctx)`获取`Context`
+// 查询使用请求的`context.Context`，客户端设置的超时会一直传到数据库，还可以选择每次调用开一个事务
+//
+//	s := grpc.NewServer(
+//		grpc.UnaryInterceptor(grpcorm.UnaryServerInterceptor(db, grpcorm.WithTx(nil))),
+//		grpc.StreamInterceptor(grpcorm.StreamServerInterceptor(db)),
+//	)
+//
+//	func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+//		err := grpcorm.FromContext(ctx).Name("user").Where("id=?", req.Id).FindOne(&user)
+//	}
+package grpcorm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lujin123/littleorm"
+	"google.golang.org/grpc"
+)
+
+// 接口名的标签
+const (
+	TagService = "grpc.service"
+	TagMethod  = "grpc.method"
+)
+
+// `context.Context`中保存`DB`的`key`
+type dbKey struct{}
+
+type options struct {
+	tx func(fullMethod string) bool
+}
+
+type Option func(o *options)
+
+// 每次调用开一个事务，处理函数返回错误或者`panic`时回滚，否则提交
+// `filter`决定哪些接口开事务，参数是完整的接口名，eg: /pkg.Service/Method，`nil`表示全部接口都开
+func WithTx(filter func(fullMethod string) bool) Option {
+	return func(o *options) {
+		if filter == nil {
+			filter = func(string) bool { return true }
+		}
+		o.tx = filter
+	}
+}
+
+// 一元调用的拦截器
+func UnaryServerInterceptor(db *littleorm.DB, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx = scope(ctx, db, info.FullMethod)
+		if o.tx == nil || !o.tx(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		err = db.Transaction(ctx, littleorm.PropagationRequired, func(ctx context.Context) error {
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// 流式调用的拦截器，开启事务的话整个流在一个事务中
+func StreamServerInterceptor(db *littleorm.DB, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := scope(ss.Context(), db, info.FullMethod)
+		if o.tx == nil || !o.tx(info.FullMethod) {
+			return handler(srv, &scopedStream{ServerStream: ss, ctx: ctx})
+		}
+		return db.Transaction(ctx, littleorm.PropagationRequired, func(ctx context.Context) error {
+			return handler(srv, &scopedStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// 获取请求的`DB`，没有使用拦截器返回`nil`
+func DB(ctx context.Context) *littleorm.DB {
+	db, _ := ctx.Value(dbKey{}).(*littleorm.DB)
+	return db
+}
+
+// 获取使用请求的`context.Context`的`Context`，有事务的话使用事务
+// 没有使用拦截器会`panic`，不确定的话先用`DB`判断
+func FromContext(ctx context.Context) *littleorm.Context {
+	db := DB(ctx)
+	if db == nil {
+		panic("grpcorm: no DB in context, install UnaryServerInterceptor or StreamServerInterceptor")
+	}
+	return db.From(ctx).WithContext(ctx)
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// 把`DB`和接口名标签保存到`ctx`中
+func scope(ctx context.Context, db *littleorm.DB, fullMethod string) context.Context {
+	service, method := splitMethod(fullMethod)
+	ctx = littleorm.WithTags(ctx, map[string]string{TagService: service, TagMethod: method})
+	return context.WithValue(ctx, dbKey{}, db)
+}
+
+// 拆分完整的接口名，eg: /pkg.Service/Method => pkg.Service, Method
+func splitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+// 替换了`context.Context`的流
+type scopedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *scopedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,46 @@
+package grpcorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lujin123/littleorm"
+	"github.com/lujin123/littleorm/littleormtest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	db := littleormtest.Open(t, "create table user (id integer primary key autoincrement, name varchar(32))")
+	interceptor := UnaryServerInterceptor(db, WithTx(nil))
+	insert := func(name string, fail error) grpc.UnaryHandler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			assert.Equal(t, "Create", littleorm.TagsFromContext(ctx)[TagMethod])
+			assert.NotEqual(t, nil, db.TxFromContext(ctx))
+			_, err := FromContext(ctx).Name("user").Insert(map[string]interface{}{"name": name})
+			assert.Equal(t, nil, err)
+			return nil, fail
+		}
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Create"}
+	_, err := interceptor(context.Background(), nil, info, insert("ok", nil))
+	assert.Equal(t, nil, err)
+	_, err = interceptor(context.Background(), nil, info, insert("fail", errors.New("failed")))
+	assert.NotEqual(t, nil, err)
+
+	var names []string
+	err = db.Acquire().Select(&names, "select name from user")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"ok"}, names)
+
+	// 没有拦截器
+	assert.Equal(t, (*littleorm.DB)(nil), DB(context.Background()))
+	assert.Panics(t, func() { FromContext(context.Background()) })
+}
+
+func TestSplitMethod(t *testing.T) {
+	service, method := splitMethod("/user.UserService/Create")
+	assert.Equal(t, "user.UserService", service)
+	assert.Equal(t, "Create", method)
+}
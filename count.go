@@ -0,0 +1,102 @@
+package littleorm
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+var ErrCountStrategy = errors.New("littleorm: count strategy not applicable")
+
+// 统计条数的方式，大表上精确的`count(*)`很慢，分页接口显示总数时可以用估算值
+type CountStrategy int
+
+const (
+	CountExact    CountStrategy = iota //精确统计，`count(*)`
+	CountEstimate                      //`EXPLAIN`估算的行数，sqlite3和有`Group`的查询会退化成精确统计
+	CountMaxID                         //用最大的主键`id`估算，只适合没有条件、很少删除的表，有`Where`条件时返回`ErrCountStrategy`
+)
+
+// 按指定的方式统计条数，会忽略`Order`、`Limit`和`Offset`
+// eg: n, err := littleorm.CountWith[int64](db.Acquire().Name("log"), littleorm.CountEstimate)
+func CountWith[T ~int64 | ~int](ctx *Context, strategy CountStrategy) (T, error) {
+	switch strategy {
+	case CountEstimate:
+		if ctx.db.dialect.Name() != "sqlite3" && len(ctx.groups) == 0 {
+			n, err := ctx.estimateCount()
+			return T(n), err
+		}
+	case CountMaxID:
+		if err := ctx.inUse(); err != nil {
+			return 0, err
+		}
+		if len(ctx.wheres) != 0 || len(ctx.groups) != 0 {
+			ctx.release()
+			return 0, ErrCountStrategy
+		}
+		var n T
+		ctx.Order("")
+		ctx.limit, ctx.offset = 0, 0
+		err := ctx.What([]string{"coalesce(max(id), 0)"}).FindOne(&n)
+		return n, err
+	}
+	return Count[T](ctx)
+}
+
+// 精确统计条数，和`Count[int64]`一样，可以直接接在链式调用后面
+// eg: n, err := db.Acquire().Name("user").Where("age>?", 18).Count()
+func (ctx *Context) Count() (int64, error) {
+	return Count[int64](ctx)
+}
+
+// `EXPLAIN`输出中的行数，postgres: `Seq Scan on t  (cost=0.00..35.50 rows=2550 width=4)`
+var explainRows = regexp.MustCompile(`rows=(\d+)`)
+
+// 用`EXPLAIN`估算查询的行数
+func (ctx *Context) estimateCount() (n int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	defer ctx.release()
+	if ctx.err != nil {
+		return 0, ctx.err
+	}
+	ctx.Order("")
+	ctx.limit, ctx.offset = 0, 0
+	ctx.What([]string{"*"})
+	ctx.args = ctx.selectArgs()
+	query := "explain " + ctx.sqlselect(nil)
+
+	ttx, cancel := ctx.context()
+	defer cancel()
+	rows, done, err := ctx.queryRows(ttx, query, ctx.args)
+	if err != nil {
+		return
+	}
+	defer done()
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	if ctx.db.dialect.Name() == "postgres" {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			return
+		}
+		if match := explainRows.FindStringSubmatch(line); match != nil {
+			n, err = strconv.ParseInt(match[1], 10, 64)
+		}
+		return
+	}
+	// mysql的估算行数还要乘以条件过滤的比例
+	plan := make(map[string]interface{})
+	if err = rows.MapScan(plan); err != nil {
+		return
+	}
+	if n, err = strconv.ParseInt(explainString(plan["rows"]), 10, 64); err != nil {
+		return
+	}
+	if filtered, ferr := strconv.ParseFloat(explainString(plan["filtered"]), 64); ferr == nil {
+		n = int64(float64(n) * filtered / 100)
+	}
+	return n, nil
+}
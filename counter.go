@@ -0,0 +1,202 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 计数缓存规则，`Parent`表的`Counter`字段保存`Child`表中`ForeignKey = ParentKey`的行数
+// eg: 用户表保存订单数，CounterRule{Parent: "user", Counter: "order_count", Child: "orders", ForeignKey: "user_id"}
+type CounterRule struct {
+	Parent     string
+	ParentKey  string //父表的主键，默认`id`
+	Counter    string
+	Child      string
+	ForeignKey string
+}
+
+// 注册计数缓存规则，用`Insert`、`InsertBatch`插入子表以后父表的计数加一，用`Delete`删除以后减一，代替触发器
+// 计数的更新和插入、删除在同一个事务中，没有事务的话会开一个事务
+// 插入时必须带上外键字段才会计数，直接`Exec`的`SQL`不会计数，可以用`RecountCounter`重新统计
+func (db *DB) CounterCache(rule CounterRule) *DB {
+	if rule.ParentKey == "" {
+		rule.ParentKey = "id"
+	}
+	db.counterMu.Lock()
+	defer db.counterMu.Unlock()
+	if db.counters == nil {
+		db.counters = make(map[string][]CounterRule)
+	}
+	db.counters[rule.Child] = append(db.counters[rule.Child], rule)
+	return db
+}
+
+// 按子表重新统计父表的计数，用来修复直接`Exec`或者计数缓存规则注册前写入的数据
+func (db *DB) RecountCounter(ctx context.Context, rule CounterRule) (int64, error) {
+	if rule.ParentKey == "" {
+		rule.ParentKey = "id"
+	}
+	set := fmt.Sprintf("%s = (select count(*) from %s where %s.%s = %s.%s)",
+		rule.Counter, rule.Child, rule.Child, rule.ForeignKey, rule.Parent, rule.ParentKey)
+	return db.AcquireContext(ctx).Name(rule.Parent).Update(set)
+}
+
+// 子表`table`的计数缓存规则
+func (db *DB) countersOf(table string) []CounterRule {
+	db.counterMu.RLock()
+	defer db.counterMu.RUnlock()
+	return db.counters[table]
+}
+
+// 外键的值和行数，按第一次出现的顺序
+type counterDelta struct {
+	keys   []interface{}
+	deltas map[string]int64
+}
+
+func (d *counterDelta) add(key interface{}, n int64) {
+	if key == nil {
+		return
+	}
+	if d.deltas == nil {
+		d.deltas = make(map[string]int64)
+	}
+	k := fmt.Sprint(key)
+	if _, ok := d.deltas[k]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.deltas[k] += n
+}
+
+// 更新父表的计数，`sign`是1或者-1
+func (db *DB) applyCounter(ctx func() *Context, rule CounterRule, delta counterDelta, sign int64) error {
+	set := fmt.Sprintf("%s = %s + %s", rule.Counter, rule.Counter, ParamMarker)
+	for _, key := range delta.keys {
+		if _, err := ctx().Name(rule.Parent).Where(rule.ParentKey+"=?", key).Update(set, sign*delta.deltas[fmt.Sprint(key)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 在事务中执行`run`，`ctx`已经有事务的话直接用
+func (ctx *Context) withCounterTx(parent context.Context, run func(tx *sqlx.Tx) error) error {
+	if ctx.tx != nil {
+		return run(ctx.tx)
+	}
+	return ctx.db.Transaction(parent, PropagationRequired, func(c context.Context) error {
+		return run(ctx.db.TxFromContext(c))
+	})
+}
+
+// 插入并更新父表的计数
+func (ctx *Context) insertCounted(fields []string, data [][]interface{}, rules []CounterRule) (result sql.Result, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	var (
+		db      = ctx.db
+		parent  = ctx.parent
+		pending = true //`ctx`还没有执行，出错时需要放回池子
+	)
+	defer func() {
+		if pending {
+			ctx.release()
+		}
+	}()
+	if parent == nil {
+		parent = context.Background()
+	}
+	deltas := make([]counterDelta, 0, len(rules))
+	counted := make([]CounterRule, 0, len(rules))
+	for _, rule := range rules {
+		index := -1
+		for i, field := range fields {
+			if field == rule.ForeignKey {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		var delta counterDelta
+		for _, row := range data {
+			if index < len(row) {
+				delta.add(row[index], 1)
+			}
+		}
+		counted = append(counted, rule)
+		deltas = append(deltas, delta)
+	}
+	err = ctx.withCounterTx(parent, func(tx *sqlx.Tx) (err error) {
+		ctx.tx, pending = tx, false
+		if result, err = ctx.insertBatch(fields, data...); err != nil {
+			return
+		}
+		acquire := func() *Context { return db.AcquireTx(tx).WithContext(parent) }
+		for i, rule := range counted {
+			if err = db.applyCounter(acquire, rule, deltas[i], 1); err != nil {
+				return
+			}
+		}
+		return
+	})
+	return
+}
+
+// 删除并更新父表的计数，删除前先按外键统计要删除的行数
+func (ctx *Context) deleteCounted(rules []CounterRule) (rowsAffected int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	var (
+		db        = ctx.db
+		parent    = ctx.parent
+		where     = sqlwhere(ctx.wheres, Grouping)
+		whereArgs = append([]interface{}(nil), ctx.whereArgs...)
+		pending   = true
+	)
+	defer func() {
+		if pending {
+			ctx.release()
+		}
+	}()
+	if ctx.err != nil {
+		return 0, ctx.err
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	err = ctx.withCounterTx(parent, func(tx *sqlx.Tx) (err error) {
+		acquire := func() *Context { return db.AcquireTx(tx).WithContext(parent) }
+		deltas := make([]counterDelta, len(rules))
+		for i, rule := range rules {
+			var rows []struct {
+				Key interface{} `db:"k"`
+				N   int64       `db:"n"`
+			}
+			query := fmt.Sprintf("select %s as k, count(*) as n from %s %s group by %s", rule.ForeignKey, rule.Child, where, rule.ForeignKey)
+			if err = acquire().Select(&rows, query, whereArgs...); err != nil {
+				return
+			}
+			for _, row := range rows {
+				deltas[i].add(row.Key, row.N)
+			}
+		}
+		ctx.tx, pending = tx, false
+		if rowsAffected, err = ctx.delete(); err != nil {
+			return
+		}
+		for i, rule := range rules {
+			if err = db.applyCounter(acquire, rule, deltas[i], -1); err != nil {
+				return
+			}
+		}
+		return
+	})
+	return
+}
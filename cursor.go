@@ -0,0 +1,194 @@
+package littleorm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("littleorm: invalid cursor")
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// 游标中保存的内容，`k`是分页字段的值，`d`是翻页方向
+type cursorPayload struct {
+	Keys      []interface{} `json:"k"`
+	Direction string        `json:"d"`
+}
+
+// 设置游标签名的密钥，设置以后生成的游标会带上签名，解析时校验签名，防止客户端篡改游标
+func (db *DB) CursorSecret(secret []byte) *DB {
+	db.cursorSecret = secret
+	return db
+}
+
+// 指定游标分页使用的字段，默认使用`id`
+// 字段组合必须唯一并且可以排序，多个字段时使用行比较 `(a, b) > (?, ?)`
+func (ctx *Context) CursorKey(columns ...string) *Context {
+	ctx.cursorKeys = columns
+	return ctx
+}
+
+// 游标分页，`dest`必须是数组指针，`cursor`为空时查询第一页
+// 返回下一页和上一页的游标，没有下一页或者上一页时对应的游标为空
+// 游标是分页字段值的`base64`编码，对客户端来说是透明的，适合无限滚动这种场景
+func (ctx *Context) CursorPaginate(dest interface{}, cursor string, limit int) (next, prev string, err error) {
+	db := ctx.db
+	keys := ctx.cursorKeys
+	if len(keys) == 0 {
+		keys = []string{"id"}
+	}
+	if limit <= 0 {
+		ctx.release()
+		return "", "", fmt.Errorf("%w: limit must be positive", ErrInvalidCursor)
+	}
+
+	payload := cursorPayload{Direction: cursorNext}
+	if cursor != "" {
+		if payload, err = db.decodeCursor(cursor); err != nil {
+			ctx.release()
+			return
+		}
+		if len(payload.Keys) != len(keys) {
+			ctx.release()
+			return "", "", fmt.Errorf("%w: key count mismatch", ErrInvalidCursor)
+		}
+	}
+
+	orders := make([]string, len(keys))
+	direction, compare := "asc", ">"
+	if payload.Direction == cursorPrev {
+		direction, compare = "desc", "<"
+	}
+	for i, key := range keys {
+		orders[i] = key + SeqSpace + direction
+	}
+	if cursor != "" {
+		places := make([]string, len(keys))
+		for i := range places {
+			places[i] = ParamMarker
+		}
+		ctx.Where(fmt.Sprintf("(%s) %s (%s)", sqljoin(keys, SeqComma), compare, sqljoin(places, SeqComma)), payload.Keys...)
+	}
+	// 多查一条用来判断是否还有数据
+	ctx.Order(sqljoin(orders, SeqComma))
+	ctx.offset = 0
+	ctx.limit = int64(limit) + 1
+	if err = ctx.FindMany(dest); err != nil {
+		return
+	}
+
+	rows := reflect.Indirect(reflect.ValueOf(dest))
+	more := rows.Len() > limit
+	if more {
+		rows.Set(rows.Slice(0, limit))
+	}
+	if payload.Direction == cursorPrev {
+		reverseSlice(rows)
+	}
+	if rows.Len() == 0 {
+		return
+	}
+
+	// 向后翻页时，只要有游标就一定有上一页，是否有下一页看是否多查出数据；向前翻页时反过来
+	hasNext, hasPrev := more, cursor != ""
+	if payload.Direction == cursorPrev {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		if next, err = db.encodeCursor(rows.Index(rows.Len()-1), keys, cursorNext); err != nil {
+			return
+		}
+	}
+	if hasPrev {
+		prev, err = db.encodeCursor(rows.Index(0), keys, cursorPrev)
+	}
+	return
+}
+
+// 根据记录生成游标
+func (db *DB) encodeCursor(row reflect.Value, keys []string, direction string) (string, error) {
+	row = reflect.Indirect(row)
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		field, ok := fieldByTag(row, key)
+		if !ok {
+			return "", fmt.Errorf("%w: field %q not found in %s", ErrInvalidCursor, key, row.Type())
+		}
+		values[i] = field.Interface()
+	}
+	data, err := json.Marshal(cursorPayload{Keys: values, Direction: direction})
+	if err != nil {
+		return "", err
+	}
+	cursor := base64.RawURLEncoding.EncodeToString(data)
+	if len(db.cursorSecret) > 0 {
+		cursor += "." + base64.RawURLEncoding.EncodeToString(db.signCursor(data))
+	}
+	return cursor, nil
+}
+
+// 解析游标，如果设置了密钥则校验签名
+func (db *DB) decodeCursor(cursor string) (payload cursorPayload, err error) {
+	parts := strings.SplitN(cursor, ".", 2)
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return payload, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if len(db.cursorSecret) > 0 {
+		if len(parts) != 2 {
+			return payload, fmt.Errorf("%w: missing signature", ErrInvalidCursor)
+		}
+		sign, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil || !hmac.Equal(sign, db.signCursor(data)) {
+			return payload, fmt.Errorf("%w: bad signature", ErrInvalidCursor)
+		}
+	}
+	// 使用`json.Number`避免大整数丢失精度
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&payload); err != nil {
+		return payload, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if payload.Direction != cursorNext && payload.Direction != cursorPrev {
+		return payload, fmt.Errorf("%w: bad direction", ErrInvalidCursor)
+	}
+	for i, key := range payload.Keys {
+		if number, ok := key.(json.Number); ok {
+			payload.Keys[i] = number.String()
+		}
+	}
+	return payload, nil
+}
+
+func (db *DB) signCursor(data []byte) []byte {
+	mac := hmac.New(sha256.New, db.cursorSecret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// 根据`db`标签查找结构体中的字段
+func fieldByTag(v reflect.Value, tag string) (reflect.Value, bool) {
+	i, ok := lookupModel(v.Type()).index[tag]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return v.Field(i), true
+}
+
+// 反转数组
+func reverseSlice(v reflect.Value) {
+	swap := reflect.Swapper(v.Interface())
+	for i, j := 0, v.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
@@ -0,0 +1,52 @@
+package littleorm
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// 按业务上的唯一字段去重插入，已经存在相同`keys`的记录时不插入，`RowsAffected`为`0`
+// 用一条`insert ... select ... where not exists`完成，避免先查再插的竞争，不依赖唯一索引
+// 但是并发插入时不加唯一索引还是可能重复(取决于隔离级别)，建议同时加上唯一索引
+// eg: result, err := db.Acquire().Name("tag").InsertUnlessExists([]string{"name"}, map[string]interface{}{"name": "go"})
+func (ctx *Context) InsertUnlessExists(keys []string, data map[string]interface{}) (sql.Result, error) {
+	ctx.validate(nil, data)
+	if len(keys) == 0 && ctx.err == nil {
+		ctx.err = fmt.Errorf("littleorm: InsertUnlessExists needs natural keys")
+	}
+	fields := make([]string, 0, len(data))
+	for k := range data {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	places := make([]string, len(fields))
+	params := make([]interface{}, 0, len(fields)+len(keys))
+	for i, field := range fields {
+		places[i] = ParamMarker
+		params = append(params, data[field])
+	}
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		value, ok := data[key]
+		switch {
+		case !ok:
+			if ctx.err == nil {
+				ctx.err = fmt.Errorf("littleorm: natural key %q not in data", key)
+			}
+		case value == nil:
+			// `= NULL`永远不成立
+			conditions[i] = key + " is null"
+		default:
+			conditions[i] = key + "=" + ParamMarker
+			params = append(params, value)
+		}
+	}
+	from := ""
+	if ctx.db.dialect.Name() == "mysql" {
+		from = " from dual"
+	}
+	query := fmt.Sprintf("insert into %s (%s) select %s%s where not exists (select 1 from %s where %s)",
+		ctx.name, sqljoin(fields, SeqComma), sqljoin(places, SeqComma), from, ctx.name, sqljoin(conditions, Grouping))
+	return ctx.exec(query, params...)
+}
@@ -0,0 +1,203 @@
+package littleorm
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 数据库方言，拼接语句时的差异都在这里，`Open`的时候根据驱动选择，其他数据库可以用`SetDialect`指定
+// 拼接语句时统一用`?`作为占位符，执行前按`Placeholder`替换
+type Dialect interface {
+	Name() string
+	// 第`n`个占位符，从1开始，mysql: ?，postgres: $n
+	Placeholder(n int) string
+	// 分页子句，包括前面的空格，`limit`为0表示不分页
+	LimitClause(offset, limit int64) string
+	// 锁定子句，包括前面的空格，见`LockingClause`
+	LockClause(opts LockOptions) (string, error)
+	// 给表名、字段名加上引号
+	Quote(identifier string) string
+}
+
+// 根据驱动选择方言，不认识的驱动按`mysql`处理
+func dialectOf(driverName string) Dialect {
+	switch lintDialect(driverName) {
+	case "postgres":
+		return postgresDialect{}
+	case "sqlite3":
+		return sqliteDialect{}
+	}
+	return mysqlDialect{}
+}
+
+// 指定数据库方言，只在初始化的时候调用，不是线程安全的
+func (db *DB) SetDialect(dialect Dialect) *DB {
+	db.dialect = dialect
+	return db
+}
+
+// 当前使用的数据库方言
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+// 给表名、字段名加上引号，eg: mysql: `order`，postgres: "order"
+func (db *DB) Quote(identifier string) string {
+	return db.dialect.Quote(identifier)
+}
+
+// mysql: limit offset, count，锁兼容`5.7`
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string           { return "mysql" }
+func (mysqlDialect) Placeholder(int) string { return ParamMarker }
+func (mysqlDialect) LimitClause(offset, limit int64) string {
+	return offsetCountLimit(offset, limit)
+}
+func (mysqlDialect) LockClause(opts LockOptions) (string, error) { return lockClause("mysql", opts) }
+func (mysqlDialect) Quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+// postgres: $1 占位符，limit count offset offset
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string             { return "postgres" }
+func (postgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+func (postgresDialect) LimitClause(offset, limit int64) string {
+	if limit == 0 {
+		return ""
+	}
+	clause := " limit " + strconv.FormatInt(limit, 10)
+	if offset != 0 {
+		clause += " offset " + strconv.FormatInt(offset, 10)
+	}
+	return clause
+}
+func (postgresDialect) LockClause(opts LockOptions) (string, error) {
+	return lockClause("postgres", opts)
+}
+func (postgresDialect) Quote(identifier string) string { return doubleQuote(identifier) }
+
+// sqlite3: limit offset, count，不支持行锁
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string           { return "sqlite3" }
+func (sqliteDialect) Placeholder(int) string { return ParamMarker }
+func (sqliteDialect) LimitClause(offset, limit int64) string {
+	return offsetCountLimit(offset, limit)
+}
+func (sqliteDialect) LockClause(opts LockOptions) (string, error) { return lockClause("sqlite3", opts) }
+func (sqliteDialect) Quote(identifier string) string              { return doubleQuote(identifier) }
+
+func offsetCountLimit(offset, limit int64) string {
+	if limit == 0 {
+		return ""
+	}
+	return " limit " + strconv.FormatInt(offset, 10) + SeqComma + strconv.FormatInt(limit, 10)
+}
+
+func doubleQuote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// 拼接分页和锁，锁必须放在分页的后面
+func (db *DB) writeLimitLock(buf *bytes.Buffer, offset, limit int64, lock LockOptions) {
+	buf.WriteString(db.dialect.LimitClause(offset, limit))
+	// 设置锁的时候已经检查过了，不会出错
+	clause, _ := db.dialect.LockClause(lock)
+	buf.WriteString(clause)
+}
+
+// 把`?`占位符替换成当前方言的占位符，和执行时发送到数据库的一样，中间件、故障注入中拿到的是替换之前的语句
+func (db *DB) BindQuery(query string) string {
+	return bindQuery(db.dialect, query)
+}
+
+// 把`?`占位符替换成方言的占位符，字符串、引号和注释中的`?`不替换
+// `??`是转义，替换成一个`?`，用于不是占位符的`?`，比如postgres的`jsonb ?? 'key'`、`tags ??| array[?]`
+// 只有mysql的字符串中`\`是转义字符，其他数据库按标准`SQL`处理，eg: `like ? escape '\'`
+func bindQuery(dialect Dialect, query string) string {
+	if !strings.Contains(query, ParamMarker) || dialect.Placeholder(1) == ParamMarker && !strings.Contains(query, escapedMarker) {
+		return query
+	}
+	var (
+		buf       strings.Builder
+		quote     byte
+		n         int
+		backslash = dialect.Name() == "mysql"
+	)
+	buf.Grow(len(query) + 8)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if backslash && c == '\\' && i+1 < len(query) {
+				buf.WriteByte(c)
+				i++
+				c = query[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 4
+			}
+			buf.WriteString(query[i : i+end+4])
+			i += end + 3
+			continue
+		case strings.HasPrefix(query[i:], escapedMarker):
+			i++
+		case c == '?':
+			n++
+			buf.WriteString(dialect.Placeholder(n))
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// 转义的`?`，不是占位符
+const escapedMarker = "??"
+
+// 执行前替换占位符的连接，mysql和sqlite3不需要替换，直接返回原来的连接
+type boundConn struct {
+	conn interface {
+		sqlx.QueryerContext
+		sqlx.ExecerContext
+	}
+	dialect Dialect
+}
+
+func (b boundConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return b.conn.QueryContext(ctx, bindQuery(b.dialect, query), args...)
+}
+
+func (b boundConn) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return b.conn.QueryxContext(ctx, bindQuery(b.dialect, query), args...)
+}
+
+func (b boundConn) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return b.conn.QueryRowxContext(ctx, bindQuery(b.dialect, query), args...)
+}
+
+func (b boundConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return b.conn.ExecContext(ctx, bindQuery(b.dialect, query), args...)
+}
+
+// 按方言包装连接
+func (db *DB) bound(conn interface {
+	sqlx.QueryerContext
+	sqlx.ExecerContext
+}) boundConn {
+	return boundConn{conn: conn, dialect: db.dialect}
+}
@@ -0,0 +1,49 @@
+package littleorm
+
+import (
+	"errors"
+)
+
+var ErrNoStatement = errors.New("littleorm: no statement to execute")
+
+// 试运行时拦截语句的错误，执行到第一条语句时返回，后面的语句都不会执行
+var errDryRun = errors.New("littleorm: dry run")
+
+// 试运行拦截到的语句
+type dryRun struct {
+	query string
+	args  []interface{}
+}
+
+// 不执行，返回`fn`中第一条要执行的语句和参数，查询和更新都可以，用来测试拼接的语句或者调试
+// `fn`中像平时一样调用`FindMany`、`UpdateMap`这些方法，返回的语句是拼接好、中间件处理之前的，占位符已经按方言替换
+// 执行到第一条语句就停下来，`fn`中的错误原样返回，一条语句都没有执行(比如命中了事务内的缓存)时返回`ErrNoStatement`
+// eg:
+//
+//	query, args, err := db.Acquire().Name("user").Where("id=?", 1).ToSQL(func(ctx *littleorm.Context) error {
+//		_, err := ctx.UpdateMap(map[string]interface{}{"name": "allen"})
+//		return err
+//	})
+func (ctx *Context) ToSQL(fn func(ctx *Context) error) (query string, args []interface{}, err error) {
+	// `fn`执行完`Context`就放回池子了，结果不能放在`Context`上
+	dry := &dryRun{}
+	ctx.dry = dry
+	err = fn(ctx)
+	switch {
+	case errors.Is(err, errDryRun):
+		return dry.query, dry.args, nil
+	case err != nil:
+		return "", nil, err
+	}
+	return "", nil, ErrNoStatement
+}
+
+// 试运行时记录语句并返回`errDryRun`，不是试运行返回`nil`
+func (ctx *Context) dryRun(query string, args []interface{}) error {
+	if ctx.dry == nil {
+		return nil
+	}
+	ctx.dry.query = bindQuery(ctx.db.dialect, query)
+	ctx.dry.args = append([]interface{}(nil), args...)
+	return errDryRun
+}
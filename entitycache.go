@@ -0,0 +1,92 @@
+package littleorm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 设置实体缓存，`FindByPK`先查缓存，没有再查数据库并缓存`ttl`时间，`nil`表示关闭，默认关闭
+// 缓存可以是`NewMemoryCache`，也可以是自己实现的`Redis`缓存，多个实例共用`Redis`时写入后别的实例也能马上看到
+// 通过`Name`指定表名的写入会清掉对应主键的缓存，事务中的写入在`Transaction`提交以后会再清一次，
+// 防止提交前其他请求把旧数据又放进缓存，见`AfterCommit`
+// 同一个实体同时有多个请求没有命中缓存时只查询一次数据库，其他请求等待共用结果，防止缓存击穿
+func (db *DB) EntityCache(cache ResultCache, ttl time.Duration) *DB {
+	db.entityCache, db.entityTTL = cache, ttl
+	return db
+}
+
+// 按主键`id`查询一条记录，设置了`EntityCache`时先查缓存，适合读多写少的热点数据，比如配置、字典
+// 事务中查询不走缓存，保证能读到事务中的修改
+// eg: err := db.Acquire().Name("region").FindByPK(&region, 1)
+func (ctx *Context) FindByPK(dest interface{}, pk interface{}) error {
+	ctx.Where("id=?", pk)
+	ctx.entity = true
+	return ctx.FindOne(dest)
+}
+
+// 查询使用的缓存，`FindByPK`用实体缓存，其他查询用结果缓存
+func (ctx *Context) cacheOf() (ResultCache, time.Duration) {
+	if ctx.entity && ctx.db.entityCache != nil && ctx.tx == nil && ctx.name != "" {
+		return ctx.db.entityCache, ctx.db.entityTTL
+	}
+	return ctx.db.resultCache, ctx.cacheTTL
+}
+
+// 清掉所有缓存中带有这些标签的缓存项
+func (db *DB) invalidate(ttx context.Context, tags ...string) {
+	for _, cache := range []ResultCache{db.resultCache, db.entityCache} {
+		if cache != nil {
+			cache.Invalidate(ttx, tags...)
+		}
+	}
+}
+
+// 事务提交以后执行`fn`，`ctx`中没有`Transaction`开启的事务时马上执行
+// 用`BeginIntoContext`开启的事务由调用方自己提交，也是马上执行
+// 适合提交以后才能做的事情，比如清缓存、发消息，事务回滚的话不会执行
+func (db *DB) AfterCommit(ctx context.Context, fn func()) {
+	if scope, _ := ctx.Value(txKey{db}).(*txScope); scope != nil && scope.managed {
+		scope.onCommit(fn)
+		return
+	}
+	fn()
+}
+
+// 防止缓存击穿，同一个`key`同时只执行一次，其他调用等待并共用结果
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// 执行`fn`，`leader`表示是否是这次调用执行的
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) (data []byte, leader bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.data, false, call.err
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+	call.data, call.err = fn()
+	return call.data, true, call.err
+}
@@ -0,0 +1,61 @@
+package littleorm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// `FindOne`、`Get`查不到记录时返回，包装了`sql.ErrNoRows`，`errors.Is(err, sql.ErrNoRows)`也成立
+var ErrNotFound = fmt.Errorf("littleorm: record not found: %w", sql.ErrNoRows)
+
+// 是否是查不到记录，`ErrNotFound`和驱动直接返回的`sql.ErrNoRows`都算
+func IsNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+// 是否是唯一键冲突，mysql: 1062，postgres: 23505，SQLite: UNIQUE constraint failed
+// eg: if littleorm.IsDuplicateKey(err) { return ErrUserExists }
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// 是否是数据库返回的锁等待超时，mysql: 1205、3572(nowait)，postgres: 55P03，SQLite: database is locked
+func IsLockTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1205 || mysqlErr.Number == 3572
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "55P03"
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// 单条记录的查询查不到时换成`ErrNotFound`
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,287 @@
+package littleorm_test
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lujin123/littleorm"
+	"github.com/lujin123/littleorm/littleormtest"
+)
+
+type User struct {
+	Id    int64  `db:"id" json:"id"`
+	Name  string `db:"name" json:"name"`
+	Age   int    `db:"age" json:"age"`
+	Email string `db:"email" json:"email"`
+}
+
+// 示例用的数据库，内存中的`SQLite`，预先插入三条数据
+func exampleDB() *littleorm.DB {
+	db, err := littleormtest.OpenMemory(`create table user (
+		id integer primary key autoincrement,
+		name varchar(32) not null default '',
+		age int not null default 0,
+		email varchar(64) not null default ''
+	)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fields := []string{"name", "age", "email"}
+	_, err = db.Acquire().Name("user").InsertBatch(fields,
+		[]interface{}{"allen", 18, "allen@gmail.com"},
+		[]interface{}{"bob", 20, "bob@gmail.com"},
+		[]interface{}{"cindy", 22, "cindy@gmail.com"},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+func ExampleContext_FindOne() {
+	db := exampleDB()
+	defer db.Close()
+
+	var user User
+	err := db.Acquire().Name("user").Where("id=?", 1).FindOne(&user)
+	fmt.Println(user.Name, user.Age, err)
+	// Output: allen 18 <nil>
+}
+
+func ExampleContext_FindMany() {
+	db := exampleDB()
+	defer db.Close()
+
+	var users []User
+	err := db.Acquire().Name("user").Where("age>?", 18).Order("id desc").Limit(10).FindMany(&users)
+	for _, user := range users {
+		fmt.Println(user.Name)
+	}
+	fmt.Println(err)
+	// Output:
+	// cindy
+	// bob
+	// <nil>
+}
+
+func ExampleContext_WhereIn() {
+	db := exampleDB()
+	defer db.Close()
+
+	var users []User
+	err := db.Acquire().Name("user").WhereIn("id", []interface{}{1, 3}).FindMany(&users)
+	fmt.Println(len(users), err)
+	// Output: 2 <nil>
+}
+
+func ExampleContext_Insert() {
+	db := exampleDB()
+	defer db.Close()
+
+	result, err := db.Acquire().Name("user").Insert(map[string]interface{}{"name": "david", "age": 30})
+	if err != nil {
+		log.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	fmt.Println(id, err)
+	// Output: 4 <nil>
+}
+
+func ExampleContext_InsertBatch() {
+	db := exampleDB()
+	defer db.Close()
+
+	result, err := db.Acquire().Name("user").InsertBatch([]string{"name", "age"},
+		[]interface{}{"david", 30},
+		[]interface{}{"eric", 31},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows, err := result.RowsAffected()
+	fmt.Println(rows, err)
+	// Output: 2 <nil>
+}
+
+func ExampleContext_Update() {
+	db := exampleDB()
+	defer db.Close()
+
+	rows, err := db.Acquire().Name("user").Where("id=?", 1).Update("age=age+?", 1)
+	fmt.Println(rows, err)
+
+	var user User
+	db.Acquire().Name("user").Where("id=?", 1).FindOne(&user)
+	fmt.Println(user.Age)
+	// Output:
+	// 1 <nil>
+	// 19
+}
+
+func ExampleContext_UpdateMap() {
+	db := exampleDB()
+	defer db.Close()
+
+	rows, err := db.Acquire().Name("user").Where("name=?", "bob").UpdateMap(map[string]interface{}{"age": 25})
+	fmt.Println(rows, err)
+	// Output: 1 <nil>
+}
+
+func ExampleContext_Delete() {
+	db := exampleDB()
+	defer db.Close()
+
+	rows, err := db.Acquire().Name("user").Where("age>=?", 20).Delete()
+	fmt.Println(rows, err)
+	// Output: 2 <nil>
+}
+
+func ExampleContext_Get() {
+	db := exampleDB()
+	defer db.Close()
+
+	var total int64
+	err := db.Acquire().Get(&total, "select count(*) from user where age>?", 18)
+	fmt.Println(total, err)
+	// Output: 2 <nil>
+}
+
+func ExampleContext_SelectFields() {
+	db := exampleDB()
+	defer db.Close()
+
+	// 客户端传过来的字段，可以是`json`标签也可以是`db`标签
+	var user User
+	err := db.Acquire().Name("user").SelectFields([]string{"id", "name"}).Where("id=?", 2).FindOne(&user)
+	fmt.Println(user.Id, user.Name, user.Age, err)
+
+	err = db.Acquire().Name("user").SelectFields([]string{"password"}).FindOne(&user)
+	fmt.Println(errors.Is(err, littleorm.ErrUnknownField))
+	// Output:
+	// 2 bob 0 <nil>
+	// true
+}
+
+func ExampleContext_Filter() {
+	db := exampleDB()
+	defer db.Close()
+
+	allowed := map[string]string{"age": "age", "name": "name"}
+	var users []User
+	err := db.Acquire().Name("user").Filter("age>=20 AND name~in", allowed).FindMany(&users)
+	for _, user := range users {
+		fmt.Println(user.Name)
+	}
+	fmt.Println(err)
+	// Output:
+	// cindy
+	// <nil>
+}
+
+func ExampleContext_OrderParams() {
+	db := exampleDB()
+	defer db.Close()
+
+	var users []User
+	err := db.Acquire().Name("user").OrderParams("-age", map[string]string{"age": "age"}).Limit(1).FindMany(&users)
+	fmt.Println(users[0].Name, err)
+	// Output: cindy <nil>
+}
+
+func ExampleContext_CursorPaginate() {
+	db := exampleDB()
+	defer db.Close()
+
+	var (
+		cursor string
+		page   int
+	)
+	for {
+		var users []User
+		next, _, err := db.Acquire().Name("user").CursorPaginate(&users, cursor, 2)
+		if err != nil {
+			log.Fatal(err)
+		}
+		page++
+		for _, user := range users {
+			fmt.Println(page, user.Name)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	// Output:
+	// 1 allen
+	// 1 bob
+	// 2 cindy
+}
+
+func ExampleDB_Mask() {
+	db := exampleDB()
+	defer db.Close()
+	db.Mask("user", "email", littleorm.MaskEmail).PrivilegedRoles("admin")
+
+	var user User
+	db.Acquire().Name("user").Where("id=?", 1).Role("guest").FindOne(&user)
+	fmt.Println(user.Email)
+	db.Acquire().Name("user").Where("id=?", 1).Role("admin").FindOne(&user)
+	fmt.Println(user.Email)
+	// Output:
+	// a****@gmail.com
+	// allen@gmail.com
+}
+
+func ExampleDB_WithTx() {
+	db := exampleDB()
+	defer db.Close()
+
+	errRollback := errors.New("rollback")
+	err := db.WithTx(func(tx *sqlx.Tx, args interface{}) error {
+		if _, err := db.AcquireTx(tx).Name("user").Where("id=?", 1).Update("age=?", args); err != nil {
+			return err
+		}
+		return errRollback
+	}, 100)
+	fmt.Println(err)
+
+	var user User
+	db.Acquire().Name("user").Where("id=?", 1).FindOne(&user)
+	fmt.Println(user.Age)
+	// Output:
+	// rollback
+	// 18
+}
+
+func ExampleDB_SetReadOnly() {
+	db := exampleDB()
+	defer db.Close()
+
+	db.SetReadOnly(true)
+	_, err := db.Acquire().Name("user").Where("id=?", 1).Delete()
+	fmt.Println(err)
+	// Output: littleorm: db is read only
+}
+
+func ExampleParseFilter() {
+	wheres, args, err := littleorm.ParseFilter(`age>=18 AND city="new york"`, map[string]string{"age": "age", "city": "city"})
+	fmt.Println(wheres, args, err)
+	// Output: [age >= ? city = ?] [18 new york] <nil>
+}
+
+func ExampleOrderFromParams() {
+	order, err := littleorm.OrderFromParams("-created_at,+name", map[string]string{"created_at": "created_at", "name": "name"})
+	fmt.Println(order, err)
+	// Output: created_at desc, name asc <nil>
+}
+
+func ExampleLint() {
+	for _, issue := range littleorm.Lint("postgres", "select `id` from user limit 0, 10") {
+		fmt.Println(issue)
+	}
+	// Output:
+	// [backtick] backtick quoted identifier is mysql only, use double quotes
+	// [limit-offset] `limit offset, count` is mysql only, use `limit count offset offset`
+}
@@ -0,0 +1,44 @@
+package littleorm
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// 判断是否有满足条件的记录，查询`select 1 ... limit 1`，不用把整行数据查出来再扫描到结构体中
+// 会忽略`What`、`Order`和`Offset`，宽表上比`FindOne`快很多
+// eg: ok, err := db.Acquire().Name("user").Where("email=?", email).Exists()
+func (ctx *Context) Exists() (bool, error) {
+	if err := ctx.inUse(); err != nil {
+		return false, err
+	}
+	ctx.What([]string{"1"}).Order("")
+	ctx.limit, ctx.offset = 1, 0
+	var one int
+	err := ctx.FindOne(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// 生成`exists (select 1 ...)`条件，用在别的查询中，`Context`用完放回池子
+// 不存在的条件可以用`Wrap("not ", "")`
+// eg:
+//
+//	paid, err := db.Acquire().Name("orders").Where("orders.user_id=user.id and status=?", 1).ExistsFragment()
+//	err = db.Acquire().Name("user").WhereFragment(paid).FindMany(&users)
+func (ctx *Context) ExistsFragment() (Fragment, error) {
+	if err := ctx.inUse(); err != nil {
+		return Fragment{}, err
+	}
+	defer ctx.release()
+	if ctx.err != nil {
+		return Fragment{}, ctx.err
+	}
+	// 子查询中不需要`limit`，数据库找到一行就会停止
+	ctx.What([]string{"1"}).Order("")
+	ctx.limit, ctx.offset = 0, 0
+	args := ctx.selectArgs()
+	return Frag("exists ("+ctx.buildselect(nil)+")", args...), nil
+}
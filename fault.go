@@ -0,0 +1,145 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var ErrInjectedFault = errors.New("littleorm: injected fault")
+
+// 故障类型
+type FaultKind int
+
+const (
+	FaultError   FaultKind = iota //直接返回错误
+	FaultTimeout                  //一直等到超时，返回`context.DeadlineExceeded`
+	FaultStale                    //返回上一次同样查询的结果，模拟读到旧数据，只对查询有效
+)
+
+// 故障注入规则，用来在测试中验证业务代码的重试和降级逻辑，不要在生产环境使用
+type Fault struct {
+	Kind FaultKind
+	// 匹配哪些`SQL`，为空匹配所有
+	Match func(query string, args []interface{}) bool
+	// 触发的概率，0到1之间，为0表示匹配上就一定触发
+	Probability float64
+	// `FaultError`返回的错误，为空返回`ErrInjectedFault`
+	Err error
+}
+
+// 故障注入的状态
+type faultInjector struct {
+	mu     sync.RWMutex
+	faults []Fault
+	stale  map[string]reflect.Value //`SQL`+参数 => 上一次的查询结果
+}
+
+// 设置故障注入规则，会替换掉之前的规则，不传参数表示关闭
+func (db *DB) InjectFaults(faults ...Fault) *DB {
+	db.faults.mu.Lock()
+	defer db.faults.mu.Unlock()
+	db.faults.faults = faults
+	db.faults.stale = nil
+	for _, fault := range faults {
+		if fault.Kind == FaultStale {
+			db.faults.stale = make(map[string]reflect.Value)
+			break
+		}
+	}
+	return db
+}
+
+// 匹配`SQL`中包含的表名或者关键字，方便写规则
+func MatchSQL(pattern string) func(query string, args []interface{}) bool {
+	return func(query string, args []interface{}) bool {
+		return containsFold(query, pattern)
+	}
+}
+
+// 执行前检查是否需要注入故障，`handled`为`true`表示已经处理了，不需要再执行
+// 匹配规则时持有读锁，等待超时不能持有锁，否则`InjectFaults`会一直阻塞到超时
+func (db *DB) injectFault(ttx context.Context, query string, args []interface{}, dest interface{}) (handled bool, err error) {
+	fault, snapshot, ok := db.matchFault(query, args, dest)
+	if !ok {
+		return false, nil
+	}
+	switch fault.Kind {
+	case FaultError:
+		if fault.Err != nil {
+			return true, fault.Err
+		}
+		return true, fmt.Errorf("%w: %s", ErrInjectedFault, query)
+	case FaultTimeout:
+		<-ttx.Done()
+		return true, ttx.Err()
+	case FaultStale:
+		reflect.ValueOf(dest).Elem().Set(snapshot)
+		return true, nil
+	}
+	return false, nil
+}
+
+// 找到第一条触发的规则，`FaultStale`同时返回上一次查询结果的拷贝
+func (db *DB) matchFault(query string, args []interface{}, dest interface{}) (Fault, reflect.Value, bool) {
+	f := &db.faults
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, fault := range f.faults {
+		if fault.Match != nil && !fault.Match(query, args) {
+			continue
+		}
+		if fault.Probability > 0 && rand.Float64() >= fault.Probability {
+			continue
+		}
+		if fault.Kind == FaultStale {
+			snapshot, ok := f.stale[staleKey(query, args)]
+			if dest == nil || !ok {
+				continue
+			}
+			return fault, copyValue(snapshot), true
+		}
+		return fault, reflect.Value{}, true
+	}
+	return Fault{}, reflect.Value{}, false
+}
+
+// 有`FaultStale`规则时记录查询结果
+func (db *DB) snapshotResult(query string, args []interface{}, dest interface{}) {
+	f := &db.faults
+	f.mu.RLock()
+	enabled := f.stale != nil
+	f.mu.RUnlock()
+	if !enabled {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stale != nil {
+		f.stale[staleKey(query, args)] = copyValue(reflect.ValueOf(dest).Elem())
+	}
+}
+
+func staleKey(query string, args []interface{}) string {
+	return fmt.Sprintf("%s\x00%v", query, args)
+}
+
+// 复制一份，数组需要复制底层数组，避免后面的查询覆盖掉
+func copyValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Slice {
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		return c
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,69 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownField = errors.New("littleorm: unknown field")
+
+// 指定查询字段，字段名可以是对象的`db`标签，也可以是`json`标签
+// 查询时会根据传入的目标对象校验字段并转换成数据库字段，字段不存在返回`ErrUnknownField`
+// 适合接口层直接把客户端传过来的字段列表透传进来，不用担心拼接出不安全的`SQL`
+func (ctx *Context) SelectFields(requested []string) *Context {
+	ctx.fields = requested
+	return ctx
+}
+
+// 根据目标对象解析`SelectFields`指定的字段，填充到`what`中
+func (ctx *Context) resolveFields(dest interface{}) error {
+	if len(ctx.fields) == 0 {
+		return nil
+	}
+	columns, err := mapFields(dest, ctx.fields)
+	if err != nil {
+		return err
+	}
+	if m := modelOf(dest); m != nil {
+		columns = m.selectColumns(columns)
+	}
+	ctx.What(columns)
+	return nil
+}
+
+// 把字段名转换成数据库字段，支持`db`和`json`标签
+func mapFields(dest interface{}, requested []string) ([]string, error) {
+	base := structType(dest)
+	if base == nil {
+		return nil, fmt.Errorf("%w: dest %T is not a struct", ErrUnknownField, dest)
+	}
+	names := make(map[string]string, base.NumField()*2)
+	for i := 0; i < base.NumField(); i++ {
+		field := base.Field(i)
+		column, _ := parseTag(field.Tag.Get(DBTag))
+		if column == "" || column == "-" {
+			continue
+		}
+		names[column] = column
+		if json := strings.Split(field.Tag.Get("json"), ",")[0]; json != "" && json != "-" {
+			names[json] = column
+		}
+	}
+
+	var (
+		columns []string
+		seen    = make(map[string]bool, len(requested))
+	)
+	for _, name := range requested {
+		column, ok := names[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownField, name)
+		}
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
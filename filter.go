@@ -0,0 +1,226 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidFilter = errors.New("littleorm: invalid filter")
+
+// 过滤表达式中支持的操作符，按长度排序，保证优先匹配长的操作符
+var filterOperators = []struct {
+	token string
+	sql   string
+}{
+	{">=", ">="},
+	{"<=", "<="},
+	{"!=", "!="},
+	{"!~", "not like"},
+	{"=", "="},
+	{">", ">"},
+	{"<", "<"},
+	{"~", "like"},
+}
+
+// 解析过滤表达式，转换成`Where`条件
+// `allowed`是允许过滤的字段白名单，key是对外暴露的字段名，value是数据库字段，不在白名单中的字段返回`ErrInvalidFilter`
+// 语法很简单，多个条件只能用`AND`连接，值中有空格的用双引号包起来，`~`表示模糊匹配，eg: age>=18 AND name~allen AND city="new york"
+func (ctx *Context) Filter(filter string, allowed map[string]string) *Context {
+	wheres, args, err := parseFilter(filter, allowed, ctx.db.dialect.Name() != "mysql")
+	if err != nil {
+		ctx.err = err
+		return ctx
+	}
+	for i, where := range wheres {
+		ctx.Where(where, args[i])
+	}
+	return ctx
+}
+
+// 解析过滤表达式，返回条件和对应的参数，每个条件只有一个参数
+// 模糊匹配按`MySQL`的语法生成，默认用`\`转义通配符，其他数据库用`Context.Filter`，会带上`escape`子句
+func ParseFilter(filter string, allowed map[string]string) (wheres []string, args []interface{}, err error) {
+	return parseFilter(filter, allowed, false)
+}
+
+// `escape`为`true`时模糊匹配带上`escape '\'`，`SQLite`没有默认的转义字符，不加的话`\%`匹配不到`%`
+func parseFilter(filter string, allowed map[string]string, escape bool) (wheres []string, args []interface{}, err error) {
+	p := &filterParser{input: filter}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			if len(wheres) > 0 {
+				err = fmt.Errorf("%w: unexpected end after AND", ErrInvalidFilter)
+			}
+			return
+		}
+		field := p.ident()
+		if field == "" {
+			err = fmt.Errorf("%w: expect field at %d", ErrInvalidFilter, p.pos)
+			return
+		}
+		column, ok := allowed[field]
+		if !ok {
+			err = fmt.Errorf("%w: field %q is not allowed", ErrInvalidFilter, field)
+			return
+		}
+		p.skipSpace()
+		op := p.operator()
+		if op == "" {
+			err = fmt.Errorf("%w: expect operator after %q", ErrInvalidFilter, field)
+			return
+		}
+		p.skipSpace()
+		var value string
+		if value, err = p.value(); err != nil {
+			return
+		}
+		where := fmt.Sprintf("%s %s %s", column, op, ParamMarker)
+		if op == "like" || op == "not like" {
+			value = "%" + escapeLike(value) + "%"
+			if escape {
+				where += ` escape '\'`
+			}
+		}
+		wheres = append(wheres, where)
+		args = append(args, value)
+
+		p.skipSpace()
+		if p.eof() {
+			return
+		}
+		if !p.keyword("and") {
+			err = fmt.Errorf("%w: expect AND at %d", ErrInvalidFilter, p.pos)
+			return
+		}
+	}
+}
+
+// 转义`like`中的通配符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+type filterParser struct {
+	input string
+	pos   int
+}
+
+func (p *filterParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *filterParser) skipSpace() {
+	for !p.eof() && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *filterParser) ident() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.input[p.pos]
+		if c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' && p.pos > start {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *filterParser) operator() string {
+	for _, op := range filterOperators {
+		if strings.HasPrefix(p.input[p.pos:], op.token) {
+			p.pos += len(op.token)
+			return op.sql
+		}
+	}
+	return ""
+}
+
+func (p *filterParser) value() (string, error) {
+	if p.eof() {
+		return "", fmt.Errorf("%w: expect value at %d", ErrInvalidFilter, p.pos)
+	}
+	if p.input[p.pos] != '"' {
+		start := p.pos
+		for !p.eof() && p.input[p.pos] != ' ' && p.input[p.pos] != '\t' {
+			p.pos++
+		}
+		return p.input[start:p.pos], nil
+	}
+	var b strings.Builder
+	for p.pos++; !p.eof(); p.pos++ {
+		c := p.input[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input):
+			p.pos++
+			b.WriteByte(p.input[p.pos])
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("%w: unterminated quote", ErrInvalidFilter)
+}
+
+func (p *filterParser) keyword(word string) bool {
+	end := p.pos + len(word)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], word) {
+		return false
+	}
+	if end < len(p.input) && p.input[end] != ' ' && p.input[end] != '\t' {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// 根据排序参数设置排序，参数解析见`OrderFromParams`，解析失败在执行时返回错误
+func (ctx *Context) OrderParams(param string, allowed map[string]string) *Context {
+	order, err := OrderFromParams(param, allowed)
+	if err != nil {
+		ctx.err = err
+		return ctx
+	}
+	return ctx.Order(order)
+}
+
+// 解析排序参数，转换成`order by`的内容，eg: "-created_at,+name" => "created_at desc, name asc"
+// `-`表示降序，`+`或者不写表示升序，`allowed`是允许排序的字段白名单，key是对外暴露的字段名，value是数据库字段
+// 不在白名单中的字段返回`ErrInvalidFilter`，这样用户传进来的排序参数就没办法注入了
+func OrderFromParams(param string, allowed map[string]string) (string, error) {
+	var (
+		orders []string
+		seen   = make(map[string]bool)
+	)
+	for _, item := range strings.Split(strings.TrimPrefix(strings.TrimSpace(param), "?"), ",") {
+		// url中的`+`会被解码成空格，所以这里先去掉空格
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		direction := "asc"
+		switch item[0] {
+		case '-':
+			direction = "desc"
+			item = item[1:]
+		case '+':
+			item = item[1:]
+		}
+		column, ok := allowed[item]
+		if !ok {
+			return "", fmt.Errorf("%w: sort field %q is not allowed", ErrInvalidFilter, item)
+		}
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		orders = append(orders, column+SeqSpace+direction)
+	}
+	return sqljoin(orders, SeqComma), nil
+}
@@ -0,0 +1,98 @@
+package littleorm
+
+import "strings"
+
+// 带参数的`SQL`片段，可以封装成函数在项目之间复用，比如常用的查询条件
+// eg:
+//
+//	func activeUsers() littleorm.Fragment { return littleorm.Frag("status=? and deleted_at is null", 1) }
+//	func inRegion(r string) littleorm.Fragment { return littleorm.Frag("region=?", r) }
+//
+//	db.Acquire().Name("user").WhereFragment(littleorm.And(activeUsers(), inRegion("cn"))).FindMany(&users)
+type Fragment struct {
+	SQL  string
+	Args []interface{}
+}
+
+// 创建片段
+func Frag(sql string, args ...interface{}) Fragment {
+	return Fragment{SQL: sql, Args: args}
+}
+
+// 是否是空片段
+func (f Fragment) IsEmpty() bool {
+	return strings.TrimSpace(f.SQL) == ""
+}
+
+// 用`sep`连接其他片段，参数按顺序合并，空片段会跳过
+func (f Fragment) Append(sep string, others ...Fragment) Fragment {
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, item := range append([]Fragment{f}, others...) {
+		if item.IsEmpty() {
+			continue
+		}
+		parts = append(parts, item.SQL)
+		args = append(args, item.Args...)
+	}
+	return Fragment{SQL: strings.Join(parts, sep), Args: args}
+}
+
+// 在片段前后加上内容，参数不变，eg: Frag("select id from vip").Wrap("id in (", ")")
+func (f Fragment) Wrap(prefix, suffix string) Fragment {
+	return Fragment{SQL: prefix + f.SQL + suffix, Args: f.Args}
+}
+
+// 用`and`连接条件，每个条件加上括号，空条件会跳过
+func And(fragments ...Fragment) Fragment {
+	return joinConditions(" and ", fragments)
+}
+
+// 用`or`连接条件，每个条件加上括号，空条件会跳过
+func Or(fragments ...Fragment) Fragment {
+	return joinConditions(" or ", fragments)
+}
+
+func joinConditions(sep string, fragments []Fragment) Fragment {
+	wrapped := make([]Fragment, 0, len(fragments))
+	for _, f := range fragments {
+		if !f.IsEmpty() {
+			wrapped = append(wrapped, f.Wrap("(", ")"))
+		}
+	}
+	if len(wrapped) == 0 {
+		return Fragment{}
+	}
+	return wrapped[0].Append(sep, wrapped[1:]...)
+}
+
+// 用片段添加条件，和`Where`一样会检查占位符的个数，空片段不添加
+func (ctx *Context) WhereFragment(f Fragment) *Context {
+	if f.IsEmpty() {
+		return ctx
+	}
+	return ctx.Where(f.SQL, f.Args...)
+}
+
+// 用片段指定查询字段，字段中可以带参数，eg: Frag("distance(lat, lng, ?, ?) as distance", lat, lng)
+func (ctx *Context) WhatFragment(fragments ...Fragment) *Context {
+	what := make([]string, len(fragments))
+	var args []interface{}
+	for i, f := range fragments {
+		ctx.checkPlaceholders("select", f.SQL, f.Args)
+		what[i] = f.SQL
+		args = append(args, f.Args...)
+	}
+	ctx.what, ctx.whatArgs = what, args
+	return ctx
+}
+
+// 用片段指定排序，排序中可以带参数，eg: Frag("field(status, ?, ?)", 2, 1)
+func (ctx *Context) OrderFragment(fragments ...Fragment) *Context {
+	f := Fragment{}.Append(SeqComma, fragments...)
+	ctx.checkPlaceholders("order by", f.SQL, f.Args)
+	ctx.order, ctx.orderArgs = f.SQL, f.Args
+	return ctx
+}
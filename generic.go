@@ -0,0 +1,40 @@
+package littleorm
+
+// 精确统计条数，会忽略`Order`、`Limit`和`Offset`，有`Group`的话统计分组的个数，大表可以用`CountWith`估算
+// eg: n, err := littleorm.Count[int64](db.Acquire().Name("user").Where("age>?", 18))
+func Count[T ~int64 | ~int](ctx *Context) (T, error) {
+	var n T
+	if err := ctx.inUse(); err != nil {
+		return n, err
+	}
+	ctx.Order("")
+	ctx.limit, ctx.offset = 0, 0
+	if len(ctx.groups) == 0 {
+		ctx.What([]string{"count(*)"})
+	} else if ctx.err == nil {
+		ctx.What([]string{"1"})
+		ctx.args = ctx.selectArgs()
+		ctx.sql = "select count(*) from (" + ctx.sqlselect(nil) + ") t"
+	}
+	err := ctx.FindOne(&n)
+	return n, err
+}
+
+// 查询单个字段的值
+// eg: names, err := littleorm.Pluck[string](db.Acquire().Name("user").Where("age>?", 18), "name")
+func Pluck[T any](ctx *Context, column string) ([]T, error) {
+	var values []T
+	if err := ctx.inUse(); err != nil {
+		return nil, err
+	}
+	err := ctx.What([]string{column}).FindMany(&values)
+	return values, err
+}
+
+// 执行`SQL`并把结果扫描到`T`中，和`Get`一样，不用再声明变量
+// eg: total, err := littleorm.GetAs[int64](db.Acquire(), "select count(*) from user")
+func GetAs[T any](ctx *Context, sql string, args ...interface{}) (T, error) {
+	var value T
+	err := ctx.Get(&value, sql, args...)
+	return value, err
+}
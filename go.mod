@@ -1,7 +1,11 @@
 module github.com/lujin123/littleorm
 
+go 1.23
+
 require (
-	github.com/go-sql-driver/mysql v1.4.1
-	github.com/jmoiron/sqlx v1.2.0
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/jmoiron/sqlx v1.3.5
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/stretchr/testify v1.3.0
 )
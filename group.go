@@ -0,0 +1,111 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidIdentifier = errors.New("littleorm: invalid identifier")
+	ErrGroupBy           = errors.New("littleorm: column not in group by")
+)
+
+// 字段名，可以带表名，eg: name, u.name
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// 分组字段，可以传多个，多次调用会追加，字段名会自动加上引号
+// 只能是字段名，表达式请用`GroupExpr`，否则执行时返回`ErrInvalidIdentifier`
+func (ctx *Context) Group(columns ...string) *Context {
+	for _, column := range columns {
+		column = strings.TrimSpace(column)
+		if !identPattern.MatchString(column) {
+			if ctx.err == nil {
+				ctx.err = fmt.Errorf("%w: group by %q, use GroupExpr for expressions", ErrInvalidIdentifier, column)
+			}
+			return ctx
+		}
+		ctx.groups = append(ctx.groups, ctx.db.quoteIdent(column))
+	}
+	return ctx
+}
+
+// 分组表达式，原样拼接，eg: GroupExpr("date(created_at)")，GroupExpr("age div ?", 10)
+func (ctx *Context) GroupExpr(expr string, args ...interface{}) *Context {
+	ctx.checkPlaceholders("group by", expr, args)
+	ctx.groups = append(ctx.groups, expr)
+	ctx.groupArgs = append(ctx.groupArgs, args...)
+	return ctx
+}
+
+// 开启严格分组检查，查询的非聚合字段必须出现在分组中，和`MySQL`的`ONLY_FULL_GROUP_BY`一致
+// 开启以后不满足的查询在执行前就返回`ErrGroupBy`，不用等到数据库报错
+func (db *DB) StrictGroupBy(strict bool) *DB {
+	db.strictGroup = strict
+	return db
+}
+
+// 检查查询字段是否都在分组中
+func (ctx *Context) checkGroupBy(dest interface{}) error {
+	if !ctx.db.strictGroup || len(ctx.groups) == 0 {
+		return nil
+	}
+	var (
+		grouped   = make(map[string]bool, len(ctx.groups))
+		qualified = make(map[string]bool) //带表名的分组字段去掉表名，eg: u.name => name
+	)
+	for _, group := range ctx.groups {
+		group = unquoteIdent(group)
+		grouped[group] = true
+		if i := strings.LastIndex(group, "."); i >= 0 {
+			qualified[group[i+1:]] = true
+		}
+	}
+	what := ctx.what
+	if len(what) == 0 {
+		if m := modelOf(dest); m != nil {
+			what = m.columns
+		}
+	}
+	for _, item := range what {
+		column := selectedColumn(item)
+		if column == "" || grouped[column] {
+			continue
+		}
+		// 分组用的是`name`，查询的是`u.name`也算，反过来分组用的是`u.name`，查询的是`name`也算
+		if i := strings.LastIndex(column, "."); i >= 0 && grouped[column[i+1:]] || i < 0 && qualified[column] {
+			continue
+		}
+		return fmt.Errorf("%w: %q", ErrGroupBy, item)
+	}
+	return nil
+}
+
+// 取出查询项中的字段名，聚合函数、常量等表达式返回空
+// eg: "name" => "name", "u.name as n" => "u.name", "count(id) as total" => ""
+func selectedColumn(item string) string {
+	fields := strings.Fields(item)
+	if len(fields) == 0 {
+		return ""
+	}
+	column := unquoteIdent(fields[0])
+	if !identPattern.MatchString(column) {
+		return ""
+	}
+	return column
+}
+
+// 给字段名加上引号，`表.字段`分开加，引号按`Dialect.Quote`
+func (db *DB) quoteIdent(ident string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		parts[i] = db.dialect.Quote(part)
+	}
+	return sqljoin(parts, ".")
+}
+
+// 去掉字段名的引号
+func unquoteIdent(ident string) string {
+	return strings.NewReplacer("`", "", `"`, "").Replace(ident)
+}
@@ -0,0 +1,73 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrQueryTooExpensive      = errors.New("littleorm: query too expensive")
+	ErrUnsupportedScanCeiling = errors.New("littleorm: scan ceiling only supports mysql")
+)
+
+// 设置全表扫描的行数上限，查询前会先`EXPLAIN`一下，如果是全表扫描(或者全索引扫描)并且预估行数超过上限就拒绝执行
+// 主要是给生产环境用的，防止不小心在大表上跑了没有索引的查询，`0`表示关闭，默认关闭
+// 确实需要扫表的分析查询可以用`Context.AllowScan`跳过检查
+// 只支持`MySQL`的`EXPLAIN`格式，其他数据库设置了上限的话查询直接返回`ErrUnsupportedScanCeiling`，不会悄悄跳过检查
+func (db *DB) ScanCeiling(rows int64) *DB {
+	db.scanCeiling = rows
+	return db
+}
+
+// 跳过全表扫描检查
+func (ctx *Context) AllowScan() *Context {
+	ctx.allowScan = true
+	return ctx
+}
+
+// 执行前检查查询的代价
+func (ctx *Context) guard(ttx context.Context, q sqlx.QueryerContext) error {
+	if ctx.db.scanCeiling <= 0 || ctx.allowScan {
+		return nil
+	}
+	if ctx.db.dialect.Name() != "mysql" {
+		return fmt.Errorf("%w: dialect %s", ErrUnsupportedScanCeiling, ctx.db.dialect.Name())
+	}
+	rows, err := q.QueryxContext(ttx, "explain "+ctx.sql, ctx.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		plan := make(map[string]interface{})
+		if err = rows.MapScan(plan); err != nil {
+			return err
+		}
+		scanType := explainString(plan["type"])
+		if scanType != "ALL" && scanType != "index" {
+			continue
+		}
+		estimate, _ := strconv.ParseInt(explainString(plan["rows"]), 10, 64)
+		if estimate > ctx.db.scanCeiling {
+			return fmt.Errorf("%w: %s scan on %s, estimated %d rows exceeds %d",
+				ErrQueryTooExpensive, scanType, explainString(plan["table"]), estimate, ctx.db.scanCeiling)
+		}
+	}
+	return rows.Err()
+}
+
+// `EXPLAIN`的结果根据驱动不同可能是`[]byte`也可能是其他类型，统一转成字符串
+func explainString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,114 @@
+package littleorm
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// 绑定到查询指纹上的提示，执行的时候自动加到语句中
+type Hints struct {
+	// 索引提示，加在`Table`(默认是`Name`指定的表)后面，eg: force index (idx_user_age)
+	Index string
+	// 索引提示加在哪个表后面，关联查询时指定，为空时用`Name`指定的表
+	Table string
+	// 优化器提示，不带`/*+ */`，加在`select`、`update`这些关键字后面，eg: MAX_EXECUTION_TIME(1000) NO_ICP(t)
+	Optimizer string
+}
+
+// 给查询指纹绑定提示，DBA发现某个查询的执行计划不稳定时，不用改每个调用的地方就能固定执行计划
+// 指纹用`Fingerprint`计算，参数、数字和字符串都会替换成`?`，`in`列表不管多长都是同一个指纹
+// 可以在运行时修改，比如从配置中心加载
+// eg: db.PinHints(littleorm.Fingerprint("select id from user where age>18"), littleorm.Hints{Index: "force index (idx_age)"})
+func (db *DB) PinHints(fingerprint string, hints Hints) *DB {
+	db.hintsMu.Lock()
+	defer db.hintsMu.Unlock()
+	if db.hints == nil {
+		db.hints = make(map[string]Hints)
+	}
+	db.hints[fingerprint] = hints
+	return db
+}
+
+// 删除绑定的提示
+func (db *DB) UnpinHints(fingerprint string) *DB {
+	db.hintsMu.Lock()
+	defer db.hintsMu.Unlock()
+	delete(db.hints, fingerprint)
+	return db
+}
+
+var (
+	fingerprintString = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"`)
+	fingerprintNumber = regexp.MustCompile(`\b-?\d+(?:\.\d+)?\b`)
+	fingerprintSpace  = regexp.MustCompile(`\s+`)
+	fingerprintIn     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	fingerprintHint   = regexp.MustCompile(`/\*\+.*?\*/`)
+)
+
+// 计算查询的指纹，去掉参数的值，相同结构的查询指纹一样
+// eg: select id from user where age > 18 and name in ('a', 'b') => select id from user where age > ? and name in (?+)
+func Fingerprint(query string) string {
+	query = fingerprintHint.ReplaceAllString(query, "")
+	query = fingerprintString.ReplaceAllString(query, "?")
+	query = fingerprintNumber.ReplaceAllString(query, "?")
+	query = fingerprintIn.ReplaceAllString(query, "(?+)")
+	query = fingerprintSpace.ReplaceAllString(strings.TrimSpace(query), " ")
+	return strings.ToLower(query)
+}
+
+// 执行前加上绑定的提示
+func (db *DB) hinted(next Executor) Executor {
+	return func(ctx context.Context, stmt *Statement) error {
+		db.hintsMu.RLock()
+		empty := len(db.hints) == 0
+		db.hintsMu.RUnlock()
+		if empty {
+			return next(ctx, stmt)
+		}
+		fingerprint := Fingerprint(stmt.Query)
+		db.hintsMu.RLock()
+		hints, ok := db.hints[fingerprint]
+		db.hintsMu.RUnlock()
+		if ok {
+			stmt.Query = hints.apply(stmt.Query, stmt.Table)
+		}
+		return next(ctx, stmt)
+	}
+}
+
+var hintKeyword = regexp.MustCompile(`(?i)^\s*(select|update|delete|insert|replace)\b`)
+
+// 把提示加到语句中
+func (h Hints) apply(query, table string) string {
+	if h.Table != "" {
+		table = h.Table
+	}
+	if h.Index != "" && table != "" {
+		// 表名后面可能有别名，索引提示要加在别名后面
+		pattern := regexp.MustCompile(`(?i)\b(from|join|update)\s+` + regexp.QuoteMeta(table) +
+			"(\\s+(?:as\\s+)?(?:[a-z_][a-z0-9_]*))?\\b")
+		if loc := pattern.FindStringSubmatchIndex(query); loc != nil {
+			end := loc[1]
+			if loc[4] >= 0 && isReservedAfterTable(query[loc[4]:loc[5]]) {
+				end = loc[4]
+			}
+			query = query[:end] + " " + h.Index + query[end:]
+		}
+	}
+	if h.Optimizer != "" {
+		if loc := hintKeyword.FindStringIndex(query); loc != nil {
+			query = query[:loc[1]] + " /*+ " + h.Optimizer + " */" + query[loc[1]:]
+		}
+	}
+	return query
+}
+
+// 表名后面的单词是关键字的话不是别名
+func isReservedAfterTable(word string) bool {
+	switch strings.ToLower(strings.TrimSpace(word)) {
+	case "where", "join", "inner", "left", "right", "cross", "on", "group", "order", "limit", "set", "for", "lock", "having", "union", "using", "natural", "straight_join":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package littleorm
+
+import (
+	"reflect"
+	"sync"
+)
+
+// 模型的钩子，按注册的顺序执行
+type modelHooks struct {
+	mu        sync.RWMutex
+	afterScan []func(row interface{}) error
+}
+
+// 注册模型`T`的`AfterScan`钩子，`FindOne`、`FindMany`、`FindSeq`扫描完每一行以后调用
+// 可以用来解密字段、把`JSON`字段解析到嵌套的结构体、计算派生字段，返回错误的话查询也返回这个错误
+// 钩子在脱敏之前执行，一般在`init`中注册
+// eg: littleorm.AfterScan(func(u *User) error { return json.Unmarshal(u.Raw, &u.Profile) })
+func AfterScan[T any](hook func(row *T) error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic("littleorm: AfterScan hook must be registered on a struct type, got " + t.String())
+	}
+	m := lookupModel(t)
+	m.hooks.mu.Lock()
+	defer m.hooks.mu.Unlock()
+	m.hooks.afterScan = append(m.hooks.afterScan, func(row interface{}) error {
+		return hook(row.(*T))
+	})
+}
+
+// 对查询结果执行`AfterScan`钩子
+func runAfterScan(dest interface{}) (err error) {
+	m := modelOf(dest)
+	if m == nil {
+		return nil
+	}
+	m.hooks.mu.RLock()
+	hooks := m.hooks.afterScan
+	m.hooks.mu.RUnlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+	eachStruct(dest, func(v reflect.Value) {
+		if err != nil || !v.CanAddr() {
+			return
+		}
+		row := v.Addr().Interface()
+		for _, hook := range hooks {
+			if err = hook(row); err != nil {
+				return
+			}
+		}
+	})
+	return
+}
@@ -0,0 +1,71 @@
+package littleorm
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 发送`KILL QUERY`的超时时间
+const killTimeout = 5 * time.Second
+
+// 获取一个使用请求的`context.Context`的`Context`，客户端断开连接以后查询会被取消
+// eg: err := db.AcquireRequest(r).Name("user").Where("id=?", id).FindOne(&user)
+func (db *DB) AcquireRequest(r *http.Request) *Context {
+	return db.AcquireContext(r.Context())
+}
+
+// 查询被取消或者超时的时候，用另外一个连接发送`KILL QUERY`终止服务端还在执行的查询，只对`MySQL`有效
+// 驱动取消查询只是断开连接，服务端的慢查询还会一直跑下去，开启以后每次执行前会多查一次`connection_id()`
+func (db *DB) KillOnCancel(enabled bool) *DB {
+	db.killOnCancel = enabled
+	return db
+}
+
+// 监听查询的取消，取消时`KILL`服务端的查询，返回的函数在查询结束以后调用
+// 没有事务也没有固定连接的时候需要先固定一个连接，才能知道查询在哪个连接上执行
+func (ctx *Context) watchCancel(ttx context.Context) (stop func(), err error) {
+	stop = func() {}
+	if !ctx.db.killOnCancel || ctx.db.dialect.Name() != "mysql" {
+		return
+	}
+	release := func() {}
+	if ctx.tx == nil && ctx.conn == nil {
+		conn, err := ctx.db.Pool().Connx(ttx)
+		if err != nil {
+			return stop, err
+		}
+		ctx.conn = conn
+		release = func() {
+			ctx.conn = nil
+			conn.Close()
+		}
+	}
+	var id int64
+	if err = sqlx.GetContext(ttx, ctx.queryer(), &id, "select connection_id()"); err != nil {
+		release()
+		return stop, err
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-done:
+		case <-ttx.Done():
+			kill, cancel := context.WithTimeout(context.Background(), killTimeout)
+			defer cancel()
+			if _, err := ctx.db.Pool().ExecContext(kill, "kill query ?", id); err != nil {
+				ctx.db.logError("littleorm kill query failed", LogField{"id", id}, LogField{"error", err})
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+		release()
+	}, nil
+}
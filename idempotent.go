@@ -0,0 +1,85 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrDuplicateToken = errors.New("littleorm: duplicate idempotency token")
+
+// 默认的去重表
+const defaultIdempotencyTable = "littleorm_idempotency"
+
+// 设置`ExecIdempotent`使用的去重表，默认`littleorm_idempotency`，需要自己建表，`token`必须是主键或者唯一索引
+// eg: create table littleorm_idempotency (token varchar(64) primary key, created_at datetime not null default current_timestamp)
+// 去重表会一直增长，需要定期清理过期的`token`，比如用`PurgeOlderThan`按`created_at`清理，mysql以外的数据库按`id`分批删除，去重表需要有`id`字段
+// eg: db.PurgeOlderThan("littleorm_idempotency", "created_at", 7*24*time.Hour, 1000).Run(ctx)
+func (db *DB) IdempotencyTable(table string) *DB {
+	db.idempotencyTable = table
+	return db
+}
+
+// 带去重的执行，`token`由客户端生成，网络超时重试时使用同一个`token`
+// 在同一个事务中先记录`token`再执行语句，`token`已经存在的话不执行，返回`ErrDuplicateToken`，调用方可以当作成功处理
+// 有事务的话在事务中执行，否则开启一个新的事务
+// eg: _, err := db.Acquire().ExecIdempotent(req.RequestId, "update account set balance=balance-? where id=?", 100, 1)
+func (ctx *Context) ExecIdempotent(token, query string, args ...interface{}) (sql.Result, error) {
+	if err := ctx.inUse(); err != nil {
+		return nil, err
+	}
+	db, tx, name, parent := ctx.db, ctx.tx, ctx.name, ctx.parent
+	ctx.release()
+	if token == "" {
+		return nil, fmt.Errorf("littleorm: ExecIdempotent needs a token")
+	}
+	run := func(tx *sqlx.Tx) (sql.Result, error) {
+		// 记录`token`的语句不设置表名，不会清掉业务表的缓存
+		result, err := db.AcquireTx(tx).WithContext(parent).Exec(db.idempotencyInsert(), token)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateToken, token)
+		}
+		return db.AcquireTx(tx).WithContext(parent).Name(name).Exec(query, args...)
+	}
+	if tx != nil {
+		return run(tx)
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	var result sql.Result
+	err := db.Transaction(parent, PropagationRequiresNew, func(txctx context.Context) (err error) {
+		result, err = run(db.TxFromContext(txctx))
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 记录`token`的语句，已经存在的话不插入
+func (db *DB) idempotencyInsert() string {
+	table := db.idempotencyTable
+	if table == "" {
+		table = defaultIdempotencyTable
+	}
+	switch db.dialect.Name() {
+	case "postgres":
+		return "insert into " + table + " (token) values (?) on conflict do nothing"
+	case "sqlite3":
+		return "insert or ignore into " + table + " (token) values (?)"
+	default:
+		return "insert ignore into " + table + " (token) values (?)"
+	}
+}
@@ -0,0 +1,84 @@
+package littleorm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+)
+
+// 事务内的缓存，同一个事务中按主键查询同一行数据时直接返回缓存，不用再查数据库
+type identityMap struct {
+	mu    sync.Mutex
+	items map[string]map[string]reflect.Value //表 => 缓存的`key` => 查询结果
+}
+
+func newIdentityMap() *identityMap {
+	return &identityMap{items: make(map[string]map[string]reflect.Value)}
+}
+
+// 按主键查询的条件，eg: id=?
+var identityWhere = regexp.MustCompile(`^\s*id\s*=\s*\?\s*$`)
+
+// 是否开启事务内的缓存，默认开启
+// 开启以后用`BeginIntoContext`、`Transaction`开启的事务中，通过`db.From(ctx)`按主键`FindOne`时
+// 同一行数据只查询一次，之后直接返回缓存，通过`db.From(ctx)`写入这张表时清掉这张表的缓存
+// 用`AcquireTx`直接写入不会清掉缓存，需要读到最新数据的查询可以用`Context.NoIdentityMap`跳过缓存
+func (db *DB) IdentityMap(enabled bool) *DB {
+	db.identityOff = !enabled
+	return db
+}
+
+// 跳过事务内的缓存，直接查询数据库
+func (ctx *Context) NoIdentityMap() *Context {
+	ctx.identity = nil
+	return ctx
+}
+
+// 按主键查询时缓存的`key`，不是按主键查询返回`false`
+func (ctx *Context) identityKey(dest interface{}) (string, bool) {
+	if ctx.identity == nil || ctx.sql != "" || ctx.err != nil || len(ctx.wheres) != 1 || len(ctx.whereArgs) != 1 ||
+		len(ctx.what) != 0 || len(ctx.fields) != 0 || len(ctx.groups) != 0 || len(ctx.havings) != 0 ||
+		len(ctx.counts) != 0 || ctx.offset != 0 || ctx.lock.Mode != LockNone || !identityWhere.MatchString(ctx.wheres[0]) {
+		return "", false
+	}
+	t := reflect.TypeOf(dest)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return "", false
+	}
+	return fmt.Sprintf("%s\x00%s\x00%v", t.Elem(), ctx.role, ctx.whereArgs[0]), true
+}
+
+// 从缓存中取出结果赋值给`dest`
+func (m *identityMap) load(table, key string, dest interface{}) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.items[table][key]
+	if ok {
+		reflect.ValueOf(dest).Elem().Set(value)
+	}
+	return ok
+}
+
+// 缓存查询结果，保存一份拷贝，修改`dest`不会影响缓存
+func (m *identityMap) store(table, key string, dest interface{}) {
+	value := reflect.New(reflect.TypeOf(dest).Elem()).Elem()
+	value.Set(reflect.ValueOf(dest).Elem())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items[table] == nil {
+		m.items[table] = make(map[string]reflect.Value)
+	}
+	m.items[table][key] = value
+}
+
+// 清掉表`table`的缓存，`table`为空的话清掉所有缓存
+func (m *identityMap) invalidate(table string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if table == "" {
+		m.items = make(map[string]map[string]reflect.Value)
+		return
+	}
+	delete(m.items, table)
+}
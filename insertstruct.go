@@ -0,0 +1,82 @@
+package littleorm
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// 使用结构体插入，字段从`db`标签中读取，跳过只读字段、计算字段、软删除字段和值为零的自增字段
+// 结构体实现了`Validator`的话写入前先校验，传入指针并且自增字段是整数时会把`LastInsertId`写回去
+// 结构体用`Polymorphic`注册过的话会写入类型字段，结构体自己有这个字段并且不是零值时用结构体的值
+// eg:
+//
+//	user := User{Name: "allen"}
+//	result, err := db.Acquire().Name("user").InsertStruct(&user)
+func (ctx *Context) InsertStruct(v interface{}) (sql.Result, error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("littleorm: InsertStruct needs a struct or pointer to struct, got %T", v)
+		}
+		return ctx.insertBatch(nil)
+	}
+	m := lookupModel(value.Type())
+	var (
+		data   = make(map[string]interface{}, len(m.columns))
+		fields = make([]string, 0, len(m.columns))
+		params = make([]interface{}, 0, len(m.columns))
+	)
+	for _, column := range m.columns {
+		if m.readonly[column] || column == m.deletedAt {
+			continue
+		}
+		field := value.Field(m.index[column])
+		if column == m.autoincr && field.IsZero() {
+			continue
+		}
+		data[column] = field.Interface()
+		fields = append(fields, column)
+		params = append(params, data[column])
+	}
+	if d, ok := discriminatorOf(value.Type()); ok {
+		if current, ok := data[d.column]; !ok {
+			data[d.column] = d.value
+			fields = append(fields, d.column)
+			params = append(params, d.value)
+		} else if reflect.ValueOf(current).IsZero() {
+			data[d.column] = d.value
+			for i, field := range fields {
+				if field == d.column {
+					params[i] = d.value
+				}
+			}
+		}
+	}
+	ctx.validate(v, data)
+	result, err := ctx.InsertBatch(fields, params)
+	if err != nil {
+		return result, err
+	}
+	if m.autoincr != "" && value.CanSet() {
+		setInsertId(value.Field(m.index[m.autoincr]), result)
+	}
+	return result, nil
+}
+
+// 自增字段是零值时写回`LastInsertId`，驱动不支持(eg: postgres)时不处理
+func setInsertId(field reflect.Value, result sql.Result) {
+	if !field.IsZero() {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(id))
+	}
+}
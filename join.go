@@ -0,0 +1,35 @@
+package littleorm
+
+// 内连接，`on`是关联条件，可以带参数，和`Where`一样检查占位符的个数
+// 关联以后字段可能重名，最好用`What`指定带表名的查询字段
+// eg: db.Acquire().Name("user").Join("orders", "orders.user_id = user.id and orders.status = ?", 1).
+//
+//	What([]string{"user.id", "user.name", "orders.amount"}).FindMany(&rows)
+func (ctx *Context) Join(table, on string, args ...interface{}) *Context {
+	return ctx.join("join", table, on, args)
+}
+
+// 左连接，参数同`Join`
+func (ctx *Context) LeftJoin(table, on string, args ...interface{}) *Context {
+	return ctx.join("left join", table, on, args)
+}
+
+// 右连接，参数同`Join`，`SQLite`在3.39以前不支持
+func (ctx *Context) RightJoin(table, on string, args ...interface{}) *Context {
+	return ctx.join("right join", table, on, args)
+}
+
+// 交叉连接，没有关联条件，`table`是带占位符的派生表时可以传参数
+func (ctx *Context) CrossJoin(table string, args ...interface{}) *Context {
+	ctx.checkPlaceholders("join", table, args)
+	ctx.joins = append(ctx.joins, "cross join "+table)
+	ctx.joinArgs = append(ctx.joinArgs, args...)
+	return ctx
+}
+
+func (ctx *Context) join(kind, table, on string, args []interface{}) *Context {
+	ctx.checkPlaceholders("join", table+" "+on, args)
+	ctx.joins = append(ctx.joins, kind+" "+table+" on "+on)
+	ctx.joinArgs = append(ctx.joinArgs, args...)
+	return ctx
+}
@@ -0,0 +1,130 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var ErrLazyNotBound = errors.New("littleorm: lazy relation is not bound, the model was not loaded by littleorm")
+
+// 按需加载的关联数据，放在模型中并用`relation`标签指定关联名，关联需要先注册
+// 查询出来的模型会自动绑定查询用的`DB`，第一次`Load`时才查询，之后返回缓存的结果
+// 模型复制以后共用同一份缓存
+// eg:
+//
+//	type User struct {
+//		Id     int64                  `db:"id"`
+//		Orders littleorm.Lazy[Order] `relation:"orders"`
+//	}
+//
+//	func (u *User) GetOrders(ctx context.Context) ([]Order, error) { return u.Orders.Load(ctx) }
+type Lazy[T any] struct {
+	state *lazyState[T]
+}
+
+type lazyState[T any] struct {
+	mu     sync.Mutex
+	loaded bool
+	items  []T
+	load   func(ctx context.Context, dest interface{}) error
+}
+
+// 加载关联数据，加载失败不会缓存，下次调用会重新加载
+func (l *Lazy[T]) Load(ctx context.Context) ([]T, error) {
+	if l.state == nil {
+		return nil, ErrLazyNotBound
+	}
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	if l.state.loaded {
+		return l.state.items, nil
+	}
+	var items []T
+	if err := l.state.load(ctx, &items); err != nil {
+		return nil, err
+	}
+	l.state.items, l.state.loaded = items, true
+	return items, nil
+}
+
+// 是否已经加载过了
+func (l *Lazy[T]) Loaded() bool {
+	if l.state == nil {
+		return false
+	}
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	return l.state.loaded
+}
+
+// 清掉缓存，下次`Load`重新查询
+func (l *Lazy[T]) Reset() {
+	if l.state == nil {
+		return
+	}
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	l.state.loaded, l.state.items = false, nil
+}
+
+func (l *Lazy[T]) bindLazy(load func(ctx context.Context, dest interface{}) error) {
+	l.state = &lazyState[T]{load: load}
+}
+
+// `Lazy`字段实现的接口，解析模型时用来识别`Lazy`字段
+type lazyBinder interface {
+	bindLazy(load func(ctx context.Context, dest interface{}) error)
+}
+
+var lazyBinderType = reflect.TypeOf((*lazyBinder)(nil)).Elem()
+
+// 给查询结果中的`Lazy`字段绑定加载函数
+func (db *DB) bindLazies(dest interface{}) {
+	// 接口的话要按每一行实际的结构体查找
+	if m := modelOf(dest); m != nil && len(m.lazies) == 0 {
+		return
+	}
+	eachStruct(dest, func(v reflect.Value) {
+		m := lookupModel(v.Type())
+		for i, relation := range m.lazies {
+			field := v.Field(i)
+			if !field.CanAddr() {
+				continue
+			}
+			parent := v
+			field.Addr().Interface().(lazyBinder).bindLazy(func(ctx context.Context, dest interface{}) error {
+				return db.loadRelation(ctx, m, relation, parent, dest)
+			})
+		}
+	})
+}
+
+// 查询父模型`parent`的关联数据
+func (db *DB) loadRelation(ctx context.Context, m *model, relation string, parent reflect.Value, dest interface{}) error {
+	if r, ok := m.hasMany[relation]; ok {
+		id, _ := fieldByTag(parent, r.ParentPK)
+		return db.From(ctx).Name(r.Table).Where(r.ForeignKey+"=?", id.Interface()).FindMany(dest)
+	}
+	r, ok := m.relations[relation]
+	if !ok {
+		return fmt.Errorf("%w: %s has no relation %q", ErrUnknownRelation, parent.Type(), relation)
+	}
+	id, _ := fieldByTag(parent, r.ParentPK)
+	related := modelOf(dest)
+	if related == nil {
+		return fmt.Errorf("%w: relation %q needs a struct type", ErrUnknownRelation, relation)
+	}
+	columns := make([]string, len(related.columns))
+	for i, column := range related.columns {
+		columns[i] = r.Related + "." + column
+	}
+	query := fmt.Sprintf("select %s from %s join %s on %s.%s = %s.%s where %s.%s = ?",
+		sqljoin(columns, SeqComma), r.Related, r.Pivot, r.Pivot, r.RelatedKey, r.Related, r.RelatedPK, r.Pivot, r.ForeignKey)
+	if r.Order != "" {
+		query += " order by " + r.Order
+	}
+	return db.From(ctx).Select(dest, query, id.Interface())
+}
@@ -0,0 +1,91 @@
+package littleorm
+
+import (
+	"context"
+	"strings"
+)
+
+// 生命周期事件
+type HookEvent int
+
+const (
+	BeforeFind HookEvent = iota
+	AfterFind
+	BeforeInsert
+	AfterInsert
+	BeforeUpdate
+	AfterUpdate
+	BeforeDelete
+	AfterDelete
+)
+
+// 生命周期钩子，`stmt`中有表名、语句和参数，查询的结果在`stmt.Dest`中，更新的结果在`stmt.Result`中
+// `err`是语句执行的错误，`Before`钩子中总是`nil`
+// `Before`钩子返回错误的话不执行语句直接返回这个错误，`After`钩子的错误在语句执行成功时返回
+type LifecycleHook func(ctx context.Context, stmt *Statement, err error) error
+
+// 注册生命周期钩子，同一个事件可以注册多个，按注册的顺序执行，只在初始化的时候调用
+// 钩子在所有中间件的外面，按语句的第一个关键字区分事件，`Upsert`算插入，软删除算更新，`Create`、`Drop`这些不触发
+// `After`钩子在`AfterScan`和脱敏之前执行，查询结果缓存命中时不执行
+// eg:
+//
+//	db.On(littleorm.AfterUpdate, func(ctx context.Context, stmt *littleorm.Statement, err error) error {
+//		if err == nil {
+//			audit.Log(stmt.Table, stmt.Query, stmt.Args)
+//		}
+//		return nil
+//	})
+func (db *DB) On(event HookEvent, hook LifecycleHook) *DB {
+	db.lifecycleMu.Lock()
+	defer db.lifecycleMu.Unlock()
+	if db.lifecycle == nil {
+		db.lifecycle = make(map[HookEvent][]LifecycleHook)
+	}
+	db.lifecycle[event] = append(db.lifecycle[event], hook)
+	return db
+}
+
+// 用生命周期钩子包装`exec`
+func (db *DB) lifecycled(exec Executor) Executor {
+	return func(ctx context.Context, stmt *Statement) error {
+		event, ok := lifecycleEvent(stmt)
+		if !ok {
+			return exec(ctx, stmt)
+		}
+		db.lifecycleMu.RLock()
+		before, after := db.lifecycle[event], db.lifecycle[event+1]
+		db.lifecycleMu.RUnlock()
+		for _, hook := range before {
+			if err := hook(ctx, stmt, nil); err != nil {
+				return err
+			}
+		}
+		err := exec(ctx, stmt)
+		for _, hook := range after {
+			if herr := hook(ctx, stmt, err); herr != nil && err == nil {
+				err = herr
+			}
+		}
+		return err
+	}
+}
+
+// 语句对应的`Before`事件，`After`事件是`Before`事件加一
+func lifecycleEvent(stmt *Statement) (HookEvent, bool) {
+	if stmt.prepare {
+		return 0, false
+	}
+	if stmt.IsQuery() {
+		return BeforeFind, true
+	}
+	keyword, _, _ := strings.Cut(strings.TrimSpace(stmt.Query), " ")
+	switch strings.ToLower(keyword) {
+	case "insert", "replace":
+		return BeforeInsert, true
+	case "update":
+		return BeforeUpdate, true
+	case "delete":
+		return BeforeDelete, true
+	}
+	return 0, false
+}
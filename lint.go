@@ -0,0 +1,115 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var ErrLint = errors.New("littleorm: sql lint failed")
+
+const (
+	LintOff    = iota //不检查
+	LintWarn          //检查并打印警告日志
+	LintStrict        //检查不通过直接返回错误，不执行
+)
+
+// `SQL`兼容性问题
+type LintIssue struct {
+	Rule    string
+	Message string
+}
+
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("[%s] %s", issue.Rule, issue.Message)
+}
+
+type lintRule struct {
+	name     string
+	dialects []string //为空表示所有数据库都检查
+	literal  bool     //是否检查字符串字面量中的内容
+	pattern  *regexp.Regexp
+	message  string
+}
+
+var lintRules = []lintRule{
+	{"backtick", []string{"postgres"}, false, regexp.MustCompile("`"), "backtick quoted identifier is mysql only, use double quotes"},
+	{"limit-offset", []string{"postgres", "sqlite3"}, false, regexp.MustCompile(`(?i)\blimit\s+\d+\s*,\s*\d+`), "`limit offset, count` is mysql only, use `limit count offset offset`"},
+	{"lock-share", []string{"postgres", "sqlite3"}, false, regexp.MustCompile(`(?i)\block\s+in\s+share\s+mode\b`), "`lock in share mode` is mysql only, use `for share`"},
+	{"upsert", []string{"postgres", "sqlite3"}, false, regexp.MustCompile(`(?i)\bon\s+duplicate\s+key\s+update\b`), "`on duplicate key update` is mysql only, use `on conflict`"},
+	{"ifnull", []string{"postgres"}, false, regexp.MustCompile(`(?i)\bifnull\s*\(`), "`ifnull` is not supported, use `coalesce`"},
+	{"zero-date", nil, true, regexp.MustCompile(`0000-00-00`), "zero date is rejected by strict sql mode and other databases"},
+}
+
+// 去掉字符串字面量，避免误报
+var literalPattern = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+
+// 检查`SQL`在指定数据库中的兼容性问题，`dialect`是驱动名，eg: mysql, postgres, sqlite3
+// 只是简单的文本匹配，不保证全部能检查出来，主要用来在上线前发现一些常见的兼容问题
+func Lint(dialect, query string) (issues []LintIssue) {
+	stripped := literalPattern.ReplaceAllString(query, "''")
+	for _, rule := range lintRules {
+		if len(rule.dialects) > 0 && !containsString(rule.dialects, lintDialect(dialect)) {
+			continue
+		}
+		target := stripped
+		if rule.literal {
+			target = query
+		}
+		if rule.pattern.MatchString(target) {
+			issues = append(issues, LintIssue{Rule: rule.name, Message: rule.message})
+		}
+	}
+	return
+}
+
+// 设置执行前的检查模式，默认`LintOff`
+func (db *DB) LintMode(mode int) *DB {
+	db.lintMode = mode
+	return db
+}
+
+// 使用当前数据库的驱动检查`SQL`
+func (db *DB) Lint(query string) []LintIssue {
+	return Lint(db.dialect.Name(), query)
+}
+
+// 执行前检查
+func (db *DB) lint(query string) error {
+	if db.lintMode == LintOff {
+		return nil
+	}
+	issues := db.Lint(query)
+	if len(issues) == 0 {
+		return nil
+	}
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = issue.String()
+	}
+	if db.lintMode == LintStrict {
+		return fmt.Errorf("%w: %s", ErrLint, sqljoin(messages, "; "))
+	}
+	db.logInfo("littleorm lint sql", LogField{"sql", query}, LogField{"issues", sqljoin(messages, "; ")})
+	return nil
+}
+
+// 驱动名统一一下
+func lintDialect(driverName string) string {
+	switch driverName {
+	case "pgx", "postgresql":
+		return "postgres"
+	case "sqlite":
+		return "sqlite3"
+	}
+	return driverName
+}
+
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrUnsupportedListener = errors.New("littleorm: listener only supports postgres")
+
+// 重连的间隔，从`minReconnect`开始每次翻倍，最多`maxReconnect`
+const (
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+	// 没有消息时定期检查连接是否还活着
+	listenerPing = 90 * time.Second
+)
+
+// 收到的通知
+type Notification struct {
+	Channel string
+	Payload string
+	PID     int //发送通知的后端进程
+}
+
+// 处理通知，同一个`Listener`的通知按顺序在一个goroutine中处理，处理慢了会阻塞后面的通知
+type NotifyHandler func(n *Notification)
+
+// `postgres`的`LISTEN/NOTIFY`，用一个单独的连接接收通知，断开以后自动重连并重新`LISTEN`
+// 重连期间发送的通知会丢失，需要的话用`OnReconnect`补偿，比如重新加载一次数据
+type Listener struct {
+	listener *pq.Listener
+	db       *DB
+
+	mu          sync.RWMutex
+	handlers    map[string][]NotifyHandler //频道 => 处理函数
+	onReconnect func()
+}
+
+// 创建`Listener`，只支持`postgres`，需要用`Open`打开的`DB`，使用同样的连接配置
+// eg:
+//
+//	l, err := db.NewListener()
+//	err = l.Handle("user_changed", func(n *littleorm.Notification) { cache.Delete(n.Payload) })
+//	go l.Run(ctx)
+func (db *DB) NewListener() (*Listener, error) {
+	if db.dialect.Name() != "postgres" {
+		return nil, fmt.Errorf("%w: dialect %s", ErrUnsupportedListener, db.dialect.Name())
+	}
+	if db.source() == "" {
+		return nil, fmt.Errorf("%w: data source name unknown, open the db with Open", ErrUnsupportedListener)
+	}
+	l := &Listener{db: db, handlers: make(map[string][]NotifyHandler)}
+	l.listener = pq.NewListener(db.source(), minReconnect, maxReconnect, l.event)
+	return l, nil
+}
+
+// 监听频道`channel`，收到通知时调用`fn`，同一个频道可以有多个处理函数
+func (l *Listener) Handle(channel string, fn NotifyHandler) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.handlers[channel]) == 0 {
+		if err := l.listener.Listen(channel); err != nil {
+			return err
+		}
+	}
+	l.handlers[channel] = append(l.handlers[channel], fn)
+	return nil
+}
+
+// 取消监听频道`channel`
+func (l *Listener) Unlisten(channel string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.handlers[channel]; !ok {
+		return nil
+	}
+	delete(l.handlers, channel)
+	return l.listener.Unlisten(channel)
+}
+
+// 重连成功以后调用`fn`，断开期间的通知已经丢失了
+func (l *Listener) OnReconnect(fn func()) *Listener {
+	l.mu.Lock()
+	l.onReconnect = fn
+	l.mu.Unlock()
+	return l
+}
+
+// 接收通知并分发给处理函数，直到`ctx`结束或者`Close`，一般放在单独的goroutine中运行
+func (l *Listener) Run(ctx context.Context) error {
+	ticker := time.NewTicker(listenerPing)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n, ok := <-l.listener.Notify:
+			if !ok {
+				return nil
+			}
+			// 重连以后会收到一个`nil`
+			if n == nil {
+				l.reconnected()
+				continue
+			}
+			l.dispatch(&Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid})
+		case <-ticker.C:
+			if err := l.listener.Ping(); err != nil {
+				l.db.logError("littleorm listener ping failed", LogField{"error", err})
+			}
+		}
+	}
+}
+
+// 关闭连接，`Run`会返回
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+func (l *Listener) dispatch(n *Notification) {
+	l.mu.RLock()
+	handlers := l.handlers[n.Channel]
+	l.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(n)
+	}
+}
+
+func (l *Listener) reconnected() {
+	l.mu.RLock()
+	fn := l.onReconnect
+	l.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+func (l *Listener) event(event pq.ListenerEventType, err error) {
+	if err != nil {
+		l.db.logError("littleorm listener event", LogField{"event", event}, LogField{"error", err})
+	}
+}
+
+// 发送通知，在事务中发送的话提交以后才会送达
+func (ctx *Context) Notify(channel, payload string) error {
+	if err := ctx.inUse(); err != nil {
+		return err
+	}
+	if ctx.db.dialect.Name() != "postgres" {
+		ctx.release()
+		return fmt.Errorf("%w: dialect %s", ErrUnsupportedListener, ctx.db.dialect.Name())
+	}
+	_, err := ctx.Exec("select pg_notify(?, ?)", channel, payload)
+	return err
+}
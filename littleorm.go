@@ -1,13 +1,15 @@
 package littleorm
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -21,11 +23,17 @@ const (
 	SeqSpace    = " "     //空格分隔符
 )
 
+// Deprecated: 内部已经不再使用，保留只是为了兼容
 const (
 	SelectTypeOne = iota
 	SelectTypeMany
 )
 
+var ErrContextReleased = errors.New("littleorm: Context already released, it must not be reused after FindOne/FindMany/Update/Delete/Exec")
+
+// 查询方法，对应`sqlx`的`GetContext`和`SelectContext`
+type selectFunc func(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error
+
 // 用单参数，函数内部调用自行转换类型，否则没办法传递，很烦
 type FuncTx func(tx *sqlx.Tx, args interface{}) error
 
@@ -34,20 +42,103 @@ func Open(driverName, dataSourceName string, timeout time.Duration) (*DB, error)
 	if err != nil {
 		return nil, err
 	}
+	res := newDB(db, timeout)
+	res.dataSourceName = dataSourceName
+	return res, nil
+}
+
+// 使用已有的`*sql.DB`，连接池还是由调用方管理，`driverName`用来区分数据库的语法，和`sql.Open`的驱动名一样
+// 适合已经有自己的连接管理的项目逐步接入，不要调用返回的`DB`的`Close`，否则会把调用方的连接池关掉
+func NewFromDB(db *sql.DB, driverName string, timeout time.Duration) *DB {
+	return newDB(sqlx.NewDb(db, driverName), timeout)
+}
+
+// 使用已有的`*sqlx.DB`，同`NewFromDB`
+func NewFromSqlx(db *sqlx.DB, timeout time.Duration) *DB {
+	return newDB(db, timeout)
+}
+
+func newDB(db *sqlx.DB, timeout time.Duration) *DB {
 	res := &DB{
 		DB:      db,
 		timeout: timeout,
+		dialect: dialectOf(db.DriverName()),
 	}
 	res.pool.New = func() interface{} {
 		return res.allocateContext()
 	}
-	return res, nil
+	return res
 }
 
 type DB struct {
 	*sqlx.DB
-	timeout time.Duration
-	pool    sync.Pool
+	timeout        time.Duration
+	pool           sync.Pool
+	dataSourceName string  //`Open`时的连接配置，`NewListener`需要单独建立连接
+	dialect        Dialect //数据库方言
+
+	maskMu     sync.RWMutex
+	masks      map[string]map[string]MaskFunc //表 => 字段 => 脱敏函数
+	privileged map[string]bool                //特权角色，不做脱敏
+
+	cursorSecret []byte //游标签名密钥
+	scanCeiling  int64  //全表扫描行数上限
+	readOnly     int32  //只读模式，原子操作
+	lintMode     int    //执行前的兼容性检查模式
+	strictGroup  bool   //检查查询字段是否都在分组中
+
+	sqlCache SQLCache      //`SQL`缓存
+	faults   faultInjector //故障注入
+
+	validateMu  sync.RWMutex
+	validators  map[string][]ValidateFunc          //表 => 写入前的校验函数
+	constraints map[string]map[string][]Constraint //表 => 字段 => 约束，见`Constrain`
+
+	identityOff  bool //关闭事务内的缓存
+	inChunkSize  int  //`in`条件拆分执行的上限
+	killOnCancel bool //取消查询时用`KILL QUERY`终止服务端的查询
+
+	middlewares []Middleware //执行语句的中间件
+
+	resultCache ResultCache   //查询结果缓存
+	entityCache ResultCache   //实体缓存
+	entityTTL   time.Duration //实体缓存的时间
+	flights     flightGroup   //防止缓存击穿
+
+	idempotencyTable string //`ExecIdempotent`的去重表
+
+	trackPoolWait bool      //统计等待连接的时间
+	poolWaits     poolWaits //最近的语句等待连接的时间
+
+	hintsMu sync.RWMutex
+	hints   map[string]Hints //查询指纹 => 提示
+
+	mirrorMu sync.RWMutex
+	mirrors  map[string][]MirrorRule //源表 => 冗余字段同步规则
+
+	counterMu sync.RWMutex
+	counters  map[string][]CounterRule //子表 => 计数缓存规则
+
+	routerMu sync.RWMutex
+	routers  map[string]TableRouter //表 => 分表路由
+
+	softMu     sync.Mutex
+	softTables map[string]tableColumns //表 => 表中的字段，见`SoftSchema`
+	softWarned sync.Map                //已经警告过的`表.字段`
+
+	tierMu sync.RWMutex
+	tiers  map[string]time.Duration //超时档位 => 超时时间，见`Tier`
+
+	lifecycleMu sync.RWMutex
+	lifecycle   map[HookEvent][]LifecycleHook //生命周期事件 => 钩子，见`On`
+
+	logger   Logger   //日志，默认不输出
+	logLevel LogLevel //日志级别
+
+	zeroDates ZeroDateMode //零值日期的处理方式，见`ZeroDates`
+
+	swapMu sync.Mutex
+	target atomic.Pointer[target] //`SwapTarget`切换以后的连接池
 }
 
 func (db *DB) allocateContext() *Context {
@@ -55,13 +146,22 @@ func (db *DB) allocateContext() *Context {
 }
 
 // 获取一个`SQL`执行`Context`
+// `Context`不是线程安全的，只能在一个goroutine中使用，执行`FindOne`、`Update`等方法以后会自动放回池子，之后就不能再使用了
 func (db *DB) Acquire() *Context {
 	// 无需加锁，sync.Pool本身是线程安全的
 	ctx := db.pool.Get().(*Context)
 	ctx.reset()
+	atomic.StoreInt32(&ctx.state, contextAcquired)
 	return ctx
 }
 
+// 获取一个使用`ctx`的`SQL`执行`Context`，请求的取消、超时和`WithTags`的标签都会传到查询中，同时还受`DB`的超时时间限制
+// `ctx`中有`Transaction`开启的事务的话使用事务，和`From`一样
+// eg: err := db.AcquireContext(r.Context()).Name("user").Where("id=?", id).FindOne(&user)
+func (db *DB) AcquireContext(ctx context.Context) *Context {
+	return db.From(ctx)
+}
+
 // 获取一个带有事务`tx`的`SQL`执行`Context`
 func (db *DB) AcquireTx(tx *sqlx.Tx) *Context {
 	ctx := db.Acquire()
@@ -76,13 +176,14 @@ func (db *DB) AcquireTx(tx *sqlx.Tx) *Context {
 // 最后，不要搞嵌套事务
 func (db *DB) WithTx(h FuncTx, args interface{}) (err error) {
 	var tx *sqlx.Tx
-	tx, err = db.Beginx()
+	tx, err = db.Pool().Beginx()
 	if err != nil {
 		return
 	}
 	defer func() {
+		// 回滚失败也返回原来的错误，回滚的错误没什么用
 		if err != nil && tx != nil {
-			err = tx.Rollback()
+			_ = tx.Rollback()
 		}
 	}()
 
@@ -96,22 +197,63 @@ func (db *DB) WithTx(h FuncTx, args interface{}) (err error) {
 }
 
 type Context struct {
-	db     *DB
-	tx     *sqlx.Tx //事务
-	sql    string
-	name   string
-	what   []string
-	wheres []string
-	order  string
-	group  string
-	having string
-	limit  int64
-	offset int64
-	args   []interface{}
-	lockX  bool //排他锁
-	lockS  bool //共享锁
+	db      *DB
+	tx      *sqlx.Tx   //事务
+	conn    *sqlx.Conn //固定的连接，临时表之类只在一个连接上可见的场景
+	sql     string
+	name    string
+	what    []string
+	wheres  []string
+	order   string
+	groups  []string
+	havings []condition
+	limit   int64
+	offset  int64
+	args    []interface{} //最终执行时的参数，按`SQL`中子句的顺序拼接好
+	lock    LockOptions   //锁定子句
+	role    string        //调用者角色，用于字段脱敏
+	fields  []string      //`SelectFields`指定的查询字段，查询时根据目标对象解析
+	err     error         //拼接过程中出现的错误，执行时返回
+
+	whatArgs   []interface{} //查询字段的参数
+	whereArgs  []interface{} //`where`条件的参数
+	groupArgs  []interface{} //`group by`表达式的参数
+	havingArgs []interface{} //`having`条件的参数
+	orderArgs  []interface{} //排序的参数
+
+	cursorKeys  []string        //游标分页字段
+	allowScan   bool            //跳过全表扫描检查
+	counts      []string        //`WithCount`统计的关联
+	identity    *identityMap    //事务内的缓存
+	chunk       *inChunk        //需要拆分执行的`in`条件
+	joins       []string        //关联的表
+	joinArgs    []interface{}   //关联表的参数
+	parent      context.Context //调用方的`context.Context`
+	interval    time.Duration   //分批执行时每批之间的休眠时间
+	cacheTTL    time.Duration   //查询结果缓存的时间
+	cacheTables []string        //查询结果缓存涉及的表
+	entity      bool            //`FindByPK`查询，使用实体缓存
+	scope       *txScope        //`From`获取时所在的事务
+	unsafe      bool            //扫描时忽略结构体中没有的字段，见`SoftSchema`
+	routes      []string        //`RouteRange`查询的分表
+	timeout     time.Duration   //`Tier`指定的超时时间，没有指定时用`DB`的超时时间
+	filter      rowFilter       //`FilterRows`的过滤函数
+	conflict    []string        //`OnConflict`指定的唯一键
+	softDelete  string          //软删除字段，见`SoftDelete`
+	unscoped    bool            //忽略软删除
+	coerce      *Coercion       //扫描时的类型转换，见`Coerce`
+	lockRetry   time.Duration   //锁等待超时以后重试的超时时间，见`RetryOnLock`
+	dry         *dryRun         //试运行，见`ToSQL`
+	columns     *[]ColumnMeta   //查询结果的字段信息，见`FindManyMap`
+
+	state int32 //是否在使用中，用来检查重复使用
 }
 
+const (
+	contextIdle int32 = iota
+	contextAcquired
+)
+
 func (ctx *Context) Name(name string) *Context {
 	ctx.name = name
 	return ctx
@@ -121,29 +263,44 @@ func (ctx *Context) Name(name string) *Context {
 // 如果不指定查询字段，默认使用传递的对象中的标签`db`指定的字段，如果没有指定`db`标签则使用`*`代替
 // 使用`*`以后增加数据库字段可能会导致老的查询出错，对兼容性不好，可能是`sqlx`这个库的问题
 func (ctx *Context) What(what []string) *Context {
-	ctx.what = what
+	ctx.what, ctx.whatArgs = what, nil
 	return ctx
 }
 
+// 添加条件，会检查条件中`?`的个数和参数个数是否一致，不一致的话执行时返回`ErrPlaceholderMismatch`
 func (ctx *Context) Where(where string, args ...interface{}) *Context {
+	ctx.checkPlaceholders("where", where, args)
+	return ctx.WhereRaw(where, args...)
+}
+
+// 添加条件，不检查占位符的个数
+// 条件中不是占位符的`?`要写成`??`，执行时替换成一个`?`，比如`postgres`的`data ?? 'key'`，`Where`中也可以这样写
+func (ctx *Context) WhereRaw(where string, args ...interface{}) *Context {
 	ctx.wheres = append(ctx.wheres, where)
-	ctx.args = append(ctx.args, args...)
+	ctx.whereArgs = append(ctx.whereArgs, args...)
 	return ctx
 }
 
 // 指定字段和字段的可取值，自动拼接成 `field in (?,?)` 形式，`args`必须是 `[]interface{}`类型，"严格"的类型系统，蛤...
+// 设置了`DB.InChunkSize`并且值的个数超过上限时，查询、更新和删除会自动拆成多次执行，见`DB.InChunkSize`
 func (ctx *Context) WhereIn(field string, args []interface{}) *Context {
-	n := len(args)
+	if size := ctx.db.inChunkSize; size > 0 && len(args) > size && ctx.chunk == nil {
+		ctx.chunk = &inChunk{where: len(ctx.wheres), arg: len(ctx.whereArgs), field: field, values: args}
+	}
+	return ctx.Where(inWhere(field, len(args)), args...)
+}
+
+// 拼接`field in (?,?)`
+func inWhere(field string, n int) string {
 	places := make([]string, n)
 	for i := 0; i < n; i++ {
 		places[i] = ParamMarker
 	}
-	inWhere := fmt.Sprintf("%s in (%s)", field, sqljoin(places, SeqComma))
-	return ctx.Where(inWhere, args...)
+	return fmt.Sprintf("%s in (%s)", field, sqljoin(places, SeqComma))
 }
 
 func (ctx *Context) Order(order string) *Context {
-	ctx.order = order
+	ctx.order, ctx.orderArgs = order, nil
 	return ctx
 }
 
@@ -157,44 +314,99 @@ func (ctx *Context) Offset(offset int64) *Context {
 	return ctx
 }
 
-func (ctx *Context) Group(group string) *Context {
-	ctx.group = group
+// 分组过滤条件，和`Where`一样会检查占位符的个数
+// 多次调用用`and`连接，需要`or`连接的用`OrHaving`
+func (ctx *Context) Having(having string, args ...interface{}) *Context {
+	ctx.checkPlaceholders("having", having, args)
+	return ctx.HavingRaw(having, args...)
+}
+
+// 分组过滤条件，和前面的条件用`or`连接
+func (ctx *Context) OrHaving(having string, args ...interface{}) *Context {
+	ctx.checkPlaceholders("having", having, args)
+	ctx.havings = append(ctx.havings, condition{sql: having, or: true})
+	ctx.havingArgs = append(ctx.havingArgs, args...)
 	return ctx
 }
 
-func (ctx *Context) Having(having string, args ...interface{}) *Context {
-	ctx.having = having
-	ctx.args = append(ctx.args, args...)
+// 分组过滤条件，不检查占位符的个数
+func (ctx *Context) HavingRaw(having string, args ...interface{}) *Context {
+	ctx.havings = append(ctx.havings, condition{sql: having})
+	ctx.havingArgs = append(ctx.havingArgs, args...)
 	return ctx
 }
 
-// 加排他锁(X锁)，不保证与`LockS`互斥，自己保证
+// 加排他锁(X锁)
+//
+// Deprecated: 使用`LockingClause(LockOptions{Mode: LockUpdate})`
 func (ctx *Context) LockX() *Context {
-	ctx.lockX = true
-	return ctx
+	return ctx.LockingClause(LockOptions{Mode: LockUpdate})
 }
 
-// 加共享锁(S锁)，不保证与`LockX`互斥，自己保证
+// 加共享锁(S锁)
+//
+// Deprecated: 使用`LockingClause(LockOptions{Mode: LockShare})`
 func (ctx *Context) LockS() *Context {
-	ctx.lockS = true
+	return ctx.LockingClause(LockOptions{Mode: LockShare})
+}
+
+// 指定查询使用的`context.Context`，取消或者超时以后查询也会取消，同时还受`DB`的超时时间限制
+func (ctx *Context) WithContext(parent context.Context) *Context {
+	ctx.parent = parent
+	return ctx
+}
+
+// 指定调用者角色，非特权角色查询的结果会按`DB.Mask`的配置进行脱敏
+func (ctx *Context) Role(role string) *Context {
+	ctx.role = role
 	return ctx
 }
 
 // 查询多条记录，参数传入一个数组的指针，eg: &[]Little
 func (ctx *Context) FindMany(dest interface{}) error {
-	return ctx.find(dest, SelectTypeMany)
+	ctx.applyZeroDates()
+	if ctx.filter != nil || ctx.coerce != nil {
+		return ctx.find(dest, ctx.manualSelect(false))
+	}
+	if p := polymorphicOf(dest); p != nil {
+		return ctx.find(dest, p.selectContext)
+	}
+	return ctx.find(dest, sqlx.SelectContext)
 }
 
-// 查询一条记录，参数传入一个对象指针
+// 查询一条记录，参数传入一个对象指针，查不到时返回`ErrNotFound`
 func (ctx *Context) FindOne(dest interface{}) error {
-	return ctx.find(dest, SelectTypeOne)
+	ctx.applyZeroDates()
+	if ctx.filter != nil || ctx.coerce != nil {
+		return ctx.find(dest, ctx.manualSelect(true))
+	}
+	if p := polymorphicOf(dest); p != nil {
+		return ctx.find(dest, p.getContext)
+	}
+	if key, ok := ctx.identityKey(dest); ok {
+		identity, table := ctx.identity, ctx.name
+		if err := ctx.inUse(); err != nil {
+			return err
+		}
+		if identity.load(table, key, dest) {
+			ctx.release()
+			return nil
+		}
+		if err := ctx.find(dest, sqlx.GetContext); err != nil {
+			return err
+		}
+		identity.store(table, key, dest)
+		return nil
+	}
+	return ctx.find(dest, sqlx.GetContext)
 }
 
 // 插入
 func (ctx *Context) Insert(data map[string]interface{}) (sql.Result, error) {
+	ctx.validate(nil, data)
 	var (
-		fields []string
-		params []interface{}
+		fields = make([]string, 0, len(data))
+		params = make([]interface{}, 0, len(data))
 	)
 	for k, v := range data {
 		fields = append(fields, k)
@@ -205,9 +417,16 @@ func (ctx *Context) Insert(data map[string]interface{}) (sql.Result, error) {
 
 // 批量插入
 func (ctx *Context) InsertBatch(fields []string, data ...[]interface{}) (sql.Result, error) {
+	if rules := ctx.db.countersOf(ctx.name); len(rules) > 0 {
+		return ctx.insertCounted(fields, data, rules)
+	}
+	return ctx.insertBatch(fields, data...)
+}
+
+func (ctx *Context) insertBatch(fields []string, data ...[]interface{}) (sql.Result, error) {
 	var (
-		params []interface{}
-		values []string
+		params = make([]interface{}, 0, len(fields)*len(data))
+		values = make([]string, 0, len(data))
 	)
 	for _, item := range data {
 		places := make([]string, len(item))
@@ -224,9 +443,17 @@ func (ctx *Context) InsertBatch(fields []string, data ...[]interface{}) (sql.Res
 
 // 使用map更新
 func (ctx *Context) UpdateMap(args map[string]interface{}) (rowsAffected int64, err error) {
+	ctx.validate(nil, args)
+	if rules := ctx.db.mirrorsOf(ctx.name, args); len(rules) > 0 {
+		return ctx.updateMirrored(args, rules)
+	}
+	return ctx.updateMap(args)
+}
+
+func (ctx *Context) updateMap(args map[string]interface{}) (rowsAffected int64, err error) {
 	var (
-		params []interface{}
-		sets   []string
+		params = make([]interface{}, 0, len(args))
+		sets   = make([]string, 0, len(args))
 	)
 	for k, v := range args {
 		params = append(params, v)
@@ -240,9 +467,16 @@ func (ctx *Context) UpdateMap(args map[string]interface{}) (rowsAffected int64,
 // 更新
 func (ctx *Context) Update(sqlset string, args ...interface{}) (rowsAffected int64, err error) {
 	template := "update %s set %s %s"
-	where := sqlwhere(ctx.wheres, Grouping)
+	if ctx.chunk != nil {
+		return ctx.execChunks(func() (string, []interface{}) {
+			query := fmt.Sprintf(template, ctx.name, sqlset, sqlwhere(ctx.scopedWheres(nil), Grouping))
+			return query, append(append([]interface{}(nil), args...), ctx.whereArgs...)
+		})
+	}
+	where := sqlwhere(ctx.scopedWheres(nil), Grouping)
 	query := fmt.Sprintf(template, ctx.name, sqlset, where)
-	params := append(args, ctx.args...)
+	params := make([]interface{}, 0, len(args)+len(ctx.whereArgs))
+	params = append(append(params, args...), ctx.whereArgs...)
 	var result sql.Result
 	result, err = ctx.exec(query, params...)
 	if err != nil {
@@ -253,13 +487,29 @@ func (ctx *Context) Update(sqlset string, args ...interface{}) (rowsAffected int
 }
 
 // 删除
+// 开启了软删除时只记录删除时间，见`SoftDelete`
 func (ctx *Context) Delete() (rowsAffected int64, err error) {
+	if column := ctx.softColumn(nil); column != "" {
+		return ctx.Update(column + "=current_timestamp")
+	}
+	if rules := ctx.db.countersOf(ctx.name); len(rules) > 0 {
+		return ctx.deleteCounted(rules)
+	}
+	return ctx.delete()
+}
+
+func (ctx *Context) delete() (rowsAffected int64, err error) {
 	template := "delete from %s %s"
+	if ctx.chunk != nil {
+		return ctx.execChunks(func() (string, []interface{}) {
+			return fmt.Sprintf(template, ctx.name, sqlwhere(ctx.wheres, Grouping)), ctx.whereArgs
+		})
+	}
 	where := sqlwhere(ctx.wheres, Grouping)
 
 	query := fmt.Sprintf(template, ctx.name, where)
 	var result sql.Result
-	result, err = ctx.exec(query, ctx.args...)
+	result, err = ctx.exec(query, ctx.whereArgs...)
 	if err != nil {
 		return
 	}
@@ -270,15 +520,15 @@ func (ctx *Context) Delete() (rowsAffected int64, err error) {
 // 查询多条记录，直接使用给定的`sql`和`args`
 func (ctx *Context) Select(dest interface{}, sql string, args ...interface{}) error {
 	ctx.sql = sql
-	ctx.args = args
-	return ctx.find(dest, SelectTypeMany)
+	ctx.args = append(ctx.args[:0], args...)
+	return ctx.find(dest, sqlx.SelectContext)
 }
 
-// 查询单条记录，直接使用给定的`sql`和`args`
+// 查询单条记录，直接使用给定的`sql`和`args`，查不到时返回`ErrNotFound`
 func (ctx *Context) Get(dest interface{}, sql string, args ...interface{}) error {
 	ctx.sql = sql
-	ctx.args = args
-	return ctx.find(dest, SelectTypeOne)
+	ctx.args = append(ctx.args[:0], args...)
+	return ctx.find(dest, sqlx.GetContext)
 }
 
 // 直接执行操作，直接使用给定的`sql`和`args`
@@ -298,111 +548,431 @@ func (ctx *Context) Drop() (sql.Result, error) {
 
 /////////////////////////private methods//////////////////////
 
+// 执行前检查`Context`是否已经放回池子了
+func (ctx *Context) inUse() error {
+	if atomic.LoadInt32(&ctx.state) != contextAcquired {
+		return ErrContextReleased
+	}
+	return nil
+}
+
+// 放回池子，只有第一次会放回去，避免同一个`Context`被两个goroutine同时拿到
+func (ctx *Context) release() {
+	if atomic.CompareAndSwapInt32(&ctx.state, contextAcquired, contextIdle) {
+		ctx.db.pool.Put(ctx)
+	}
+}
+
 // 重置Context
 func (ctx *Context) reset() *Context {
 	ctx.sql = ""
 	ctx.name = ""
-	ctx.what = []string{}
-	ctx.wheres = []string{}
+	ctx.what = nil
+	ctx.whatArgs = reuseArgs(ctx.whatArgs)
+	ctx.wheres = reuseStrings(ctx.wheres)
 	ctx.order = ""
-	ctx.group = ""
-	ctx.having = ""
+	ctx.orderArgs = reuseArgs(ctx.orderArgs)
+	ctx.groups = reuseStrings(ctx.groups)
+	ctx.havings = ctx.havings[:0]
 	ctx.limit = 0
 	ctx.offset = 0
-	ctx.args = []interface{}{}
+	ctx.args = reuseArgs(ctx.args)
+	ctx.whereArgs = reuseArgs(ctx.whereArgs)
+	ctx.groupArgs = reuseArgs(ctx.groupArgs)
+	ctx.havingArgs = reuseArgs(ctx.havingArgs)
 	ctx.tx = nil
-	ctx.lockS = false
-	ctx.lockX = false
+	ctx.conn = nil
+	ctx.lock = LockOptions{}
+	ctx.role = ""
+	ctx.fields = nil
+	ctx.err = nil
+	ctx.cursorKeys = nil
+	ctx.allowScan = false
+	ctx.counts = nil
+	ctx.identity = nil
+	ctx.chunk = nil
+	ctx.joins = reuseStrings(ctx.joins)
+	ctx.joinArgs = reuseArgs(ctx.joinArgs)
+	ctx.parent = nil
+	ctx.interval = 0
+	ctx.cacheTTL, ctx.cacheTables = 0, nil
+	ctx.entity = false
+	ctx.scope = nil
+	ctx.unsafe = false
+	ctx.routes = nil
+	ctx.timeout = 0
+	ctx.filter = nil
+	ctx.conflict = nil
+	ctx.softDelete, ctx.unscoped = "", false
+	ctx.coerce = nil
+	ctx.lockRetry = 0
+	ctx.dry = nil
+	ctx.columns = nil
 	return ctx
 }
 
 // 查询方法
-func (ctx *Context) find(dest interface{}, selectType int) (err error) {
-	defer ctx.db.pool.Put(ctx)
-	ttx, cancel := context.WithTimeout(context.Background(), ctx.db.timeout)
+func (ctx *Context) find(dest interface{}, fn selectFunc) (err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	defer ctx.release()
+	defer func() { err = notFound(err) }()
+	if ctx.err != nil {
+		return ctx.err
+	}
+	if len(ctx.routes) > 0 {
+		return ctx.findRoutes(dest, fn)
+	}
+	if ctx.chunkable(dest) {
+		return ctx.findChunks(dest, fn)
+	}
+	built := ctx.sql == ""
+	if err = ctx.query(dest, fn); ctx.retryLocked(err, built) {
+		err = ctx.query(dest, fn)
+	}
+	return
+}
+
+// 执行一次查询，`Context`的检查和放回池子由调用方处理
+func (ctx *Context) query(dest interface{}, fn selectFunc) (err error) {
+	ttx, cancel := ctx.context()
 	defer cancel()
-	if ctx.sql == "" {
-		ctx.sql = ctx.sqlselect(dest)
+	if err = ctx.prepare(ttx, dest); err != nil {
+		return
 	}
-	switch selectType {
-	case SelectTypeOne:
-		if ctx.tx != nil {
-			err = ctx.tx.GetContext(ttx, dest, ctx.sql, ctx.args...)
-		} else {
-			err = ctx.db.GetContext(ttx, dest, ctx.sql, ctx.args...)
+	if err = ctx.dryRun(ctx.sql, ctx.args); err != nil {
+		return
+	}
+	var faked bool
+	stmt := &Statement{Query: ctx.sql, Args: ctx.args, Table: ctx.name, Dest: dest}
+	run := ctx.db.chain(func(ttx context.Context, stmt *Statement) (err error) {
+		if faked, err = ctx.db.injectFault(ttx, stmt.Query, stmt.Args, stmt.Dest); faked {
+			return
 		}
-	case SelectTypeMany:
-		if ctx.tx != nil {
-			err = ctx.tx.SelectContext(ttx, dest, ctx.sql, ctx.args...)
-		} else {
-			err = ctx.db.SelectContext(ttx, dest, ctx.sql, ctx.args...)
+		unpin, err := ctx.pinConn(ttx, stmt)
+		if err != nil {
+			return
+		}
+		defer unpin()
+		stop, err := ctx.watchCancel(ttx)
+		if err != nil {
+			return
+		}
+		defer stop()
+		return fn(ttx, ctx.queryer(), stmt.Dest, stmt.Query, stmt.Args...)
+	})
+	cacheKey, cacheTags, cached := ctx.resultCacheKey(dest)
+	if !cached {
+		err = run(ttx, stmt)
+	} else if ctx.loadResult(ttx, cacheKey, dest) {
+		return ctx.afterQuery(stmt, dest)
+	} else {
+		// 同时没有命中缓存的查询只执行一次，其他的等待共用结果
+		data, leader, ferr := ctx.db.flights.do(cacheKey, func() ([]byte, error) {
+			if err := run(ttx, stmt); err != nil || faked {
+				return nil, err
+			}
+			return ctx.storeResult(ttx, cacheKey, cacheTags, dest), nil
+		})
+		switch {
+		case leader || ferr != nil:
+			err = ferr
+		case data == nil || !ctx.decodeResult(data, dest):
+			err = run(ttx, stmt)
+		}
+	}
+	if err != nil {
+		return
+	}
+	if faked {
+		ctx.mask(dest)
+		return
+	}
+	return ctx.afterQuery(stmt, dest)
+}
+
+// 查询返回结果集，由调用方逐行扫描，`done`关闭结果集并放回`TrackPoolWait`取出的连接
+// 和`query`一样经过中间件、生命周期钩子和故障注入，`stmt.Dest`是`*(*sqlx.Rows)`
+// `Context`的检查和放回池子由调用方处理
+func (ctx *Context) queryRows(ttx context.Context, query string, args []interface{}) (rows *sqlx.Rows, done func(), err error) {
+	done = func() {}
+	stmt := &Statement{Query: query, Args: args, Table: ctx.name, Dest: &rows}
+	err = ctx.db.chain(func(ttx context.Context, stmt *Statement) error {
+		if handled, err := ctx.db.injectFault(ttx, stmt.Query, stmt.Args, nil); handled {
+			return err
+		}
+		conn, err := ctx.waitConn(ttx, stmt)
+		if err != nil {
+			return err
+		}
+		q, release := ctx.queryer(), releaseOnDone(ttx, conn)
+		if conn != nil {
+			q = ctx.db.bound(conn)
+		}
+		if rows, err = q.QueryxContext(ttx, stmt.Query, stmt.Args...); err != nil {
+			release()
+			return err
+		}
+		done = func() {
+			rows.Close()
+			release()
 		}
-	default:
-		panic("select type err")
+		return nil
+	})(ttx, stmt)
+	if err == nil && rows == nil {
+		err = fmt.Errorf("littleorm: no rows returned by middlewares for %s", query)
 	}
 	return
 }
 
+// 查询以后的处理，绑定延迟加载的关联、执行钩子、脱敏
+func (ctx *Context) afterQuery(stmt *Statement, dest interface{}) (err error) {
+	ctx.db.bindLazies(dest)
+	// 自己扫描的查询在扫描时已经逐行执行过钩子，见`manualSelect`
+	if ctx.filter == nil && ctx.coerce == nil {
+		if err = runAfterScan(dest); err != nil {
+			return
+		}
+	}
+	ctx.db.snapshotResult(stmt.Query, stmt.Args, dest)
+	ctx.mask(dest)
+	return
+}
+
+// 拼接查询语句并做执行前的检查
+func (ctx *Context) prepare(ttx context.Context, dest interface{}) (err error) {
+	if m := modelOf(dest); m != nil && m.soft {
+		ctx.unsafe = true
+	}
+	if ctx.sql == "" {
+		if err = ctx.resolveFields(dest); err != nil {
+			return
+		}
+		if err = ctx.resolveSoftSchema(ttx, dest); err != nil {
+			return
+		}
+		if err = ctx.resolveCounts(dest); err != nil {
+			return
+		}
+		ctx.args = ctx.selectArgs()
+		if err = ctx.checkGroupBy(dest); err != nil {
+			return
+		}
+		ctx.sql = ctx.sqlselect(dest)
+	}
+	if err = ctx.db.lint(ctx.sql); err != nil {
+		return
+	}
+	return ctx.guard(ttx, ctx.queryer())
+}
+
+// 查询的参数，按照`SQL`中子句的顺序拼接，不依赖`Where`和`Having`的调用顺序
+func (ctx *Context) selectArgs() []interface{} {
+	args := append(append(ctx.args[:0], ctx.whatArgs...), ctx.joinArgs...)
+	args = append(append(append(args, ctx.whereArgs...), ctx.groupArgs...), ctx.havingArgs...)
+	return append(args, ctx.orderArgs...)
+}
+
 // update,insert,delete方法
 func (ctx *Context) exec(query string, args ...interface{}) (sql.Result, error) {
-	log.Printf("littleorm exec sql: <%s>, args: %#v", query, args)
-	defer ctx.db.pool.Put(ctx)
-	ttx, cancel := context.WithTimeout(context.Background(), ctx.db.timeout)
+	ctx.db.logDebug("littleorm exec sql", sqlFields(query, args)...)
+	if err := ctx.inUse(); err != nil {
+		return nil, err
+	}
+	defer ctx.release()
+	if ctx.err != nil {
+		return nil, ctx.err
+	}
+	result, err := ctx.execute(query, args...)
+	if ctx.retryLocked(err, false) {
+		result, err = ctx.execute(query, args...)
+	}
+	return result, err
+}
+
+// 执行一次更新，`Context`的检查和放回池子由调用方处理
+func (ctx *Context) execute(query string, args ...interface{}) (sql.Result, error) {
+	if ctx.db.IsReadOnly() {
+		return nil, ErrReadOnly
+	}
+	if len(ctx.routes) > 0 {
+		return nil, fmt.Errorf("%w: RouteRange is only for queries, use Route to write", ErrTableRoute)
+	}
+	if ctx.identity != nil {
+		// 直接执行的`SQL`不知道改了哪张表，清掉所有缓存
+		ctx.identity.invalidate(ctx.name)
+	}
+	if err := ctx.db.lint(query); err != nil {
+		return nil, err
+	}
+	args = ctx.db.zeroDateArgs(args)
+	if err := ctx.dryRun(query, args); err != nil {
+		return nil, err
+	}
+	ttx, cancel := ctx.context()
 	defer cancel()
+	stmt := &Statement{Query: query, Args: args, Table: ctx.name}
+	err := ctx.db.chain(func(ttx context.Context, stmt *Statement) (err error) {
+		if handled, err := ctx.db.injectFault(ttx, stmt.Query, stmt.Args, nil); handled {
+			return err
+		}
+		unpin, err := ctx.pinConn(ttx, stmt)
+		if err != nil {
+			return
+		}
+		defer unpin()
+		stop, err := ctx.watchCancel(ttx)
+		if err != nil {
+			return
+		}
+		defer stop()
+		stmt.Result, err = ctx.execer().ExecContext(ttx, stmt.Query, stmt.Args...)
+		return
+	})(ttx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	ctx.invalidateResults(ttx, query)
+	return stmt.Result, nil
+}
 
-	var ec sqlx.ExecerContext
-	if ctx.tx != nil {
-		ec = ctx.tx
-	} else {
-		ec = ctx.db
+// 执行时使用的`context.Context`，在调用方的`context.Context`基础上加上超时时间
+func (ctx *Context) context() (context.Context, context.CancelFunc) {
+	parent := ctx.parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	timeout := ctx.db.timeout
+	if ctx.timeout > 0 {
+		timeout = ctx.timeout
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// 查询使用的连接，有事务用事务，固定了连接用固定的连接
+// 占位符按方言替换
+func (ctx *Context) queryer() sqlx.QueryerContext {
+	return ctx.execer()
+}
+
+// 更新使用的连接，和查询一样
+func (ctx *Context) execer() boundConn {
+	switch {
+	case ctx.tx != nil && ctx.unsafe:
+		return ctx.db.bound(ctx.tx.Unsafe())
+	case ctx.tx != nil:
+		return ctx.db.bound(ctx.tx)
+	case ctx.conn != nil:
+		return ctx.db.bound(ctx.conn)
+	case ctx.unsafe:
+		return ctx.db.bound(ctx.db.Pool().Unsafe())
 	}
-	return ec.ExecContext(ttx, query, args...)
+	return ctx.db.bound(ctx.db.Pool())
 }
 
-// select查询语句的拼接
+// `Context`放回池子以后复用`wheres`和`args`的底层数组，超过这个容量的就丢掉，避免大数组一直被占着
+const maxPooledSlice = 64
+
+// 复用字符串数组
+func reuseStrings(items []string) []string {
+	if cap(items) > maxPooledSlice {
+		return nil
+	}
+	return items[:0]
+}
+
+// 复用参数数组，需要先清空里面的元素，否则参数引用的对象没办法被回收
+func reuseArgs(args []interface{}) []interface{} {
+	if cap(args) > maxPooledSlice {
+		return nil
+	}
+	for i := range args {
+		args[i] = nil
+	}
+	return args[:0]
+}
+
+// 拼接`SQL`用的缓冲区，复用一下减少内存分配
+// 用`bytes.Buffer`而不是`strings.Builder`，因为`strings.Builder`调用`String()`以后底层数组就不能复用了
+var sqlBufferPool = sync.Pool{
+	New: func() interface{} {
+		return bytes.NewBuffer(make([]byte, 0, 256))
+	},
+}
+
+// 超过这个大小的缓冲区不放回池子，避免一直占着大块内存
+const maxPooledBuffer = 64 << 10
+
+// select查询语句的拼接，开启了`SQL`缓存的话先查缓存
 func (ctx *Context) sqlselect(dest interface{}) string {
-	var sqlArray []string
-	sqlArray = append(sqlArray, "select")
+	if ctx.db.sqlCache == nil {
+		return ctx.buildselect(dest)
+	}
+	key := ctx.shapeKey(dest)
+	sql, ok := ctx.db.sqlCache.Get(key)
+	if ok {
+		ctx.db.logDebug("littleorm sql", sqlFields(sql, ctx.args)...)
+		return sql
+	}
+	sql = ctx.buildselect(dest)
+	ctx.db.sqlCache.Set(key, sql)
+	return sql
+}
+
+// 拼接select查询语句
+func (ctx *Context) buildselect(dest interface{}) string {
+	buf := sqlBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			sqlBufferPool.Put(buf)
+		}
+	}()
+
+	buf.WriteString("select ")
 	if len(ctx.what) != 0 {
-		sqlArray = append(sqlArray, sqljoin(ctx.what, SeqComma))
+		writeJoin(buf, ctx.what, SeqComma)
 	} else {
 		// 如果不指定字段，取出目标对象的 tag 中的 db 全部填充了，
 		// 不使用 * 来填充是因为 sqlx 解析时候如果对象中不包含数据库中全部字段会出现映射错误，会让以后增加数据库字段时候不兼容
-		whatFields := decodetags(dest)
-		if len(whatFields) > 0 {
-			sqlArray = append(sqlArray, sqljoin(whatFields, SeqComma))
+		// 模型的字段解析一次以后就缓存了，不用每次都反射
+		if m := modelOf(dest); m != nil && len(m.columns) > 0 {
+			buf.WriteString(m.selects)
 		} else {
-			sqlArray = append(sqlArray, "*")
+			buf.WriteString("*")
 		}
 	}
-	sqlArray = append(sqlArray, "from "+ctx.name)
-	if len(ctx.wheres) != 0 {
-		sqlArray = append(sqlArray, sqlwhere(ctx.wheres, Grouping))
+	buf.WriteString(" from ")
+	buf.WriteString(ctx.name)
+	for _, join := range ctx.joins {
+		buf.WriteByte(' ')
+		buf.WriteString(join)
+	}
+	if wheres := ctx.scopedWheres(dest); len(wheres) != 0 {
+		buf.WriteString(" where ")
+		writeJoin(buf, wheres, Grouping)
 	}
 
-	if ctx.group != "" {
-		sqlArray = append(sqlArray, "group by "+ctx.group)
+	if len(ctx.groups) != 0 {
+		buf.WriteString(" group by ")
+		writeJoin(buf, ctx.groups, SeqComma)
 	}
 
-	if ctx.having != "" {
-		sqlArray = append(sqlArray, "having "+ctx.having)
+	if len(ctx.havings) != 0 {
+		buf.WriteString(" having ")
+		writeConditions(buf, ctx.havings)
 	}
 
 	if ctx.order != "" {
-		sqlArray = append(sqlArray, "order by "+ctx.order)
+		buf.WriteString(" order by ")
+		buf.WriteString(ctx.order)
 	}
 
-	if ctx.limit != 0 {
-		sqlArray = append(sqlArray, fmt.Sprintf("limit %d, %d", ctx.offset, ctx.limit))
-	}
-	if ctx.lockS {
-		sqlArray = append(sqlArray, "lock in share mode")
-	}
-	if ctx.lockX {
-		sqlArray = append(sqlArray, "for update")
-	}
-	sql := sqljoin(sqlArray, SeqSpace)
-	log.Printf("littleorm sql: <%v>, args: %#v", sql, ctx.args)
+	// 分页和锁的语法各个数据库不一样，顺序也有要求，统一放在一起处理
+	ctx.db.writeLimitLock(buf, ctx.offset, ctx.limit, ctx.lock)
+	sql := buf.String()
+	ctx.db.logDebug("littleorm sql", sqlFields(sql, ctx.args)...)
 	return sql
 }
 
@@ -421,22 +991,90 @@ func sqljoin(args []string, seq string) string {
 	return strings.Join(args, seq)
 }
 
-// 解析对象中的 `db tag`
-// 参数只能指针，单个对象或者数组，eg: &little, &[]Little
-func decodetags(dest interface{}) (fields []string) {
-	value := reflect.ValueOf(dest)
-	slice := value.Type().Elem()
-	var base reflect.Type
-	if slice.Kind() == reflect.Slice {
-		base = slice.Elem()
-	} else {
-		base = slice
+// 条件片段，`or`表示和前面的条件用`or`连接，否则用`and`连接
+type condition struct {
+	sql string
+	or  bool
+}
+
+// 拼接条件，多个条件时每个条件都加上括号，避免条件中的`or`和外面的`and`优先级混在一起
+func writeConditions(buf *bytes.Buffer, conditions []condition) {
+	if len(conditions) == 1 {
+		buf.WriteString(conditions[0].sql)
+		return
 	}
-	for i := 0; i < base.NumField(); i++ {
-		dbTag := base.Field(i).Tag.Get(DBTag)
-		if dbTag != "" {
-			fields = append(fields, dbTag)
+	for i, cond := range conditions {
+		if i > 0 {
+			if cond.or {
+				buf.WriteString(" or ")
+			} else {
+				buf.WriteString(Grouping)
+			}
 		}
+		buf.WriteByte('(')
+		buf.WriteString(cond.sql)
+		buf.WriteByte(')')
+	}
+}
+
+// 拼接数组字符串，直接写到缓冲区中
+func writeJoin(buf *bytes.Buffer, args []string, seq string) {
+	for i, arg := range args {
+		if i > 0 {
+			buf.WriteString(seq)
+		}
+		buf.WriteString(arg)
+	}
+}
+
+// 取出目标对象对应的结构体类型，不是结构体返回`nil`
+// 参数只能指针，单个对象或者数组，eg: &little, &[]Little, &[]*Little
+func structType(dest interface{}) reflect.Type {
+	if dest == nil {
+		return nil
+	}
+	base := reflect.TypeOf(dest)
+	for base.Kind() == reflect.Ptr || base.Kind() == reflect.Slice {
+		base = base.Elem()
+	}
+	if base.Kind() != reflect.Struct {
+		return nil
+	}
+	return base
+}
+
+// 遍历目标对象中的结构体，参数同`structType`，eg: &little, &[]Little, &[]*Little
+// 单表继承的接口也会展开，eg: &animal, &[]Animal，接口中是结构体值的话改完以后写回接口
+func eachStruct(dest interface{}, fn func(v reflect.Value)) {
+	value := reflect.Indirect(reflect.ValueOf(dest))
+	switch value.Kind() {
+	case reflect.Struct, reflect.Interface:
+		eachItem(value, fn)
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			eachItem(value.Index(i), fn)
+		}
+	}
+}
+
+func eachItem(item reflect.Value, fn func(v reflect.Value)) {
+	if item.Kind() != reflect.Interface {
+		if item = reflect.Indirect(item); item.Kind() == reflect.Struct {
+			fn(item)
+		}
+		return
+	}
+	if item.IsNil() {
+		return
+	}
+	elem := item.Elem()
+	switch {
+	case elem.Kind() == reflect.Ptr && elem.Elem().Kind() == reflect.Struct:
+		fn(elem.Elem())
+	case elem.Kind() == reflect.Struct && item.CanSet():
+		copied := reflect.New(elem.Type()).Elem()
+		copied.Set(elem)
+		fn(copied)
+		item.Set(copied)
 	}
-	return
 }
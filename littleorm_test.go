@@ -1,19 +1,31 @@
 package littleorm
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
 var (
-	host     = "http://127.0.0.1"
+	host     = "127.0.0.1"
 	port     = 62894
 	user     = "root"
 	password = "123"
@@ -36,15 +48,18 @@ type LittleOrm struct {
 	UpdatedAt time.Time `db:"updated_at"`
 }
 
-func init() {
-	dataSourceName := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&loc=%s&parseTime=true", user, password, host, port, dbname, "Asia%2FShanghai")
-	var err error
-	db, err = Open("mysql", dataSourceName, 10*time.Second)
-	if err != nil {
-		fmt.Printf("open conn err: %v", err)
-	}
+// 默认使用内存中的`SQLite`跑测试，设置环境变量`LITTLEORM_TEST_DRIVER=mysql`使用`MySQL`
+var driver = os.Getenv("LITTLEORM_TEST_DRIVER")
 
-	sql := `CREATE TABLE little_orm (
+func init() {
+	var (
+		sql string
+		err error
+	)
+	if driver == "mysql" {
+		dataSourceName := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&loc=%s&parseTime=true", user, password, host, port, dbname, "Asia%2FShanghai")
+		db, err = Open("mysql", dataSourceName, 10*time.Second)
+		sql = `CREATE TABLE little_orm (
 		id int(11) unsigned NOT NULL AUTO_INCREMENT,
 		name varchar(32) NOT NULL DEFAULT '',
 		age int(11) NOT NULL,
@@ -52,6 +67,24 @@ func init() {
 		updated_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 		PRIMARY KEY (id)
 	  ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	} else {
+		driver = "sqlite3"
+		// 内存数据库每个连接都是独立的，只能用一个连接
+		db, err = Open("sqlite3", "file:littleorm?mode=memory&cache=shared", 10*time.Second)
+		if err == nil {
+			db.SetMaxOpenConns(1)
+		}
+		sql = `CREATE TABLE little_orm (
+		id integer PRIMARY KEY AUTOINCREMENT,
+		name varchar(32) NOT NULL DEFAULT '',
+		age int NOT NULL,
+		created_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+	  );`
+	}
+	if err != nil {
+		log.Fatalf("open conn err: %v", err)
+	}
 
 	_, err = db.Acquire().Name(tablename).Drop()
 	if err != nil {
@@ -63,6 +96,13 @@ func init() {
 	}
 }
 
+// 只能在`MySQL`上跑的测试，比如加锁、`EXPLAIN`
+func onlyMySQL(t testing.TB) {
+	if driver != "mysql" {
+		t.Skipf("%s only runs on mysql", t.Name())
+	}
+}
+
 func TestInsert(t *testing.T) {
 	data := map[string]interface{}{
 		"name": name,
@@ -167,7 +207,7 @@ func TestGroupHaving(t *testing.T) {
 		ages []int8
 		err  error
 	)
-	err = db.Acquire().Name(tablename).What([]string{"sum(age) as age"}).Group("name").Having("age > ?", age).FindMany(&ages)
+	err = db.Acquire().Name(tablename).What([]string{"sum(age) as age"}).Group("name").Having("sum(age) > ?", age).FindMany(&ages)
 	assert.Equal(t, nil, err)
 	assert.EqualValues(t, 2, len(ages))
 }
@@ -245,3 +285,3164 @@ func updateAge(tx *sqlx.Tx, age interface{}) error {
 	}
 	return nil
 }
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "a****@gmail.com", MaskEmail("allen@gmail.com"))
+	assert.Equal(t, "138****5678", MaskPhone("13812345678"))
+	assert.Equal(t, "a***n", MaskMiddle("allen"))
+
+	// 共用连接池，脱敏配置不影响其他测试
+	d := newDB(db.Pool(), time.Second)
+	d.Mask(tablename, "name", MaskMiddle).PrivilegedRoles("admin")
+	var (
+		little LittleOrm
+		err    error
+	)
+	err = d.Acquire().Name(tablename).Where("id=?", 1).Role("guest").FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "a***n", little.Name)
+
+	err = d.Acquire().Name(tablename).Where("id=?", 1).Role("admin").FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, name, little.Name)
+
+	// 没有指定角色的按非特权角色处理
+	err = d.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "a***n", little.Name)
+
+	// `Queryx`不脱敏
+	rows, err := d.Acquire().Queryx("select name from "+tablename+" where id=?", 1)
+	assert.Equal(t, nil, err)
+	defer rows.Close()
+	assert.True(t, rows.Next())
+	var raw string
+	assert.Equal(t, nil, rows.Scan(&raw))
+	assert.Equal(t, name, raw)
+}
+
+func TestSelectFields(t *testing.T) {
+	var (
+		little LittleOrm
+		err    error
+	)
+	err = db.Acquire().Name(tablename).SelectFields([]string{"id", "name"}).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, little.Id)
+	assert.EqualValues(t, 0, little.Age)
+
+	err = db.Acquire().Name(tablename).SelectFields([]string{"id", "password"}).FindOne(&little)
+	assert.True(t, errors.Is(err, ErrUnknownField))
+}
+
+func TestFilter(t *testing.T) {
+	allowed := map[string]string{"age": "age", "name": "name"}
+	wheres, args, err := ParseFilter("age>=18 AND name~allen", allowed)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"age >= ?", "name like ?"}, wheres)
+	assert.Equal(t, []interface{}{"18", "%allen%"}, args)
+
+	_, _, err = ParseFilter("password=123", allowed)
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+
+	var littles []LittleOrm
+	err = db.Acquire().Name(tablename).Filter(`name~allen and age>=18`, allowed).FindMany(&littles)
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, 0, len(littles))
+
+	// sqlite没有默认的转义字符，要带上`escape`子句通配符才会按原样匹配
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/filter.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_like (name varchar(10))")
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Name("little_like").InsertBatch([]string{"name"}, []interface{}{"a_c"}, []interface{}{"abc"}, []interface{}{"50%"}, []interface{}{"500"})
+	assert.Equal(t, nil, err)
+	var names []string
+	err = d.Acquire().Name("little_like").What([]string{"name"}).Filter(`name~a_c`, allowed).FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"a_c"}, names)
+	names = nil
+	err = d.Acquire().Name("little_like").What([]string{"name"}).Filter(`name~"0%"`, allowed).FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"50%"}, names)
+}
+
+func TestOrderFromParams(t *testing.T) {
+	allowed := map[string]string{"id": "id", "created_at": "created_at", "name": "name"}
+	order, err := OrderFromParams("?-created_at,+name, id", allowed)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "created_at desc, name asc, id asc", order)
+
+	_, err = OrderFromParams("id;drop table little_orm", allowed)
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+
+	var littles []LittleOrm
+	err = db.Acquire().Name(tablename).OrderParams("-id", allowed).FindMany(&littles)
+	assert.Equal(t, nil, err)
+	assert.True(t, littles[0].Id > littles[len(littles)-1].Id)
+}
+
+func TestCursorPaginate(t *testing.T) {
+	var (
+		littles []LittleOrm
+		err     error
+	)
+	next, prev, err := db.Acquire().Name(tablename).CursorPaginate(&littles, "", 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(littles))
+	assert.Equal(t, "", prev)
+	assert.NotEqual(t, "", next)
+	first := littles[0].Id
+
+	littles = nil
+	next, prev, err = db.Acquire().Name(tablename).CursorPaginate(&littles, next, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(littles))
+	assert.True(t, littles[0].Id > first)
+
+	littles = nil
+	_, _, err = db.Acquire().Name(tablename).CursorPaginate(&littles, prev, 1)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, first, littles[0].Id)
+
+	_, _, err = db.Acquire().Name(tablename).CursorPaginate(&littles, "bad-cursor", 1)
+	assert.True(t, errors.Is(err, ErrInvalidCursor))
+}
+
+func TestScanCeiling(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/guard.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_guard (id int)")
+	assert.Equal(t, nil, err)
+	var ids []int64
+	err = d.ScanCeiling(1).Acquire().Name("little_guard").What([]string{"id"}).FindMany(&ids)
+	assert.True(t, errors.Is(err, ErrUnsupportedScanCeiling))
+	err = d.Acquire().Name("little_guard").What([]string{"id"}).AllowScan().FindMany(&ids)
+	assert.Equal(t, nil, err)
+
+	onlyMySQL(t)
+	db.ScanCeiling(1)
+	defer db.ScanCeiling(0)
+
+	var littles []LittleOrm
+	err = db.Acquire().Name(tablename).Where("age>?", 0).FindMany(&littles)
+	assert.True(t, errors.Is(err, ErrQueryTooExpensive))
+
+	err = db.Acquire().Name(tablename).Where("age>?", 0).AllowScan().FindMany(&littles)
+	assert.Equal(t, nil, err)
+}
+
+func TestReadOnly(t *testing.T) {
+	db.SetReadOnly(true)
+	_, err := db.Acquire().Name(tablename).Where("id=?", 1).Update("age=age+?", 1)
+	assert.Equal(t, ErrReadOnly, err)
+	// 直接执行的`SQL`也不能写入
+	_, err = db.Acquire().Queryx("delete from "+tablename+" where id=?", 1)
+	assert.Equal(t, ErrReadOnly, err)
+	err = db.Acquire().QueryRowx("update " + tablename + " set age=1 returning id").Scan(new(int))
+	assert.Equal(t, ErrReadOnly, err)
+	_, err = db.Acquire().PrepareNamed("insert into " + tablename + " (name) values (:name)")
+	assert.Equal(t, ErrReadOnly, err)
+	rows, err := db.Acquire().Queryx("with t as (select id from " + tablename + ")\nselect id from t")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, rows.Close())
+	rows, err = db.Acquire().Queryx("select\tid from " + tablename)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, rows.Close())
+	db.SetReadOnly(false)
+
+	var little LittleOrm
+	err = db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+}
+
+func TestLint(t *testing.T) {
+	issues := Lint("postgres", "select `id` from little_orm where created_at > '0000-00-00' limit 0, 10")
+	rules := make([]string, len(issues))
+	for i, issue := range issues {
+		rules[i] = issue.Rule
+	}
+	assert.Equal(t, []string{"backtick", "limit-offset", "zero-date"}, rules)
+	assert.Equal(t, 0, len(Lint("mysql", "select id from little_orm where name='`' limit 0, 10")))
+	// `?`在执行前才替换成`$n`，postgres也不能报错
+	assert.Equal(t, 0, len(Lint("postgres", "select id from little_orm where id=? and name=?")))
+}
+
+func BenchmarkSQLSelect(b *testing.B) {
+	Register(LittleOrm{})
+	b.ReportAllocs()
+	var little LittleOrm
+	for i := 0; i < b.N; i++ {
+		ctx := db.Acquire().Name(tablename).Where("id=?", 1).Order("id desc").Limit(1)
+		ctx.sqlselect(&little)
+		ctx.release()
+	}
+}
+
+func BenchmarkFindOne(b *testing.B) {
+	b.ReportAllocs()
+	var little LittleOrm
+	for i := 0; i < b.N; i++ {
+		if err := db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindOneWhat(b *testing.B) {
+	b.ReportAllocs()
+	var little LittleOrm
+	for i := 0; i < b.N; i++ {
+		if err := db.Acquire().Name(tablename).What([]string{"id", "name", "age"}).Where("id=?", 1).FindOne(&little); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExec(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.Acquire().Name(tablename).Where("id=?", 1).Update("age=?", age); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSQLSelectComplex(b *testing.B) {
+	b.ReportAllocs()
+	var littles []LittleOrm
+	ctx := db.Acquire().Name(tablename).Where("id>?", 1).Where("age<?", 100).Group("name").Having("count(id)>?", 1).Order("id desc").Offset(10).Limit(10).LockS()
+	defer ctx.release()
+	for i := 0; i < b.N; i++ {
+		ctx.sqlselect(&littles)
+	}
+}
+
+func BenchmarkFindOneParallel(b *testing.B) {
+	b.ReportAllocs()
+	var (
+		mu        sync.Mutex
+		durations []time.Duration
+	)
+	b.RunParallel(func(pb *testing.PB) {
+		var (
+			little LittleOrm
+			local  []time.Duration
+		)
+		for pb.Next() {
+			start := time.Now()
+			if err := db.Acquire().Name(tablename).Where("id=?", 1).Where("age>?", 0).FindOne(&little); err != nil {
+				b.Error(err)
+				return
+			}
+			local = append(local, time.Since(start))
+		}
+		mu.Lock()
+		durations = append(durations, local...)
+		mu.Unlock()
+	})
+	if len(durations) > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		b.ReportMetric(float64(durations[len(durations)*99/100].Nanoseconds()), "p99-ns")
+	}
+}
+
+func TestSQLCache(t *testing.T) {
+	cache := NewSQLCache(16)
+	db.SQLCache(cache)
+	defer db.SQLCache(nil)
+
+	var little LittleOrm
+	for i := 0; i < 2; i++ {
+		err := db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little)
+		assert.Equal(t, nil, err)
+	}
+	assert.Equal(t, 1, len(cache.(*sqlCache).items))
+
+	err := db.Acquire().Name(tablename).Where("name=?", name).FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(cache.(*sqlCache).items))
+}
+
+func TestInjectFaults(t *testing.T) {
+	defer db.InjectFaults()
+
+	db.InjectFaults(Fault{Kind: FaultError, Match: MatchSQL("update " + tablename)})
+	_, err := db.Acquire().Name(tablename).Where("id=?", 1).Update("age=?", age)
+	assert.True(t, errors.Is(err, ErrInjectedFault))
+
+	var little LittleOrm
+	err = db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+
+	db.InjectFaults(Fault{Kind: FaultStale, Match: MatchSQL("select")})
+	err = db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+	oldAge := little.Age
+	_, err = db.Acquire().Name(tablename).Where("id=?", 1).Update("age=age+?", 1)
+	assert.Equal(t, nil, err)
+	// 读到的是上一次的结果
+	err = db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, oldAge, little.Age)
+
+	db.InjectFaults()
+	err = db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, oldAge+1, little.Age)
+
+	// 等待超时的时候不能占着锁，修改规则不用等查询超时
+	d := newDB(db.Pool(), time.Second)
+	d.InjectFaults(Fault{Kind: FaultTimeout})
+	done := make(chan error, 1)
+	go func() {
+		var other LittleOrm
+		done <- d.Acquire().Name(tablename).Where("id=?", 1).FindOne(&other)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	d.InjectFaults()
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.True(t, errors.Is(<-done, context.DeadlineExceeded))
+}
+
+func TestContextReleased(t *testing.T) {
+	ctx := db.Acquire().Name(tablename)
+	var littles []LittleOrm
+	assert.Equal(t, nil, ctx.FindMany(&littles))
+	assert.Equal(t, ErrContextReleased, ctx.FindMany(&littles))
+	_, err := ctx.Delete()
+	assert.Equal(t, ErrContextReleased, err)
+}
+
+// 随机组合拼接条件，任何情况下都只能返回错误，不能panic
+func FuzzBuilder(f *testing.F) {
+	f.Add(tablename, "id=?", "id desc", "", "", int64(1), int64(0), false, false, true)
+	f.Add("", "", "", "name", "count(id)>?", int64(0), int64(10), true, true, false)
+	f.Add("not_exists", "id in (?,?)", "?", "1", "x", int64(-1), int64(-1), true, false, true)
+	f.Fuzz(func(t *testing.T, table, where, order, group, having string, limit, offset int64, lockX, lockS, many bool) {
+		ctx := db.Acquire().Name(table).Where(where, 1).Order(order).Group(group).Having(having, 1).Limit(limit).Offset(offset)
+		if lockX {
+			ctx.LockX()
+		}
+		if lockS {
+			ctx.LockS()
+		}
+		if many {
+			var littles []LittleOrm
+			ctx.FindMany(&littles)
+		} else {
+			var little LittleOrm
+			ctx.FindOne(&little)
+		}
+		// 已经放回池子了，再用只会返回错误
+		assert.Equal(t, ErrContextReleased, ctx.FindOne(nil))
+	})
+}
+
+func TestPlaceholderMismatch(t *testing.T) {
+	assert.Equal(t, 2, countPlaceholders("id=? and name=? and remark='?' and `a?`=1"))
+
+	var little LittleOrm
+	err := db.Acquire().Name(tablename).Where("id=? and age=?", 1).FindOne(&little)
+	assert.True(t, errors.Is(err, ErrPlaceholderMismatch))
+
+	err = db.Acquire().Name(tablename).Where("id=?", 1).Group("name").Having("sum(age)>?").FindOne(&little)
+	assert.True(t, errors.Is(err, ErrPlaceholderMismatch))
+
+	err = db.Acquire().Name(tablename).WhereRaw("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+}
+
+func TestMultiHaving(t *testing.T) {
+	var names []string
+	err := db.Acquire().Name(tablename).What([]string{"name"}).Group("name").Having("count(id)>=?", 1).Having("sum(age)>?", 0).OrHaving("name=?", name).Order("name").FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, 0, len(names))
+
+	ctx := db.Acquire().Name(tablename).What([]string{"name"}).Group("name").Having("count(id)>?", 1).OrHaving("sum(age)>?", 100)
+	defer ctx.release()
+	assert.Equal(t, "select name from little_orm group by "+db.quoteIdent("name")+" having (count(id)>?) or (sum(age)>?)", ctx.sqlselect(&names))
+}
+
+func TestHavingBeforeWhere(t *testing.T) {
+	var names []string
+	err := db.Acquire().Name(tablename).What([]string{"name"}).Group("name").Having("sum(age)>?", 0).Where("id=?", 1).FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{name}, names)
+
+	// 没有匹配的where条件时，having的参数不能被当成where的参数
+	names = nil
+	err = db.Acquire().Name(tablename).What([]string{"name"}).Group("name").Having("count(id)>=?", 1).Where("id=?", 0).FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(names))
+}
+
+func TestGroupColumns(t *testing.T) {
+	var ages []int
+	err := db.Acquire().Name(tablename).What([]string{"sum(age) as age"}).Group("name", "age").FindMany(&ages)
+	assert.Equal(t, nil, err)
+
+	err = db.Acquire().Name(tablename).What([]string{"count(id)"}).Group("name; drop table little_orm").FindMany(&ages)
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+
+	err = db.Acquire().Name(tablename).What([]string{"count(id)"}).GroupExpr("age / ?", 10).FindMany(&ages)
+	assert.Equal(t, nil, err)
+
+	db.StrictGroupBy(true)
+	defer db.StrictGroupBy(false)
+	var littles []LittleOrm
+	err = db.Acquire().Name(tablename).What([]string{"name", "age"}).Group("name").FindMany(&littles)
+	assert.True(t, errors.Is(err, ErrGroupBy))
+	err = db.Acquire().Name(tablename).What([]string{"name", "max(age) as age"}).Group("name").FindMany(&littles)
+	assert.Equal(t, nil, err)
+
+	// 带表名和不带表名的字段两个方向都能匹配，表名不同的不算
+	d := newDB(db.Pool(), time.Second).StrictGroupBy(true)
+	for _, c := range []struct {
+		what, group string
+		ok          bool
+	}{
+		{"u.name", "name", true},
+		{"name", "u.name", true},
+		{"u.name", "u.name", true},
+		{"u.name", "v.name", false},
+		{"age", "u.name", false},
+	} {
+		ctx := d.Acquire().What([]string{c.what}).Group(c.group)
+		assert.Equal(t, c.ok, ctx.checkGroupBy(nil) == nil, c.what+" group by "+c.group)
+		ctx.release()
+	}
+}
+
+func TestLimitLockOrder(t *testing.T) {
+	cases := map[string]string{
+		"mysql":    "select id from t limit 10, 5 for update",
+		"postgres": "select id from t limit 5 offset 10 for update",
+		"sqlite3":  "select id from t limit 10, 5",
+	}
+	for driver, expected := range cases {
+		d := newDB(sqlx.NewDb(nil, driver), time.Second)
+		ctx := d.Acquire().Name("t").What([]string{"id"}).Offset(10).Limit(5).LockX()
+		assert.Equal(t, expected, ctx.sqlselect(nil), driver)
+		ctx.release()
+	}
+
+	d := newDB(sqlx.NewDb(nil, "postgres"), time.Second)
+	ctx := d.Acquire().Name("t").What([]string{"id"}).Limit(1).LockS()
+	assert.Equal(t, "select id from t limit 1 for share", ctx.sqlselect(nil))
+	ctx.release()
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- 建表
+/* 注释 */
+create table a (id int, name varchar(10) default ';');
+/*!40101 SET NAMES utf8 */;
+insert into a values (1, 'it''s;ok'), (2, "x\";y"); # 注释;
+DELIMITER $$
+create trigger t before insert on a for each row begin set new.name = 'x'; end$$
+DELIMITER ;
+select 1`
+	statements, err := SplitStatements(script)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{
+		"/* 注释 */\ncreate table a (id int, name varchar(10) default ';')",
+		"/*!40101 SET NAMES utf8 */",
+		`insert into a values (1, 'it''s;ok'), (2, "x\";y")`,
+		"create trigger t before insert on a for each row begin set new.name = 'x'; end",
+		"select 1",
+	}, statements)
+
+	// postgres: 函数体用`$$`引起来，反斜杠不是转义，`#`不是注释
+	statements, err = SplitDialectStatements("postgres", `create function f() returns int as $$ select 1; $$ language sql;
+select $body$ a; $$ b $body$, $1;
+select 'a\', 1 # 2;--注释;
+select 2`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{
+		"create function f() returns int as $$ select 1; $$ language sql",
+		"select $body$ a; $$ b $body$, $1",
+		`select 'a\', 1 # 2`,
+		"select 2",
+	}, statements)
+	_, err = SplitDialectStatements("postgres", "select $$ a;")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRunScript(t *testing.T) {
+	script := `create table little_script (id int);
+insert into little_script values (1);
+insert into little_script values (2);
+insert into not_exists values (3);
+insert into little_script values (4);`
+	n, err := db.Acquire().RunScript(script)
+	assert.Equal(t, 3, n)
+	var scriptErr *ScriptError
+	assert.True(t, errors.As(err, &scriptErr))
+	assert.Equal(t, 3, scriptErr.Index)
+
+	var total int64
+	err = db.Acquire().Get(&total, "select count(*) from little_script")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 2, total)
+	_, err = db.Acquire().Name("little_script").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestSeed(t *testing.T) {
+	var runs []string
+	RegisterSeed("little_seed_base", func(ctx context.Context, tx *sqlx.Tx) error {
+		runs = append(runs, "base")
+		_, err := tx.Exec("create table little_seed (id int, name varchar(10))")
+		return err
+	})
+	RegisterSeed("little_seed_rows", func(ctx context.Context, tx *sqlx.Tx) error {
+		runs = append(runs, "rows")
+		_, err := db.AcquireTx(tx).Name("little_seed").InsertBatch([]string{"id", "name"}, []interface{}{1, "a"}, []interface{}{2, "b"})
+		return err
+	}, "little_seed_base")
+
+	err := db.Seed(context.Background(), "little_seed_rows")
+	assert.Equal(t, nil, err)
+	err = db.Seed(context.Background(), "little_seed_rows")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"base", "rows"}, runs)
+
+	var total int64
+	err = db.Acquire().Get(&total, "select count(*) from little_seed")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 2, total)
+
+	err = db.Seed(context.Background(), "little_seed_missing")
+	assert.True(t, errors.Is(err, ErrUnknownSeed))
+
+	// 只读模式下种子数据不执行
+	db.SetReadOnly(true)
+	err = db.Seed(context.Background(), "little_seed_rows")
+	db.SetReadOnly(false)
+	assert.True(t, errors.Is(err, ErrReadOnly))
+	assert.Equal(t, []string{"base", "rows"}, runs)
+
+	RegisterSeed("little_seed_a", func(ctx context.Context, tx *sqlx.Tx) error { return nil }, "little_seed_b")
+	RegisterSeed("little_seed_b", func(ctx context.Context, tx *sqlx.Tx) error { return nil }, "little_seed_a")
+	err = db.Seed(context.Background(), "little_seed_a")
+	assert.True(t, errors.Is(err, ErrSeedCycle))
+
+	_, err = db.Acquire().Name("little_seed").Drop()
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name(SeedTable).Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestAnonymize(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_anon (id int primary key, name varchar(32), email varchar(64), phone varchar(16))")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_anon").InsertBatch([]string{"id", "name", "email", "phone"},
+		[]interface{}{1, "allen", "allen@gmail.com", "13812345678"},
+		[]interface{}{2, "bob", "bob@gmail.com", nil},
+		[]interface{}{3, "cindy", "cindy@gmail.com", "13912345678"},
+		[]interface{}{4, "david", "david@gmail.com", "13712345678"},
+		[]interface{}{5, "eric", "eric@gmail.com", "13612345678"},
+	)
+	assert.Equal(t, nil, err)
+
+	rules := map[string]AnonymizeFunc{
+		"name":  AnonName("user"),
+		"email": AnonEmail("example.com"),
+		"phone": AnonMask(MaskPhone),
+	}
+	rows, err := db.Acquire().Name("little_anon").Where("id<=?", 4).Limit(2).Anonymize(rules)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 4, rows)
+
+	type anon struct {
+		Id    int64          `db:"id"`
+		Name  string         `db:"name"`
+		Email string         `db:"email"`
+		Phone sql.NullString `db:"phone"`
+	}
+	var anons []anon
+	err = db.Acquire().Name("little_anon").Order("id").FindMany(&anons)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 5, len(anons))
+	assert.Equal(t, "user_1", anons[0].Name)
+	assert.Equal(t, "user_1@example.com", anons[0].Email)
+	assert.Equal(t, "138****5678", anons[0].Phone.String)
+	assert.False(t, anons[1].Phone.Valid)
+	assert.Equal(t, "user_4", anons[3].Name)
+	assert.Equal(t, "eric", anons[4].Name)
+
+	assert.Equal(t, AnonHash("salt")("1", "allen"), AnonHash("salt")("2", "allen"))
+	assert.NotEqual(t, AnonHash("salt")("1", "allen"), AnonHash("pepper")("1", "allen"))
+
+	_, err = db.Acquire().Name("little_anon").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestPurgeOlderThan(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_purge (id int primary key, created_at datetime not null)")
+	assert.Equal(t, nil, err)
+	old := time.Now().Add(-48 * time.Hour).UTC().Format("2006-01-02 15:04:05")
+	recent := time.Now().UTC().Format("2006-01-02 15:04:05")
+	_, err = db.Acquire().Name("little_purge").InsertBatch([]string{"id", "created_at"},
+		[]interface{}{1, old}, []interface{}{2, old}, []interface{}{3, old},
+		[]interface{}{4, old}, []interface{}{5, old}, []interface{}{6, recent},
+	)
+	assert.Equal(t, nil, err)
+
+	var batches []int64
+	total, err := db.PurgeOlderThan("little_purge", "created_at", 24*time.Hour, 2).
+		Interval(time.Millisecond).
+		Progress(func(deleted, total int64) { batches = append(batches, deleted) }).
+		Run(context.Background())
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 5, total)
+	assert.Equal(t, []int64{2, 2, 1}, batches)
+
+	var left int64
+	err = db.Acquire().Get(&left, "select count(*) from little_purge")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, left)
+
+	_, err = db.PurgeOlderThan("little_purge", "created_at", time.Hour, 0).Run(context.Background())
+	assert.NotEqual(t, nil, err)
+
+	// 取消的`ctx`在执行删除时就返回
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.PurgeOlderThan("little_purge", "created_at", time.Hour, 10).Run(canceled)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	_, err = db.Acquire().Name("little_purge").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestTableStats(t *testing.T) {
+	stats, err := db.TableStats(context.Background(), tablename)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(stats))
+	assert.Equal(t, tablename, stats[0].Name)
+
+	var total int64
+	err = db.Acquire().Get(&total, "select count(*) from "+tablename)
+	assert.Equal(t, nil, err)
+	if driver != "mysql" {
+		// mysql的行数是估算值
+		assert.Equal(t, total, stats[0].Rows)
+	}
+	assert.True(t, stats[0].AutoIncrement > 0)
+
+	stats, err = db.TableStats(context.Background(), "little_not_exists")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(stats))
+}
+
+func TestFindSeq(t *testing.T) {
+	var expected []LittleOrm
+	err := db.Acquire().Name(tablename).Order("id").FindMany(&expected)
+	assert.Equal(t, nil, err)
+
+	var rows []LittleOrm
+	for row, err := range FindSeq[LittleOrm](db.Acquire().Name(tablename).Order("id")) {
+		assert.Equal(t, nil, err)
+		rows = append(rows, row)
+	}
+	assert.Equal(t, expected, rows)
+
+	var ids []uint64
+	for id, err := range FindSeq[uint64](db.Acquire().Name(tablename).What([]string{"id"}).Order("id")) {
+		assert.Equal(t, nil, err)
+		ids = append(ids, id)
+		break
+	}
+	assert.Equal(t, []uint64{expected[0].Id}, ids)
+
+	ctx := db.Acquire().Name(tablename)
+	seq := FindSeq[LittleOrm](ctx)
+	for range seq {
+	}
+	for _, err := range seq {
+		assert.True(t, errors.Is(err, ErrContextReleased))
+	}
+
+	for _, err := range FindSeq[LittleOrm](db.Acquire().Name("little_not_exists")) {
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+func TestGenericHelpers(t *testing.T) {
+	var littles []LittleOrm
+	err := db.Acquire().Name(tablename).Order("id").FindMany(&littles)
+	assert.Equal(t, nil, err)
+
+	total, err := Count[int64](db.Acquire().Name(tablename).Order("id").Limit(1))
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, len(littles), total)
+
+	groups := make(map[string]bool)
+	for _, little := range littles {
+		groups[little.Name] = true
+	}
+	count, err := Count[int](db.Acquire().Name(tablename).Group("name"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, len(groups), count)
+	total, err = db.Acquire().Name(tablename).Group("name").Count()
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, len(groups), total)
+	total, err = db.Acquire().Name(tablename).Where("name=?", littles[0].Name).Count()
+	assert.Equal(t, nil, err)
+	assert.True(t, total >= 1)
+
+	names, err := Pluck[string](db.Acquire().Name(tablename).Order("id"), "name")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, len(littles), len(names))
+	assert.Equal(t, littles[0].Name, names[0])
+
+	first, err := GetAs[uint64](db.Acquire(), "select min(id) from "+tablename)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, littles[0].Id, first)
+
+	_, err = GetAs[LittleOrm](db.Acquire(), "select * from "+tablename+" where id=?", -1)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestTransactionPropagation(t *testing.T) {
+	errRollback := errors.New("rollback")
+	err := db.Transaction(context.Background(), PropagationRequired, func(ctx context.Context) error {
+		outer := db.TxFromContext(ctx)
+		assert.NotEqual(t, (*sqlx.Tx)(nil), outer)
+		_, err := db.From(ctx).Name(tablename).Insert(map[string]interface{}{"name": "little_propagation", "age": 1})
+		assert.Equal(t, nil, err)
+
+		return db.Transaction(ctx, PropagationRequired, func(ctx context.Context) error {
+			assert.Equal(t, outer, db.TxFromContext(ctx))
+			return db.Transaction(ctx, PropagationNotSupported, func(ctx context.Context) error {
+				assert.Equal(t, (*sqlx.Tx)(nil), db.TxFromContext(ctx))
+				return errRollback
+			})
+		})
+	})
+	assert.True(t, errors.Is(err, errRollback))
+	var total int64
+	err = db.Acquire().Get(&total, "select count(*) from "+tablename+" where name=?", "little_propagation")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 0, total)
+
+	// 内存数据库只有一个连接，新建事务需要用文件数据库
+	other, err := Open("sqlite3", "file:"+t.TempDir()+"/propagation.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer other.Close()
+	_, err = other.Acquire().Create("create table little_propagation (id int)")
+	assert.Equal(t, nil, err)
+	err = other.Transaction(context.Background(), PropagationRequired, func(ctx context.Context) error {
+		outer := other.TxFromContext(ctx)
+		err := other.Transaction(ctx, PropagationRequiresNew, func(ctx context.Context) error {
+			assert.NotEqual(t, outer, other.TxFromContext(ctx))
+			_, err := other.From(ctx).Name("little_propagation").Insert(map[string]interface{}{"id": 1})
+			return err
+		})
+		assert.Equal(t, nil, err)
+		return errRollback
+	})
+	assert.True(t, errors.Is(err, errRollback))
+	err = other.Acquire().Get(&total, "select count(*) from little_propagation")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, (*sqlx.Tx)(nil), db.TxFromContext(context.Background()))
+}
+
+func TestBeginIntoContext(t *testing.T) {
+	ctx, tx, err := db.BeginIntoContext(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, tx, db.TxFromContext(ctx))
+
+	_, err = db.From(ctx).Name(tablename).Insert(map[string]interface{}{"name": "little_ambient", "age": 1})
+	assert.Equal(t, nil, err)
+	var total int64
+	err = db.From(ctx).Get(&total, "select count(*) from "+tablename+" where name=?", "little_ambient")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, nil, tx.Rollback())
+
+	err = db.From(context.Background()).Get(&total, "select count(*) from "+tablename+" where name=?", "little_ambient")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 0, total)
+}
+
+func TestWithReadSnapshot(t *testing.T) {
+	var first, second int64
+	err := db.WithReadSnapshot(context.Background(), func(ctx context.Context) error {
+		assert.NotEqual(t, (*sqlx.Tx)(nil), db.TxFromContext(ctx))
+		if err := db.From(ctx).Get(&first, "select count(*) from "+tablename); err != nil {
+			return err
+		}
+		return db.From(ctx).Get(&second, "select count(*) from "+tablename)
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, first, second)
+
+	if driver == "mysql" {
+		err = db.WithReadSnapshot(context.Background(), func(ctx context.Context) error {
+			_, err := db.From(ctx).Name(tablename).Insert(map[string]interface{}{"name": "little_snapshot", "age": 1})
+			return err
+		})
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+type littleHooked struct {
+	Id    uint64 `db:"id"`
+	Name  string `db:"name"`
+	Age   int8   `db:"age"`
+	Label string
+}
+
+var errHookedAge = errors.New("hooked age")
+
+func TestAfterScan(t *testing.T) {
+	AfterScan(func(row *littleHooked) error {
+		row.Label = fmt.Sprintf("%s(%d)", row.Name, row.Age)
+		return nil
+	})
+	AfterScan(func(row *littleHooked) error {
+		if row.Age < 0 {
+			return errHookedAge
+		}
+		return nil
+	})
+
+	var one littleHooked
+	err := db.Acquire().Name(tablename).Where("id=?", 1).FindOne(&one)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, fmt.Sprintf("%s(%d)", one.Name, one.Age), one.Label)
+
+	var many []*littleHooked
+	err = db.Acquire().Name(tablename).FindMany(&many)
+	assert.Equal(t, nil, err)
+	for _, row := range many {
+		assert.Equal(t, fmt.Sprintf("%s(%d)", row.Name, row.Age), row.Label)
+	}
+
+	for row, err := range FindSeq[littleHooked](db.Acquire().Name(tablename).Limit(1)) {
+		assert.Equal(t, nil, err)
+		assert.NotEqual(t, "", row.Label)
+	}
+
+	rows, err := db.Acquire().Queryx("select id, name, age from "+tablename+" where id=?", 1)
+	assert.Equal(t, nil, err)
+	assert.True(t, rows.Next())
+	var scanned littleHooked
+	assert.Equal(t, nil, rows.StructScan(&scanned))
+	assert.Equal(t, one.Label, scanned.Label)
+	assert.Equal(t, nil, rows.Close())
+
+	_, err = db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": "little_hooked", "age": -1})
+	assert.Equal(t, nil, err)
+	err = db.Acquire().Name(tablename).Where("name=?", "little_hooked").FindOne(&one)
+	assert.True(t, errors.Is(err, errHookedAge))
+	_, err = db.Acquire().Name(tablename).Where("name=?", "little_hooked").Delete()
+	assert.Equal(t, nil, err)
+}
+
+type validatedLittle struct {
+	Name string `db:"name"`
+}
+
+var errEmptyName = errors.New("empty name")
+
+func (v validatedLittle) Validate() error {
+	if v.Name == "" {
+		return errEmptyName
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	errTooOld := errors.New("too old")
+	db.Validate("little_validate", func(data map[string]interface{}) error {
+		if age, ok := data["age"].(int); ok && age > 150 {
+			return errTooOld
+		}
+		return nil
+	})
+	_, err := db.Acquire().Create("create table little_validate (name varchar(10), age int)")
+	assert.Equal(t, nil, err)
+
+	_, err = db.Acquire().Name("little_validate").Insert(map[string]interface{}{"name": "allen", "age": 200})
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.True(t, errors.Is(err, errTooOld))
+	_, err = db.Acquire().Name("little_validate").Insert(map[string]interface{}{"name": "allen", "age": 20})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_validate").Where("name=?", "allen").UpdateMap(map[string]interface{}{"age": 151})
+	assert.True(t, errors.Is(err, errTooOld))
+
+	ctx := db.Acquire().Name("little_validate")
+	ctx.validate(validatedLittle{}, nil)
+	assert.True(t, errors.Is(ctx.err, errEmptyName))
+	ctx.release()
+
+	_, err = db.Acquire().Name("little_validate").Drop()
+	assert.Equal(t, nil, err)
+}
+
+type littleInserted struct {
+	Id      int64  `db:"id"`
+	Name    string `db:"name"`
+	Age     int    `db:"age"`
+	Created string `db:"created,readonly"`
+}
+
+func (v littleInserted) Validate() error {
+	if v.Name == "" {
+		return errEmptyName
+	}
+	return nil
+}
+
+func TestInsertStruct(t *testing.T) {
+	ddl := "create table little_inserted (id integer primary key autoincrement, name varchar(10), age int, created varchar(20) default 'db')"
+	if driver == "mysql" {
+		ddl = "create table little_inserted (id int primary key auto_increment, name varchar(10), age int, created varchar(20) default 'db')"
+	}
+	_, err := db.Acquire().Create(ddl)
+	assert.Equal(t, nil, err)
+
+	row := littleInserted{Name: "allen", Age: 18, Created: "ignored"}
+	_, err = db.Acquire().Name("little_inserted").InsertStruct(&row)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), row.Id)
+	_, err = db.Acquire().Name("little_inserted").InsertStruct(littleInserted{Id: 10, Name: "lu"})
+	assert.Equal(t, nil, err)
+
+	var rows []littleInserted
+	err = db.Acquire().Name("little_inserted").Order("id asc").FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleInserted{{Id: 1, Name: "allen", Age: 18, Created: "db"}, {Id: 10, Name: "lu", Created: "db"}}, rows)
+
+	_, err = db.Acquire().Name("little_inserted").InsertStruct(&littleInserted{})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = db.Acquire().Name("little_inserted").InsertStruct(1)
+	assert.NotEqual(t, nil, err)
+
+	_, err = db.Acquire().Name("little_inserted").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestUpdateBatchStruct(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_batch (id int primary key, name varchar(10), age int, created varchar(20) default '')")
+	assert.Equal(t, nil, err)
+	for i := 1; i <= 3; i++ {
+		_, err = db.Acquire().Name("little_batch").InsertStruct(littleInserted{Id: int64(i), Name: "allen", Age: i})
+		assert.Equal(t, nil, err)
+	}
+
+	rows := []littleInserted{{Id: 1, Name: "a", Age: 11}, {Id: 2, Name: "b", Age: 12}, {Id: 3, Name: "c", Age: 13}}
+	n, err := db.Acquire().Name("little_batch").UpdateBatchStruct(rows, "age")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(3), n)
+
+	db.InChunkSize(2)
+	n, err = db.Acquire().Name("little_batch").Where("age>?", 11).UpdateBatchStruct(&rows)
+	db.InChunkSize(0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), n)
+
+	var got []littleInserted
+	err = db.Acquire().Name("little_batch").Order("id asc").FindMany(&got)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleInserted{{Id: 1, Name: "allen", Age: 11}, {Id: 2, Name: "b", Age: 12}, {Id: 3, Name: "c", Age: 13}}, got)
+
+	_, err = db.Acquire().Name("little_batch").UpdateBatchStruct([]littleInserted{{Id: 1}}, "name")
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = db.Acquire().Name("little_batch").UpdateBatchStruct(rows, "created")
+	assert.NotEqual(t, nil, err)
+
+	_, err = db.Acquire().Name("little_batch").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestUpdateStruct(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_updated (id int primary key, name varchar(10), age int, created varchar(20) default '')")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_updated").InsertStruct(littleInserted{Id: 1, Name: "allen", Age: 18})
+	assert.Equal(t, nil, err)
+
+	n, err := db.Acquire().Name("little_updated").UpdateStruct(&littleInserted{Id: 1, Name: "lu", Created: "ignored"}, SkipZero())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+	var row littleInserted
+	err = db.Acquire().Name("little_updated").Where("id=?", 1).FindOne(&row)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, littleInserted{Id: 1, Name: "lu", Age: 18}, row)
+
+	_, err = db.Acquire().Name("little_updated").Where("name=?", "lu").UpdateStruct(littleInserted{Name: "x", Age: 0}, OnlyColumns("age"))
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_updated").UpdateStruct(littleInserted{Id: 1, Name: "allen", Age: 20}, OmitColumns("age"))
+	assert.Equal(t, nil, err)
+	err = db.Acquire().Name("little_updated").Where("id=?", 1).FindOne(&row)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, littleInserted{Id: 1, Name: "allen", Age: 0}, row)
+
+	_, err = db.Acquire().Name("little_updated").UpdateStruct(littleInserted{Name: "allen"})
+	assert.True(t, errors.Is(err, ErrNoPrimaryKey))
+	_, err = db.Acquire().Name("little_updated").UpdateStruct(littleInserted{Id: 1}, OnlyColumns("name"))
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = db.Acquire().Name("little_updated").UpdateStruct(littleInserted{Id: 1}, SkipZero())
+	assert.NotEqual(t, nil, err)
+
+	_, err = db.Acquire().Name("little_updated").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestTier(t *testing.T) {
+	db.TimeoutTier("interactive", 2*time.Second).TimeoutTier("report", time.Minute)
+	ctx := db.Acquire().Tier("report")
+	c, cancel := ctx.context()
+	deadline, ok := c.Deadline()
+	cancel()
+	assert.True(t, ok)
+	assert.True(t, time.Until(deadline) > 50*time.Second)
+	ctx.release()
+
+	ctx = db.Acquire()
+	c, cancel = ctx.context()
+	deadline, _ = c.Deadline()
+	cancel()
+	assert.True(t, time.Until(deadline) <= db.timeout)
+	ctx.release()
+
+	var rows []LittleOrm
+	err := db.Acquire().Name(tablename).Tier("batch").FindMany(&rows)
+	assert.True(t, errors.Is(err, ErrUnknownTier))
+	err = db.Acquire().Name(tablename).Tier("interactive").Limit(1).FindMany(&rows)
+	assert.Equal(t, nil, err)
+}
+
+func TestJoin(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_join_user (id int primary key, name varchar(10))")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Create("create table little_join_order (id int primary key, user_id int, amount int)")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_join_user").InsertBatch([]string{"id", "name"}, []interface{}{1, "allen"}, []interface{}{2, "lu"})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_join_order").InsertBatch([]string{"id", "user_id", "amount"}, []interface{}{1, 1, 10}, []interface{}{2, 1, 20})
+	assert.Equal(t, nil, err)
+
+	type row struct {
+		Name   string `db:"name"`
+		Amount *int   `db:"amount"`
+	}
+	var rows []row
+	err = db.Acquire().Name("little_join_user u").
+		Join("little_join_order o", "o.user_id = u.id and o.amount > ?", 15).
+		What([]string{"u.name", "o.amount"}).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, 20, *rows[0].Amount)
+
+	rows = nil
+	err = db.Acquire().Name("little_join_user u").
+		LeftJoin("little_join_order o", "o.user_id = u.id").
+		What([]string{"u.name", "o.amount"}).Where("u.id=?", 2).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []row{{Name: "lu"}}, rows)
+
+	var n int64
+	err = db.Acquire().Name("little_join_user u").CrossJoin("little_join_order o").What([]string{"count(*)"}).FindOne(&n)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(4), n)
+
+	err = db.Acquire().Name("little_join_user u").Join("little_join_order o", "o.user_id = ?").FindMany(&rows)
+	assert.True(t, errors.Is(err, ErrPlaceholderMismatch))
+
+	_, err = db.Acquire().Name("little_join_order").Drop()
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_join_user").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestFilterRows(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_filter (id int primary key, name varchar(10), age int, created varchar(20) default '')")
+	assert.Equal(t, nil, err)
+	for i := 1; i <= 5; i++ {
+		_, err = db.Acquire().Name("little_filter").InsertStruct(littleInserted{Id: int64(i), Name: "allen", Age: i})
+		assert.Equal(t, nil, err)
+	}
+	even := func(row interface{}) bool {
+		return row.(*littleInserted).Age%2 == 0
+	}
+
+	var rows []littleInserted
+	err = db.Acquire().Name("little_filter").Order("id asc").FilterRows(even).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, 4, rows[1].Age)
+
+	var ptrs []*littleInserted
+	err = db.Acquire().Name("little_filter").FilterRows(even).FindMany(&ptrs)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(ptrs))
+
+	var ids []int64
+	err = db.Acquire().Name("little_filter").What([]string{"id"}).FilterRows(func(row interface{}) bool {
+		return *row.(*int64) > 3
+	}).FindMany(&ids)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(ids))
+
+	var row littleInserted
+	err = db.Acquire().Name("little_filter").Order("id desc").FilterRows(even).FindOne(&row)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(4), row.Id)
+	err = db.Acquire().Name("little_filter").FilterRows(func(interface{}) bool { return false }).FindOne(&row)
+	assert.Equal(t, ErrNotFound, err)
+
+	var seen []int
+	for row, err := range FindSeq[littleInserted](db.Acquire().Name("little_filter").Order("id asc").FilterRows(even)) {
+		assert.Equal(t, nil, err)
+		seen = append(seen, row.Age)
+	}
+	assert.Equal(t, []int{2, 4}, seen)
+
+	_, err = db.Acquire().Name("little_filter").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestUpsert(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_upsert (sku varchar(10) primary key, qty int, note varchar(10))")
+	assert.Equal(t, nil, err)
+
+	_, err = db.Acquire().Name("little_upsert").OnConflict("sku").Upsert(map[string]interface{}{"sku": "a1", "qty": 1, "note": "x"}, nil)
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_upsert").OnConflict("sku").Upsert(map[string]interface{}{"sku": "a1", "qty": 5, "note": "y"}, []string{"qty"})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_upsert").OnConflict("sku").Upsert(map[string]interface{}{"sku": "a1"}, nil)
+	assert.Equal(t, nil, err)
+
+	type stock struct {
+		Sku  string `db:"sku"`
+		Qty  int    `db:"qty"`
+		Note string `db:"note"`
+	}
+	var rows []stock
+	err = db.Acquire().Name("little_upsert").FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []stock{{Sku: "a1", Qty: 5, Note: "x"}}, rows)
+
+	if driver != "mysql" {
+		_, err = db.Acquire().Name("little_upsert").Upsert(map[string]interface{}{"sku": "a1", "qty": 5}, nil)
+		assert.True(t, errors.Is(err, ErrUpsertConflict))
+	}
+
+	_, err = db.Acquire().Name("little_upsert").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestOrderBy(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_order_by (id int primary key, score int)")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_order_by").InsertBatch([]string{"id", "score"}, []interface{}{1, 10}, []interface{}{2, nil}, []interface{}{3, 20}, []interface{}{4, 10})
+	assert.Equal(t, nil, err)
+
+	var ids []int64
+	err = db.Acquire().Name("little_order_by").What([]string{"id"}).OrderBy("score", "DESC", NullsLast).OrderBy("id", "asc", NullsDefault).FindMany(&ids)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int64{3, 1, 4, 2}, ids)
+
+	ids = nil
+	err = db.Acquire().Name("little_order_by").What([]string{"id"}).OrderBy("score", "desc", NullsFirst).OrderByExpr("abs(id - ?)", 4).FindMany(&ids)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int64{2, 3, 4, 1}, ids)
+
+	err = db.Acquire().Name("little_order_by").OrderBy("score desc", "asc", NullsDefault).FindMany(&ids)
+	assert.True(t, errors.Is(err, ErrInvalidIdentifier))
+	err = db.Acquire().Name("little_order_by").OrderBy("score", "up", NullsDefault).FindMany(&ids)
+	assert.NotEqual(t, nil, err)
+
+	_, err = db.Acquire().Name("little_order_by").Drop()
+	assert.Equal(t, nil, err)
+}
+
+type littleTrashed struct {
+	Id        int64   `db:"id"`
+	Name      string  `db:"name"`
+	DeletedAt *string `db:"deleted_at,softdelete"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_trashed (id int primary key, name varchar(10), deleted_at varchar(30))")
+	assert.Equal(t, nil, err)
+	for i := 1; i <= 3; i++ {
+		_, err = db.Acquire().Name("little_trashed").InsertStruct(littleTrashed{Id: int64(i), Name: "allen"})
+		assert.Equal(t, nil, err)
+	}
+	_, err = db.Acquire().Name("little_trashed").InsertBatch([]string{"id", "name", "deleted_at"}, []interface{}{4, "allen", "2024-01-01"})
+	assert.Equal(t, nil, err)
+
+	n, err := db.Acquire().Name("little_trashed").SoftDelete("deleted_at").Where("id=?", 1).OrWhere("id=?", 4).Delete()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	var rows []littleTrashed
+	err = db.Acquire().Name("little_trashed").Where("id=?", 1).OrWhere("id=?", 2).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, int64(2), rows[0].Id)
+
+	var total int64
+	err = db.Acquire().Name("little_trashed").SoftDelete("deleted_at").What([]string{"count(*)"}).FindOne(&total)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), total)
+	err = db.Acquire().Name("little_trashed").Unscoped().FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, len(rows))
+	assert.NotEqual(t, (*string)(nil), rows[0].DeletedAt)
+
+	n, err = db.Acquire().Name("little_trashed").UpdateStruct(littleTrashed{Id: 1, Name: "lu"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(0), n)
+	n, err = db.Acquire().Name("little_trashed").UpdateStruct(littleTrashed{Id: 2, Name: "lu"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = db.Acquire().Name("little_trashed").Where("id in (?, ?)", 1, 2).ForceDelete()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), n)
+	err = db.Acquire().Name("little_trashed").Unscoped().FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(rows))
+
+	_, err = db.Acquire().Name("little_trashed").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/hooks.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_hooks (id int primary key, name varchar(10))")
+	assert.Equal(t, nil, err)
+
+	var events []string
+	record := func(name string) LifecycleHook {
+		return func(ctx context.Context, stmt *Statement, err error) error {
+			events = append(events, name+":"+stmt.Table)
+			return nil
+		}
+	}
+	errBlocked := errors.New("blocked")
+	d.On(BeforeInsert, record("before insert")).On(AfterInsert, func(ctx context.Context, stmt *Statement, err error) error {
+		n, _ := stmt.Result.RowsAffected()
+		events = append(events, fmt.Sprintf("after insert:%d", n))
+		return nil
+	})
+	d.On(AfterFind, record("after find")).On(AfterUpdate, record("after update"))
+	d.On(BeforeDelete, func(ctx context.Context, stmt *Statement, err error) error {
+		return errBlocked
+	})
+
+	_, err = d.Acquire().Name("little_hooks").Insert(map[string]interface{}{"id": 1, "name": "allen"})
+	assert.Equal(t, nil, err)
+	var rows []littleInserted
+	err = d.Acquire().Name("little_hooks").What([]string{"id", "name"}).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Name("little_hooks").SoftDelete("name").Where("id=?", 1).Delete()
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Name("little_hooks").Where("id=?", 1).Delete()
+	assert.True(t, errors.Is(err, errBlocked))
+	assert.Equal(t, []string{"before insert:little_hooks", "after insert:1", "after find:little_hooks", "after update:little_hooks"}, events)
+
+	var n int64
+	err = d.Acquire().Name("little_hooks").What([]string{"count(*)"}).FindOne(&n)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestCoerce(t *testing.T) {
+	_, err := db.Acquire().Create("create table little_coerce (id int primary key, price decimal(10,2), name varchar(10), raw blob)")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_coerce").InsertBatch([]string{"id", "price", "name", "raw"}, []interface{}{1, 12.5, "allen", []byte("x")}, []interface{}{-2, nil, "lu", nil})
+	assert.Equal(t, nil, err)
+
+	var rows []map[string]interface{}
+	err = db.Acquire().Name("little_coerce").Order("id desc").Coerce(Coercion{Decimals: DecimalAsString, Text: true}).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"id": int64(1), "price": "12.5", "name": "allen", "raw": []byte("x")},
+		{"id": int64(-2), "price": nil, "name": "lu", "raw": nil},
+	}, rows)
+
+	type row struct {
+		Id    interface{} `db:"id"`
+		Price interface{} `db:"price"`
+		Name  string      `db:"name"`
+	}
+	var one row
+	err = db.Acquire().Name("little_coerce").What([]string{"id", "price", "name"}).Where("id=?", 1).
+		Coerce(Coercion{Ints: IntAsUint64, Decimals: DecimalAsFloat64}).FindOne(&one)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, row{Id: uint64(1), Price: 12.5, Name: "allen"}, one)
+
+	var ids []interface{}
+	err = db.Acquire().Name("little_coerce").What([]string{"id"}).Coerce(Coercion{Ints: IntAsUint64}).FindMany(&ids)
+	assert.True(t, errors.Is(err, ErrCoercion))
+
+	var seen []string
+	for m, err := range FindSeq[map[string]interface{}](db.Acquire().Name("little_coerce").What([]string{"price"}).Where("id=?", 1).Coerce(Coercion{Decimals: DecimalAsString})) {
+		assert.Equal(t, nil, err)
+		seen = append(seen, m["price"].(string))
+	}
+	assert.Equal(t, []string{"12.5"}, seen)
+
+	_, err = db.Acquire().Name("little_coerce").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestRetryOnLock(t *testing.T) {
+	defer db.InjectFaults()
+	var calls int32
+	db.InjectFaults(Fault{Kind: FaultError, Err: &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, Match: func(query string, args []interface{}) bool {
+		return strings.HasPrefix(query, "update "+tablename) && atomic.AddInt32(&calls, 1) == 1
+	}})
+	_, err := db.Acquire().Name(tablename).Where("id=?", -1).UpdateMap(map[string]interface{}{"name": "x"})
+	assert.Equal(t, int32(1), calls)
+	assert.NotEqual(t, nil, err)
+
+	calls = 0
+	_, err = db.Acquire().Name(tablename).Where("id=?", -1).RetryOnLock(time.Second).UpdateMap(map[string]interface{}{"name": "x"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int32(2), calls)
+
+	ctx := db.Acquire().Name(tablename).RetryOnLock(time.Minute)
+	ctx.lock = LockOptions{Mode: LockUpdate, Wait: LockNoWait}
+	ctx.sql = "select 1"
+	assert.False(t, ctx.retryLocked(errors.New("syntax error"), true))
+	assert.True(t, ctx.retryLocked(&pq.Error{Code: "55P03"}, true))
+	assert.Equal(t, LockWaitDefault, ctx.lock.Wait)
+	assert.Equal(t, "", ctx.sql)
+	assert.Equal(t, time.Minute, ctx.timeout)
+	assert.False(t, ctx.retryLocked(&pq.Error{Code: "55P03"}, true))
+	assert.True(t, ctx.lockTimeout(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)))
+	assert.True(t, ctx.lockTimeout(errors.New("database is locked")))
+	ctx.release()
+
+	// 事务中超时不重试，锁等待超时的错误还是重试
+	tx, err := db.Pool().Beginx()
+	assert.Equal(t, nil, err)
+	defer tx.Rollback()
+	ctx = db.AcquireTx(tx).Name(tablename).RetryOnLock(time.Minute)
+	ctx.lock = LockOptions{Mode: LockUpdate}
+	assert.False(t, ctx.retryLocked(fmt.Errorf("wrapped: %w", context.DeadlineExceeded), false))
+	assert.True(t, ctx.retryLocked(&mysql.MySQLError{Number: 1205}, false))
+	ctx.release()
+}
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+msg)
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...LogField) { l.record("debug", msg) }
+func (l *recordingLogger) Info(msg string, fields ...LogField)  { l.record("info", msg) }
+func (l *recordingLogger) Error(msg string, fields ...LogField) { l.record("error", msg) }
+
+func TestLogger(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/logger.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_logger (id int)")
+	assert.Equal(t, nil, err)
+
+	logger := &recordingLogger{}
+	d.Logger(logger, LogDebug)
+	var ids []int64
+	err = d.Acquire().Name("little_logger").What([]string{"id"}).FindMany(&ids)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"debug littleorm sql"}, logger.lines)
+
+	d.Logger(logger, LogInfo)
+	err = d.Acquire().Name("little_logger").What([]string{"id"}).FindMany(&ids)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(logger.lines))
+
+	var buf strings.Builder
+	NewStdLogger(log.New(&buf, "", 0)).Error("littleorm mirror failed", LogField{"source", "user.name"})
+	assert.Equal(t, "[error] littleorm mirror failed source=\"user.name\"\n", buf.String())
+}
+
+func TestToSQL(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/tosql.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_tosql (id int, name varchar(10))")
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Name("little_tosql").Insert(map[string]interface{}{"id": 1, "name": "allen"})
+	assert.Equal(t, nil, err)
+
+	query, args, err := d.Acquire().Name("little_tosql").What([]string{"name"}).Where("id=?", 1).ToSQL(func(ctx *Context) error {
+		var names []string
+		return ctx.FindMany(&names)
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "select name from little_tosql where id=?", query)
+	assert.Equal(t, []interface{}{1}, args)
+
+	query, args, err = d.Acquire().Name("little_tosql").Where("id=?", 1).ToSQL(func(ctx *Context) error {
+		_, err := ctx.UpdateMap(map[string]interface{}{"name": "bob"})
+		return err
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "update little_tosql set name=? where id=?", query)
+	assert.Equal(t, []interface{}{"bob", 1}, args)
+	var name string
+	err = d.Acquire().Name("little_tosql").What([]string{"name"}).Where("id=?", 1).FindOne(&name)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "allen", name)
+
+	_, _, err = d.Acquire().ToSQL(func(ctx *Context) error { return nil })
+	assert.Equal(t, ErrNoStatement, err)
+}
+
+func TestWarmup(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/warmup.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_warmup (id int)")
+	assert.Equal(t, nil, err)
+	d.SetMaxIdleConns(5)
+	d.SetMaxOpenConns(4)
+
+	err = d.Warmup(context.Background(), 6, "select * from little_warmup where id=?")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, d.Stats().Idle)
+	err = d.Warmup(context.Background(), 2, "select * from little_missing")
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 0, d.Stats().InUse)
+}
+
+func TestSwapTarget(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"blue", "green"} {
+		d, err := Open("sqlite3", "file:"+dir+"/"+name+".db", time.Second)
+		assert.Equal(t, nil, err)
+		_, err = d.Acquire().Create("create table little_swap (name varchar(10))")
+		assert.Equal(t, nil, err)
+		_, err = d.Acquire().Name("little_swap").Insert(map[string]interface{}{"name": name})
+		assert.Equal(t, nil, err)
+		_, err = d.Acquire().Create("create table little_tag (id int, name varchar(10))")
+		assert.Equal(t, nil, err)
+		_, err = d.Acquire().Create("create table little_post_tag (post_id int, tag_id int)")
+		assert.Equal(t, nil, err)
+		_, err = d.Acquire().Name("little_tag").Insert(map[string]interface{}{"id": 1, "name": name})
+		assert.Equal(t, nil, err)
+		_, err = d.Acquire().Name("little_post_tag").Insert(map[string]interface{}{"post_id": 1, "tag_id": 1})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, d.Close())
+	}
+
+	d, err := Open("sqlite3", "file:"+dir+"/blue.db", 200*time.Millisecond)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	d.SetMaxOpenConns(3)
+	var name string
+	err = d.Acquire().Name("little_swap").What([]string{"name"}).FindOne(&name)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "blue", name)
+
+	assert.NotEqual(t, nil, d.SwapTarget("file:"+dir+"/missing/green.db"))
+	// 切换前拿到的旧连接池在超时时间内还能用
+	old := d.Pool()
+	assert.Equal(t, nil, d.SwapTarget("file:"+dir+"/green.db"))
+	assert.Equal(t, nil, old.Ping())
+	err = d.Acquire().Name("little_swap").What([]string{"name"}).FindOne(&name)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "green", name)
+	assert.Equal(t, 3, d.Pool().Stats().MaxOpenConnections)
+	time.Sleep(300 * time.Millisecond)
+	assert.NotEqual(t, nil, d.DB.Ping())
+
+	// 预加载也要用切换以后的连接池
+	RegisterManyToMany(littlePost{}, "tags", ManyToMany{
+		Field:      "Tags",
+		Pivot:      "little_post_tag",
+		ForeignKey: "post_id",
+		RelatedKey: "tag_id",
+		Related:    "little_tag",
+		Order:      "little_tag.name desc",
+	})
+	post := littlePost{Id: 1}
+	err = d.Preload(context.Background(), &post, "tags")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleTag{{1, "green"}}, post.Tags)
+}
+
+func TestErrorClassification(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/errors.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_errors (id int primary key, name varchar(10))")
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Name("little_errors").Insert(map[string]interface{}{"id": 1, "name": "allen"})
+	assert.Equal(t, nil, err)
+
+	var name string
+	err = d.Acquire().Name("little_errors").What([]string{"name"}).Where("id=?", 2).FindOne(&name)
+	assert.Equal(t, ErrNotFound, err)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+	assert.True(t, IsNotFound(err))
+	_, err = GetAs[string](d.Acquire(), "select name from little_errors where id=?", 2)
+	assert.Equal(t, ErrNotFound, err)
+	assert.True(t, IsNotFound(sql.ErrNoRows))
+	assert.False(t, IsNotFound(nil))
+
+	_, err = d.Acquire().Name("little_errors").Insert(map[string]interface{}{"id": 1, "name": "bob"})
+	assert.True(t, IsDuplicateKey(err))
+	assert.False(t, IsNotFound(err))
+	assert.True(t, IsDuplicateKey(&mysql.MySQLError{Number: 1062}))
+	assert.True(t, IsDuplicateKey(fmt.Errorf("insert: %w", &pq.Error{Code: "23505"})))
+	assert.False(t, IsDuplicateKey(ErrNotFound))
+	assert.True(t, IsLockTimeout(&pq.Error{Code: "55P03"}))
+}
+
+func TestConstrain(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/constrain.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_constrain (id integer primary key, name varchar(5), age int, created varchar(20) default '')")
+	assert.Equal(t, nil, err)
+	d.Constrain("little_constrain", "name", NotEmpty(), MaxLength(5), Pattern("^[a-z]+$")).
+		Constrain("little_constrain", "age", Range(0, 150))
+
+	_, err = d.Acquire().Name("little_constrain").Insert(map[string]interface{}{"name": "李小龙大侠", "age": 20})
+	assert.True(t, errors.Is(err, ErrValidation))
+	var fields FieldErrors
+	assert.True(t, errors.As(err, &fields))
+	assert.Equal(t, FieldErrors{{Column: "name", Rule: "pattern", Message: "must match ^[a-z]+$"}}, fields)
+
+	_, err = d.Acquire().Name("little_constrain").InsertStruct(&littleInserted{Name: "allenlu", Age: 200})
+	assert.True(t, errors.As(err, &fields))
+	assert.Equal(t, []string{"age", "name"}, []string{fields[0].Column, fields[1].Column})
+	assert.Equal(t, []string{"range", "max_length"}, []string{fields[0].Rule, fields[1].Rule})
+
+	_, err = d.Acquire().Name("little_constrain").Insert(map[string]interface{}{"name": "allen", "age": nil})
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Name("little_constrain").Where("name=?", "allen").UpdateMap(map[string]interface{}{"name": " "})
+	assert.True(t, errors.As(err, &fields))
+	assert.Equal(t, "name: must not be empty; name: must match ^[a-z]+$", fields.Error())
+	_, err = d.Acquire().Name("little_constrain").Where("name=?", "allen").UpdateMap(map[string]interface{}{"age": 30})
+	assert.Equal(t, nil, err)
+}
+
+func TestPaginate(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/paginate.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_paginate (id integer primary key, name varchar(10), age int, created varchar(20) default '')")
+	assert.Equal(t, nil, err)
+	for i := 1; i <= 7; i++ {
+		_, err = d.Acquire().Name("little_paginate").Insert(map[string]interface{}{"id": i, "name": fmt.Sprintf("n%d", i), "age": i % 3})
+		assert.Equal(t, nil, err)
+	}
+
+	var rows []littleInserted
+	total, err := d.Acquire().Name("little_paginate").Where("age>?", 0).Order("id desc").Paginate(2, 2, &rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, []int64{4, 2}, []int64{rows[0].Id, rows[1].Id})
+
+	total, err = d.Acquire().Name("little_paginate").Where("age>?", 0).Paginate(4, 2, &rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, 0, len(rows))
+
+	var ages []int
+	total, err = d.Acquire().Name("little_paginate").What([]string{"age"}).Group("age").Order("age").Paginate(1, 2, &ages)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []int{0, 1}, ages)
+
+	_, err = d.Acquire().Name("little_paginate").Paginate(0, 2, &rows)
+	assert.Equal(t, ErrInvalidPage, err)
+}
+
+func TestFindManyMap(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/meta.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_meta (id integer primary key, name varchar(10) not null, phone varchar(20), price decimal(10,2))")
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Name("little_meta").Insert(map[string]interface{}{"id": 1, "name": "allen", "phone": "13800001234", "price": 9.5})
+	assert.Equal(t, nil, err)
+	d.Mask("little_meta", "phone", func(s string) string { return s[:3] + "****" })
+
+	var rows []map[string]interface{}
+	columns, err := d.Acquire().Name("little_meta").Role("guest").FindManyMap(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []map[string]interface{}{{"id": int64(1), "name": "allen", "phone": "138****", "price": 9.5}}, rows)
+	assert.Equal(t, 4, len(columns))
+	assert.Equal(t, "name", columns[1].Name)
+	assert.Equal(t, "varchar(10)", strings.ToLower(columns[1].Type))
+	assert.True(t, columns[1].Nullable)
+
+	var totals []map[string]interface{}
+	columns, err = d.Acquire().QueryMeta(&totals, "select count(*) as total from little_meta where id>?", 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []map[string]interface{}{{"total": int64(1)}}, totals)
+	assert.Equal(t, "total", columns[0].Name)
+}
+
+func TestZeroDates(t *testing.T) {
+	d, err := Open("sqlite3", "file:"+t.TempDir()+"/zerodate.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer d.Close()
+	_, err = d.Acquire().Create("create table little_zerodate (id integer primary key, created datetime, updated datetime)")
+	assert.Equal(t, nil, err)
+	_, err = d.Acquire().Exec("insert into little_zerodate (id, created, updated) values (1, '0000-00-00 00:00:00', '2024-00-00'), (2, '2024-01-02 03:04:05', null)")
+	assert.Equal(t, nil, err)
+
+	type row struct {
+		Id      int64      `db:"id"`
+		Created time.Time  `db:"created"`
+		Updated *time.Time `db:"updated"`
+	}
+	d.ZeroDates(ZeroDateAsZero)
+	var rows []row
+	err = d.Acquire().Name("little_zerodate").What([]string{"id", "created", "updated"}).Order("id").FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.True(t, rows[0].Created.IsZero())
+	assert.True(t, rows[0].Updated != nil && rows[0].Updated.IsZero())
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), rows[1].Created)
+	assert.True(t, rows[1].Updated == nil)
+
+	d.ZeroDates(ZeroDateAsNull)
+	var updated []sql.NullTime
+	err = d.Acquire().Name("little_zerodate").What([]string{"updated"}).Order("id").FindMany(&updated)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []sql.NullTime{{}, {}}, updated)
+	var one row
+	err = d.Acquire().Name("little_zerodate").What([]string{"id", "created", "updated"}).Where("id=?", 1).FindOne(&one)
+	assert.Equal(t, nil, err)
+	assert.True(t, one.Updated == nil)
+	var maps []map[string]interface{}
+	err = d.Acquire().Name("little_zerodate").What([]string{"created"}).Order("id").Coerce(Coercion{}).FindMany(&maps)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, maps[0]["created"])
+
+	_, err = d.Acquire().Name("little_zerodate").Where("id=?", 2).UpdateMap(map[string]interface{}{"updated": time.Time{}})
+	assert.Equal(t, nil, err)
+	d.ZeroDates(ZeroDateAsZero)
+	_, err = d.Acquire().Name("little_zerodate").Where("id=?", 2).UpdateMap(map[string]interface{}{"created": time.Time{}})
+	assert.Equal(t, nil, err)
+	d.ZeroDates(ZeroDateAsIs)
+	var raw []string
+	err = d.Acquire().Select(&raw, "select coalesce(cast(created as text), 'null') || '|' || coalesce(cast(updated as text), 'null') from little_zerodate where id=2")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"0000-00-00 00:00:00|null"}, raw)
+}
+
+type littleComputed struct {
+	Id       int64  `db:"id"`
+	First    string `db:"first_name"`
+	Last     string `db:"last_name"`
+	FullName string `db:"full_name"`
+	Created  string `db:"created,readonly"`
+}
+
+func TestComputed(t *testing.T) {
+	expr := "first_name || ' ' || last_name"
+	if driver == "mysql" {
+		expr = "concat(first_name, ' ', last_name)"
+	}
+	Computed(littleComputed{}, "full_name", expr)
+	m := modelOf(&littleComputed{})
+	assert.True(t, m.readonly["full_name"])
+	assert.True(t, m.readonly["created"])
+	assert.Equal(t, []string{"id", "first_name", "last_name", "full_name", "created"}, m.columns)
+
+	_, err := db.Acquire().Create("create table little_computed (id int, first_name varchar(10), last_name varchar(10), created varchar(20))")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_computed").Insert(map[string]interface{}{"id": 1, "first_name": "allen", "last_name": "lu", "created": "now"})
+	assert.Equal(t, nil, err)
+
+	var row littleComputed
+	err = db.Acquire().Name("little_computed").Where("id=?", 1).FindOne(&row)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "allen lu", row.FullName)
+	assert.Equal(t, "now", row.Created)
+
+	row = littleComputed{}
+	err = db.Acquire().Name("little_computed").SelectFields([]string{"id", "full_name"}).FindOne(&row)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "allen lu", row.FullName)
+	assert.Equal(t, "", row.First)
+
+	assert.Panics(t, func() { Computed(littleComputed{}, "nickname", "'x'") })
+
+	// 投影继承计算字段
+	type item struct {
+		Id       int64   `db:"id"`
+		FullName *string `db:"full_name"`
+	}
+	Projection(item{}, littleComputed{})
+	var items []item
+	err = db.Acquire().Name("little_computed").FindMany(&items)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "allen lu", *items[0].FullName)
+	assert.Panics(t, func() {
+		Projection(struct {
+			Id string `db:"id"`
+		}{}, littleComputed{})
+	})
+	assert.Panics(t, func() {
+		Projection(struct {
+			Nickname string `db:"nickname"`
+		}{}, littleComputed{})
+	})
+	_, err = db.Acquire().Name("little_computed").Drop()
+	assert.Equal(t, nil, err)
+}
+
+type littleAnimal interface {
+	Sound() string
+}
+
+type littleDog struct {
+	Id    int64   `db:"id"`
+	Name  string  `db:"name"`
+	Bark  *string `db:"bark"`
+	Label string
+}
+
+func (d *littleDog) Sound() string { return *d.Bark }
+
+type littleCat struct {
+	Id    int64  `db:"id"`
+	Name  string `db:"name"`
+	Lives int    `db:"lives"`
+}
+
+func (c littleCat) Sound() string { return fmt.Sprintf("meow x%d", c.Lives) }
+
+func TestPolymorphic(t *testing.T) {
+	Polymorphic[littleAnimal]("kind", map[string]littleAnimal{"dog": &littleDog{}, "cat": littleCat{}})
+	AfterScan(func(d *littleDog) error {
+		d.Label = "dog:" + d.Name
+		return nil
+	})
+	_, err := db.Acquire().Create("create table little_animal (id int, kind varchar(10), name varchar(10), bark varchar(10), lives int)")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_animal").InsertBatch([]string{"id", "kind", "name", "bark", "lives"},
+		[]interface{}{1, "dog", "rex", "woof", nil},
+		[]interface{}{2, "cat", "tom", nil, 9},
+	)
+	assert.Equal(t, nil, err)
+
+	var animals []littleAnimal
+	err = db.Acquire().Name("little_animal").Order("id").FindMany(&animals)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(animals))
+	assert.Equal(t, "woof", animals[0].Sound())
+	assert.Equal(t, "dog:rex", animals[0].(*littleDog).Label)
+	assert.Equal(t, littleCat{Id: 2, Name: "tom", Lives: 9}, animals[1])
+
+	// 接口中的结构体指针和结构体值都要脱敏
+	masked := newDB(db.Pool(), time.Second)
+	masked.Mask("little_animal", "name", MaskMiddle)
+	err = masked.Acquire().Name("little_animal").Order("id").FindMany(&animals)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "r*x", animals[0].(*littleDog).Name)
+	assert.Equal(t, "t*m", animals[1].(littleCat).Name)
+	var one littleAnimal
+	err = masked.Acquire().Name("little_animal").Where("id=?", 2).FindOne(&one)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "t*m", one.(littleCat).Name)
+
+	var animal littleAnimal
+	err = db.Acquire().Name("little_animal").Where("id=?", 2).FindOne(&animal)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "meow x9", animal.Sound())
+	err = db.Acquire().Name("little_animal").Where("id=?", 3).FindOne(&animal)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+
+	// 注册过的结构体插入时自动写类型字段
+	bark := "wuff"
+	_, err = db.Acquire().Name("little_animal").InsertStruct(&littleDog{Id: 4, Name: "max", Bark: &bark})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_animal").InsertStruct(littleCat{Id: 5, Name: "kitty", Lives: 7})
+	assert.Equal(t, nil, err)
+	var kinds []string
+	err = db.Acquire().Name("little_animal").What([]string{"kind"}).Where("id>?", 3).Order("id").FindMany(&kinds)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"dog", "cat"}, kinds)
+	err = db.Acquire().Name("little_animal").Where("id=?", 4).FindOne(&animal)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "dog:max", animal.(*littleDog).Label)
+
+	_, err = db.Acquire().Name("little_animal").Insert(map[string]interface{}{"id": 3, "kind": "bird"})
+	assert.Equal(t, nil, err)
+	err = db.Acquire().Name("little_animal").FindMany(&animals)
+	assert.NotEqual(t, nil, err)
+
+	_, err = db.Acquire().Name("little_animal").Drop()
+	assert.Equal(t, nil, err)
+}
+
+type littleTag struct {
+	Id   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+type littlePost struct {
+	Id   int64 `db:"id"`
+	Tags []littleTag
+}
+
+func TestManyToMany(t *testing.T) {
+	RegisterManyToMany(littlePost{}, "tags", ManyToMany{
+		Field:      "Tags",
+		Pivot:      "little_post_tag",
+		ForeignKey: "post_id",
+		RelatedKey: "tag_id",
+		Related:    "little_tag",
+		Order:      "little_tag.name desc",
+	})
+	_, err := db.Acquire().Create("create table little_tag (id int, name varchar(10))")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Create("create table little_post_tag (post_id int, tag_id int)")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_tag").InsertBatch([]string{"id", "name"},
+		[]interface{}{1, "go"}, []interface{}{2, "sql"}, []interface{}{3, "orm"})
+	assert.Equal(t, nil, err)
+
+	post, other := littlePost{Id: 1}, littlePost{Id: 2}
+	ctx := context.Background()
+	assert.Equal(t, nil, db.Attach(ctx, &post, "tags", 1, 2))
+	assert.Equal(t, nil, db.Attach(ctx, &post, "tags", 2, 3))
+	assert.Equal(t, nil, db.Attach(ctx, &other, "tags", 1))
+
+	posts := []littlePost{post, other}
+	err = db.Preload(ctx, &posts, "tags")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleTag{{2, "sql"}, {3, "orm"}, {1, "go"}}, posts[0].Tags)
+	assert.Equal(t, []littleTag{{1, "go"}}, posts[1].Tags)
+
+	rows, err := db.Detach(ctx, &post, "tags", 3)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, rows)
+	assert.Equal(t, nil, db.Sync(ctx, &post, "tags", 3, 1))
+	err = db.Preload(ctx, &post, "tags")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleTag{{3, "orm"}, {1, "go"}}, post.Tags)
+	masked := newDB(db.Pool(), time.Second)
+	masked.Mask("little_tag", "name", MaskMiddle)
+	err = masked.Preload(ctx, &post, "tags")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleTag{{3, "o*m"}, {1, "**"}}, post.Tags)
+
+	rows, err = db.Detach(ctx, &post, "tags")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 2, rows)
+	_, err = db.Detach(ctx, &post, "labels")
+	assert.True(t, errors.Is(err, ErrUnknownRelation))
+
+	_, err = db.Acquire().Name("little_tag").Drop()
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_post_tag").Drop()
+	assert.Equal(t, nil, err)
+}
+
+type littleCustomer struct {
+	Id          int64  `db:"id"`
+	Name        string `db:"name"`
+	OrdersCount int64  `db:"orders_count"`
+}
+
+func TestWithCount(t *testing.T) {
+	RegisterHasMany(littleCustomer{}, "orders", HasMany{Table: "little_order", ForeignKey: "customer_id"})
+	_, err := db.Acquire().Create("create table little_customer (id int, name varchar(10))")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Create("create table little_order (id int, customer_id int)")
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_customer").InsertBatch([]string{"id", "name"}, []interface{}{1, "allen"}, []interface{}{2, "bob"})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_order").InsertBatch([]string{"id", "customer_id"},
+		[]interface{}{1, 1}, []interface{}{2, 1}, []interface{}{3, 2})
+	assert.Equal(t, nil, err)
+
+	var customers []littleCustomer
+	err = db.Acquire().Name("little_customer").Order("id").FindMany(&customers)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleCustomer{{1, "allen", 0}, {2, "bob", 0}}, customers)
+
+	customers = nil
+	err = db.Acquire().Name("little_customer").WithCount("orders").Order("id").FindMany(&customers)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleCustomer{{1, "allen", 2}, {2, "bob", 1}}, customers)
+
+	var customer littleCustomer
+	err = db.Acquire().Name("little_customer").SelectFields([]string{"id"}).WithCount("orders").Where("id=?", 2).FindOne(&customer)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, littleCustomer{2, "", 1}, customer)
+
+	err = db.Acquire().Name("little_customer").WithCount("reviews").FindOne(&customer)
+	assert.True(t, errors.Is(err, ErrUnknownRelation))
+
+	_, err = db.Acquire().Name("little_customer").Drop()
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_order").Drop()
+	assert.Equal(t, nil, err)
+}
+
+type littleOrder struct {
+	Id         int64 `db:"id"`
+	CustomerId int64 `db:"customer_id"`
+}
+
+type littleBuyer struct {
+	Id     int64             `db:"id"`
+	Name   string            `db:"name"`
+	Orders Lazy[littleOrder] `relation:"orders"`
+	Tags   Lazy[littleTag]   `relation:"tags"`
+}
+
+func TestLazy(t *testing.T) {
+	RegisterHasMany(littleBuyer{}, "orders", HasMany{Table: "little_order", ForeignKey: "customer_id"})
+	RegisterManyToMany(littleBuyer{}, "tags", ManyToMany{
+		Pivot: "little_buyer_tag", ForeignKey: "buyer_id", RelatedKey: "tag_id", Related: "little_tag",
+	})
+	for _, sql := range []string{
+		"create table little_buyer (id int, name varchar(10))",
+		"create table little_order (id int, customer_id int)",
+		"create table little_tag (id int, name varchar(10))",
+		"create table little_buyer_tag (buyer_id int, tag_id int)",
+	} {
+		_, err := db.Acquire().Create(sql)
+		assert.Equal(t, nil, err)
+	}
+	_, err := db.Acquire().Name("little_buyer").InsertBatch([]string{"id", "name"}, []interface{}{1, "allen"}, []interface{}{2, "bob"})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_order").InsertBatch([]string{"id", "customer_id"}, []interface{}{1, 1}, []interface{}{2, 1})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_tag").Insert(map[string]interface{}{"id": 1, "name": "vip"})
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_buyer_tag").Insert(map[string]interface{}{"buyer_id": 2, "tag_id": 1})
+	assert.Equal(t, nil, err)
+
+	var unbound littleBuyer
+	_, err = unbound.Orders.Load(context.Background())
+	assert.True(t, errors.Is(err, ErrLazyNotBound))
+
+	var buyers []littleBuyer
+	err = db.Acquire().Name("little_buyer").Order("id").FindMany(&buyers)
+	assert.Equal(t, nil, err)
+	assert.False(t, buyers[0].Orders.Loaded())
+	orders, err := buyers[0].Orders.Load(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleOrder{{1, 1}, {2, 1}}, orders)
+	assert.True(t, buyers[0].Orders.Loaded())
+
+	// 加载以后使用缓存
+	_, err = db.Acquire().Name("little_order").Delete()
+	assert.Equal(t, nil, err)
+	orders, err = buyers[0].Orders.Load(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(orders))
+	buyers[0].Orders.Reset()
+	orders, err = buyers[0].Orders.Load(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(orders))
+
+	tags, err := buyers[1].Tags.Load(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleTag{{1, "vip"}}, tags)
+
+	for _, table := range []string{"little_buyer", "little_order", "little_tag", "little_buyer_tag"} {
+		_, err = db.Acquire().Name(table).Drop()
+		assert.Equal(t, nil, err)
+	}
+}
+
+func TestIdentityMap(t *testing.T) {
+	ctx, tx, err := db.BeginIntoContext(context.Background())
+	assert.Equal(t, nil, err)
+
+	var first, second LittleOrm
+	err = db.From(ctx).Name(tablename).Where("id=?", 1).FindOne(&first)
+	assert.Equal(t, nil, err)
+	// 不经过`From(ctx)`修改，缓存不会失效
+	_, err = db.AcquireTx(tx).Name(tablename).Where("id=?", 1).Update("age=?", 99)
+	assert.Equal(t, nil, err)
+	err = db.From(ctx).Name(tablename).Where("id = ?", 1).FindOne(&second)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, first, second)
+
+	err = db.From(ctx).Name(tablename).Where("id=?", 1).NoIdentityMap().FindOne(&second)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 99, second.Age)
+
+	// 经过`From(ctx)`修改会清掉缓存
+	_, err = db.From(ctx).Name(tablename).Where("id=?", 1).Update("age=?", 98)
+	assert.Equal(t, nil, err)
+	err = db.From(ctx).Name(tablename).Where("id=?", 1).FindOne(&second)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 98, second.Age)
+	assert.Equal(t, nil, tx.Rollback())
+
+	// 关闭以后每次都查询数据库
+	db.IdentityMap(false)
+	defer db.IdentityMap(true)
+	ctx, tx, err = db.BeginIntoContext(context.Background())
+	assert.Equal(t, nil, err)
+	c := db.From(ctx)
+	assert.Equal(t, (*identityMap)(nil), c.identity)
+	c.release()
+	assert.Equal(t, nil, tx.Rollback())
+}
+
+func TestUnitOfWork(t *testing.T) {
+	for _, sql := range []string{
+		"create table little_uow_order (id int primary key, total int)",
+		"create table little_uow_item (id int primary key, order_id int references little_uow_order(id), qty int)",
+	} {
+		_, err := db.Acquire().Create(sql)
+		assert.Equal(t, nil, err)
+	}
+	if driver == "sqlite3" {
+		_, err := db.Acquire().Exec("pragma foreign_keys = on")
+		assert.Equal(t, nil, err)
+		defer db.Acquire().Exec("pragma foreign_keys = off")
+	}
+
+	var err error
+	uow := db.UnitOfWork().DependsOn("little_uow_item", "little_uow_order")
+	// 先记录子表，执行时仍然先插入父表
+	uow.Insert("little_uow_item", map[string]interface{}{"id": 1, "order_id": 1, "qty": 1}).
+		Insert("little_uow_order", map[string]interface{}{"id": 1, "total": 0}).
+		Update("little_uow_order", 1, map[string]interface{}{"total": 10}).
+		Update("little_uow_order", 1, map[string]interface{}{"total": 20})
+	assert.Equal(t, nil, uow.Commit(context.Background()))
+
+	var total int64
+	err = db.Acquire().Get(&total, "select total from little_uow_order where id=?", 1)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 20, total)
+
+	// 删除时先删子表
+	uow.Update("little_uow_item", 1, map[string]interface{}{"qty": 2}).
+		Delete("little_uow_order", 1).
+		Delete("little_uow_item", 1)
+	assert.Equal(t, nil, uow.Commit(context.Background()))
+	err = db.Acquire().Get(&total, "select count(*) from little_uow_order")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 0, total)
+
+	err = db.UnitOfWork().DependsOn("a", "b").DependsOn("b", "a").Insert("a", map[string]interface{}{"id": 1}).Commit(context.Background())
+	assert.NotEqual(t, nil, err)
+
+	// 结构体按模型的主键更新和删除
+	type uowStruct struct {
+		No    int `db:"order_no,pk"`
+		Total int `db:"total"`
+	}
+	_, err = db.Acquire().Create("create table little_uow_struct (order_no int primary key, total int)")
+	assert.Equal(t, nil, err)
+	defer db.Acquire().Name("little_uow_struct").Drop()
+	assert.Equal(t, nil, db.UnitOfWork().
+		InsertStruct("little_uow_struct", &uowStruct{No: 7, Total: 1}).
+		InsertStruct("little_uow_struct", &uowStruct{No: 8, Total: 1}).
+		UpdateStruct("little_uow_struct", &uowStruct{No: 7, Total: 5}).
+		UpdateStruct("little_uow_struct", &uowStruct{No: 7, Total: 6}).
+		DeleteStruct("little_uow_struct", &uowStruct{No: 8}).
+		Commit(context.Background()))
+	var rows []uowStruct
+	err = db.Acquire().Name("little_uow_struct").FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []uowStruct{{No: 7, Total: 6}}, rows)
+	err = db.UnitOfWork().UpdateStruct("little_uow_struct", uowStruct{Total: 1}).Commit(context.Background())
+	assert.True(t, errors.Is(err, ErrNoPrimaryKey))
+
+	_, err = db.Acquire().Name("little_uow_item").Drop()
+	assert.Equal(t, nil, err)
+	_, err = db.Acquire().Name("little_uow_order").Drop()
+	assert.Equal(t, nil, err)
+}
+
+func TestCountWith(t *testing.T) {
+	exact, err := Count[int64](db.Acquire().Name(tablename))
+	assert.Equal(t, nil, err)
+
+	estimate, err := CountWith[int64](db.Acquire().Name(tablename), CountEstimate)
+	assert.Equal(t, nil, err)
+	if driver == "sqlite3" {
+		assert.Equal(t, exact, estimate)
+	}
+
+	var maxID int64
+	err = db.Acquire().Get(&maxID, "select max(id) from "+tablename)
+	assert.Equal(t, nil, err)
+	approx, err := CountWith[int64](db.Acquire().Name(tablename).Order("id").Limit(1), CountMaxID)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, maxID, approx)
+
+	_, err = CountWith[int64](db.Acquire().Name(tablename).Where("age>?", 1), CountMaxID)
+	assert.True(t, errors.Is(err, ErrCountStrategy))
+}
+
+type littleTempRow struct {
+	Id    int64  `db:"id"`
+	Label string `db:"label"`
+}
+
+func TestTempTable(t *testing.T) {
+	tmp, err := db.CreateTempTable(context.Background(), "little_tmp_ids", "id bigint primary key")
+	assert.Equal(t, nil, err)
+	ids := make([]interface{}, 0, 1200)
+	for i := 1; i <= 1200; i++ {
+		ids = append(ids, i)
+	}
+	assert.Equal(t, nil, tmp.InsertValues("id", ids...))
+
+	var littles []LittleOrm
+	err = tmp.Acquire().Name(tablename + " join little_tmp_ids on little_tmp_ids.id = " + tablename + ".id").
+		What([]string{tablename + ".*"}).FindMany(&littles)
+	assert.Equal(t, nil, err)
+	var total int64
+	// 内存数据库只有一个连接，被临时表占用了，其他查询也要在临时表的连接上执行
+	err = tmp.Acquire().Get(&total, "select count(*) from "+tablename+" where id <= 1200")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, total, len(littles))
+	assert.Equal(t, nil, tmp.Close())
+
+	tmp, err = db.CreateTempTable(context.Background(), "little_tmp_rows", littleTempRow{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, tmp.Insert([]string{"id", "label"}, []interface{}{1, "a"}))
+	var rows []littleTempRow
+	err = tmp.Acquire().Name(tmp.Name()).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []littleTempRow{{1, "a"}}, rows)
+	assert.Equal(t, nil, tmp.Close())
+
+	_, err = db.CreateTempTable(context.Background(), "little_tmp_bad", 1)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestInChunkSize(t *testing.T) {
+	db.InChunkSize(2)
+	defer db.InChunkSize(0)
+
+	ids := []interface{}{1, 2, 3, 4, 5}
+	var littles []LittleOrm
+	err := db.Acquire().Name(tablename).Where("age>?", -1).WhereIn("id", ids).FindMany(&littles)
+	assert.Equal(t, nil, err)
+	var expected int64
+	err = db.Acquire().Get(&expected, "select count(*) from "+tablename+" where id in (1,2,3,4,5)")
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, expected, len(littles))
+
+	// 带了`Limit`不拆分
+	littles = nil
+	err = db.Acquire().Name(tablename).WhereIn("id", ids).Limit(1).FindMany(&littles)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, len(littles))
+
+	var insertIds []interface{}
+	for i := 0; i < 5; i++ {
+		result, err := db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": name + "-chunk", "age": age})
+		assert.Equal(t, nil, err)
+		id, _ := result.LastInsertId()
+		insertIds = append(insertIds, id)
+	}
+	rows, err := db.Acquire().Name(tablename).WhereIn("id", insertIds).Where("age=?", age).UpdateMap(map[string]interface{}{"age": age + 1})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 5, rows)
+	rows, err = db.Acquire().Name(tablename).WhereIn("id", insertIds).Where("age=?", age+1).Delete()
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 5, rows)
+}
+
+func TestValues(t *testing.T) {
+	type scored struct {
+		Id    uint64 `db:"id"`
+		Score int    `db:"score"`
+	}
+	var rows []scored
+	err := db.Acquire().Name(tablename).
+		JoinValues(Values([]string{"vid", "score"}, []interface{}{1, 90}, []interface{}{2, 80}), "v", "v.vid = "+tablename+".id").
+		What([]string{tablename + ".id", "v.score"}).Order(tablename + ".id").FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []scored{{1, 90}, {2, 80}}, rows)
+
+	query, args, err := Values([]string{"a", "b"}, []interface{}{1, 2}, []interface{}{3, 4}).SQL("mysql", "v")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "(values row(?, ?), row(?, ?)) as v (a, b)", query)
+	assert.Equal(t, []interface{}{1, 2, 3, 4}, args)
+	query, _, err = Values([]string{"a", "b"}, []interface{}{1, 2}, []interface{}{3, 4}).SQL("sqlite3", "v")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "(select ? as a, ? as b union all select ?, ?) as v", query)
+	query, _, err = Values([]string{"a", "b", "c"}, []interface{}{1, "x", nil}, []interface{}{3, "y", 1.5}).SQL("postgres", "v")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "(values (?::bigint, ?::text, ?), (?::bigint, ?::text, ?)) as v (a, b, c)", query)
+	query, _, err = Values([]string{"a", "b"}, []interface{}{1, 2}).Types("int", "").SQL("postgres", "v")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "(values (?::int, ?)) as v (a, b)", query)
+
+	err = db.Acquire().Name(tablename).JoinValues(Values([]string{"a"}, []interface{}{1, 2}), "v", "1=1").FindMany(&rows)
+	assert.True(t, errors.Is(err, ErrInvalidValues))
+}
+
+func TestAcquireRequest(t *testing.T) {
+	db.KillOnCancel(true)
+	defer db.KillOnCancel(false)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	var little LittleOrm
+	err := db.AcquireRequest(r).Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+
+	// 客户端断开连接以后请求的`context.Context`会被取消
+	c, cancel := context.WithCancel(r.Context())
+	cancel()
+	err = db.AcquireRequest(r.WithContext(c)).Name(tablename).Where("id=?", 1).FindOne(&little)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.AcquireRequest(r.WithContext(c)).Name(tablename).Where("id=?", 1).Update("age=age")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestTags(t *testing.T) {
+	c := WithTags(context.Background(), map[string]string{"a": "1"})
+	c = WithTags(c, map[string]string{"b": "2"})
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, TagsFromContext(c))
+	ctx := db.Acquire().WithContext(c)
+	assert.Equal(t, "2", ctx.Tags()["b"])
+	ctx.release()
+	assert.Equal(t, map[string]string(nil), TagsFromContext(context.Background()))
+}
+
+func TestPassthrough(t *testing.T) {
+	rows, err := db.Acquire().Queryx("select id, name from "+tablename+" where id<=?", 2)
+	assert.Equal(t, nil, err)
+	var ids []uint64
+	for rows.Next() {
+		var little LittleOrm
+		assert.Equal(t, nil, rows.StructScan(&little))
+		ids = append(ids, little.Id)
+	}
+	assert.Equal(t, nil, rows.Close())
+	assert.Equal(t, []uint64{1, 2}, ids)
+
+	var id uint64
+	err = db.Acquire().QueryRowx("select id from "+tablename+" where id=?", 1).Scan(&id)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, id)
+
+	ctx := db.Acquire()
+	ctx.release()
+	assert.Equal(t, ErrContextReleased, ctx.QueryRowx("select 1").Scan(&id))
+
+	stmt, err := db.Acquire().PrepareNamed("select id from " + tablename + " where id=:id")
+	assert.Equal(t, nil, err)
+	err = stmt.Get(&id, map[string]interface{}{"id": 2})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 2, id)
+	assert.Equal(t, nil, stmt.Close())
+}
+
+func TestNewFromDB(t *testing.T) {
+	raw, err := sql.Open("sqlite3", "file:"+t.TempDir()+"/from.db")
+	assert.Equal(t, nil, err)
+	defer raw.Close()
+	_, err = raw.Exec("create table little_from (id integer primary key, name varchar(32))")
+	assert.Equal(t, nil, err)
+
+	wrapped := NewFromDB(raw, "sqlite3", time.Second)
+	_, err = wrapped.Acquire().Name("little_from").Insert(map[string]interface{}{"id": 1, "name": "from"})
+	assert.Equal(t, nil, err)
+	name, err := GetAs[string](NewFromSqlx(sqlx.NewDb(raw, "sqlite3"), time.Second).Acquire(), "select name from little_from where id=?", 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "from", name)
+}
+
+func TestMiddleware(t *testing.T) {
+	mdb, err := Open("sqlite3", "file:"+t.TempDir()+"/mw.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer mdb.Close()
+	_, err = mdb.Acquire().Create("create table little_mw (id integer primary key, name varchar(32))")
+	assert.Equal(t, nil, err)
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Executor) Executor {
+			return func(ctx context.Context, stmt *Statement) error {
+				order = append(order, name)
+				return next(ctx, stmt)
+			}
+		}
+	}
+	// 分表：把表名改成实际的表
+	shard := func(next Executor) Executor {
+		return func(ctx context.Context, stmt *Statement) error {
+			stmt.Query = strings.Replace(stmt.Query, "little_logical", "little_mw", 1)
+			return next(ctx, stmt)
+		}
+	}
+	// 缓存：直接返回结果
+	cache := func(next Executor) Executor {
+		return func(ctx context.Context, stmt *Statement) error {
+			if names, ok := stmt.Dest.(*[]string); ok && stmt.Table == "cached" {
+				*names = []string{"from cache"}
+				return nil
+			}
+			return next(ctx, stmt)
+		}
+	}
+	mdb.Use(trace("a"), trace("b"), shard, cache)
+
+	_, err = mdb.Acquire().Name("little_logical").Insert(map[string]interface{}{"id": 1, "name": "mw"})
+	assert.Equal(t, nil, err)
+	var little struct {
+		Id   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	err = mdb.Acquire().Name("little_logical").Where("id=?", 1).FindOne(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "mw", little.Name)
+	assert.Equal(t, []string{"a", "b", "a", "b"}, order)
+
+	names, err := Pluck[string](mdb.Acquire().Name("cached"), "name")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"from cache"}, names)
+
+	// 返回结果集的查询也经过中间件
+	order = nil
+	for name, err := range FindSeq[string](mdb.Acquire().Name("little_logical").What([]string{"name"})) {
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "mw", name)
+	}
+	rows, err := mdb.Acquire().Queryx("select name from little_logical")
+	assert.Equal(t, nil, err)
+	assert.True(t, rows.Next())
+	assert.Equal(t, nil, rows.Close())
+	var name string
+	err = mdb.Acquire().QueryRowx("select name from little_logical where id=?", 1).Scan(&name)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "mw", name)
+	named, err := mdb.Acquire().PrepareNamed("select name from little_logical where id=:id")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, named.Close())
+	assert.Equal(t, []string{"a", "b", "a", "b", "a", "b", "a", "b"}, order)
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(2, 1)
+	exec := recorder.Middleware()(func(ctx context.Context, stmt *Statement) error {
+		if stmt.Query == "bad" {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	c := WithTags(context.Background(), map[string]string{TagTraceID: "t1"})
+	for _, query := range []string{"q1", "q2", "bad"} {
+		_ = exec(c, &Statement{Query: query, Args: []interface{}{query}})
+	}
+	_ = exec(context.Background(), &Statement{Query: "untraced"})
+
+	statements := recorder.Statements("t1")
+	assert.Equal(t, 2, len(statements))
+	assert.Equal(t, "q2", statements[0].Query)
+	assert.Equal(t, "bad", statements[1].Query)
+	assert.Equal(t, sql.ErrNoRows, statements[1].Err)
+
+	// 超过请求个数上限，最早的请求被丢掉
+	_ = exec(WithTags(context.Background(), map[string]string{TagTraceID: "t2"}), &Statement{Query: "q3"})
+	assert.Equal(t, 0, len(recorder.Statements("t1")))
+	assert.Equal(t, 1, len(recorder.Statements("t2")))
+	recorder.Forget("t2")
+	assert.Equal(t, 0, len(recorder.Statements("t2")))
+
+	// `Forget`的请求从顺序中删掉，不会越积越多
+	recorder = NewRecorder(2, 2)
+	exec = recorder.Middleware()(func(ctx context.Context, stmt *Statement) error { return nil })
+	_ = exec(WithTags(context.Background(), map[string]string{TagTraceID: "keep"}), &Statement{Query: "q"})
+	for i := 0; i < 10; i++ {
+		_ = exec(WithTags(context.Background(), map[string]string{TagTraceID: "short"}), &Statement{Query: "q"})
+		recorder.Forget("short")
+	}
+	assert.Equal(t, 1, recorder.order.Len())
+	assert.Equal(t, 1, len(recorder.Statements("keep")))
+}
+
+func TestQueryLog(t *testing.T) {
+	path := t.TempDir() + "/sql.log"
+	qlog, err := OpenQueryLog(path, QueryLogGeneral, 120, 1)
+	assert.Equal(t, nil, err)
+	exec := qlog.Middleware()(func(ctx context.Context, stmt *Statement) error { return nil })
+	err = exec(context.Background(), &Statement{Query: "select * from user where name=? and note='?' and ok=?", Args: []interface{}{"it's", true}})
+	assert.Equal(t, nil, err)
+	data, err := os.ReadFile(path)
+	assert.Equal(t, nil, err)
+	assert.Contains(t, string(data), "Query\tselect * from user where name='it\\'s' and note='?' and ok=1 /* ")
+
+	// 超过大小滚动到`sql.log.1`
+	err = exec(context.Background(), &Statement{Query: "select 2"})
+	assert.Equal(t, nil, err)
+	old, err := os.ReadFile(path + ".1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, data, old)
+	assert.Equal(t, nil, qlog.Close())
+
+	qlog, err = OpenQueryLog(path, QueryLogJSON, 0, 0)
+	assert.Equal(t, nil, err)
+	defer qlog.Close()
+	exec = qlog.Middleware()(func(ctx context.Context, stmt *Statement) error { return sql.ErrNoRows })
+	_ = exec(context.Background(), &Statement{Query: "select ?", Args: []interface{}{1}, Table: "user"})
+	data, err = os.ReadFile(path)
+	assert.Equal(t, nil, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var entry map[string]interface{}
+	assert.Equal(t, nil, json.Unmarshal([]byte(lines[len(lines)-1]), &entry))
+	assert.Equal(t, "select ?", entry["query"])
+	assert.Equal(t, sql.ErrNoRows.Error(), entry["error"])
+}
+
+func TestRefreshSummary(t *testing.T) {
+	sdb, err := Open("sqlite3", "file:"+t.TempDir()+"/summary.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer sdb.Close()
+	_, err = sdb.Acquire().RunScript(`
+create table orders (id integer primary key, user_id int, amount int);
+create table order_summary (user_id int primary key, orders int, amount int);
+insert into orders (user_id, amount) values (1, 10), (1, 20), (2, 5);
+insert into order_summary values (3, 1, 1);
+`)
+	assert.Equal(t, nil, err)
+	sel := func() *Context {
+		return sdb.Acquire().Name("orders").What([]string{"user_id", "count(*) as orders", "sum(amount) as amount"}).GroupExpr("user_id")
+	}
+	type summary struct {
+		UserId int `db:"user_id"`
+		Orders int `db:"orders"`
+		Amount int `db:"amount"`
+	}
+	load := func() (rows []summary) {
+		err := sdb.Acquire().Name("order_summary").Order("user_id").FindMany(&rows)
+		assert.Equal(t, nil, err)
+		return
+	}
+
+	err = sdb.RefreshSummary(context.Background(), "order_summary", sel(), RefreshIncremental, "user_id")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []summary{{1, 2, 30}, {2, 1, 5}, {3, 1, 1}}, load())
+
+	_, err = sdb.Acquire().Exec("insert into orders (user_id, amount) values (2, 5)")
+	assert.Equal(t, nil, err)
+	err = sdb.RefreshSummary(context.Background(), "order_summary", sel(), RefreshFull)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []summary{{1, 2, 30}, {2, 2, 10}}, load())
+
+	err = sdb.RefreshSummary(context.Background(), "order_summary", sel(), RefreshIncremental)
+	assert.True(t, errors.Is(err, ErrRefreshSummary))
+}
+
+func TestMirror(t *testing.T) {
+	mdb, err := Open("sqlite3", "file:"+t.TempDir()+"/mirror.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer mdb.Close()
+	_, err = mdb.Acquire().RunScript(`
+create table users (id integer primary key, name varchar(32));
+create table orders (id integer primary key, user_id int, user_name varchar(32));
+insert into users values (1, 'a'), (2, 'b');
+insert into orders (user_id, user_name) values (1, 'a'), (1, 'a'), (2, 'b');
+`)
+	assert.Equal(t, nil, err)
+	mdb.Mirror(MirrorRule{Source: "users", SourceColumn: "name", SourceKey: "id", Target: "orders", TargetColumn: "user_name", TargetKey: "user_id"})
+
+	// 条件中的字段被更新了也能同步
+	rows, err := mdb.Acquire().Name("users").Where("name=?", "a").UpdateMap(map[string]interface{}{"name": "aa"})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, rows)
+	names, err := Pluck[string](mdb.Acquire().Name("orders").Order("id"), "user_name")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"aa", "aa", "b"}, names)
+
+	// 同步失败时更新也回滚
+	mdb.Mirror(MirrorRule{Source: "users", SourceColumn: "name", SourceKey: "id", Target: "missing", TargetColumn: "user_name", TargetKey: "user_id"})
+	_, err = mdb.Acquire().Name("users").Where("id=?", 2).UpdateMap(map[string]interface{}{"name": "bb"})
+	assert.NotEqual(t, nil, err)
+	name, err := GetAs[string](mdb.Acquire(), "select name from users where id=?", 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "b", name)
+}
+
+func TestDeleteByPKs(t *testing.T) {
+	var ids []interface{}
+	for i := 0; i < 5; i++ {
+		result, err := db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": name + "-pks", "age": age})
+		assert.Equal(t, nil, err)
+		id, _ := result.LastInsertId()
+		ids = append(ids, id)
+	}
+	// 条件会一起带上，第一条不满足条件
+	_, err := db.Acquire().Name(tablename).Where("id=?", ids[0]).UpdateMap(map[string]interface{}{"age": age + 1})
+	assert.Equal(t, nil, err)
+	rows, err := db.Acquire().Name(tablename).Where("age=?", age).Interval(time.Millisecond).DeleteByPKs(ids, 2)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 4, rows)
+	rows, err = db.Acquire().Name(tablename).DeleteByPKs(ids, 2)
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 1, rows)
+
+	_, err = db.Acquire().Name(tablename).DeleteByPKs(ids, 0)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestInsertUnlessExists(t *testing.T) {
+	data := map[string]interface{}{"name": name + "-dedup", "age": age}
+	result, err := db.Acquire().Name(tablename).InsertUnlessExists([]string{"name", "age"}, data)
+	assert.Equal(t, nil, err)
+	rows, _ := result.RowsAffected()
+	assert.EqualValues(t, 1, rows)
+	result, err = db.Acquire().Name(tablename).InsertUnlessExists([]string{"name", "age"}, data)
+	assert.Equal(t, nil, err)
+	rows, _ = result.RowsAffected()
+	assert.EqualValues(t, 0, rows)
+
+	_, err = db.Acquire().Name(tablename).InsertUnlessExists([]string{"missing"}, data)
+	assert.NotEqual(t, nil, err)
+	_, err = db.Acquire().Name(tablename).Where("name=?", name+"-dedup").Delete()
+	assert.Equal(t, nil, err)
+}
+
+func TestFragment(t *testing.T) {
+	var ids []int64
+	for i := 0; i < 2; i++ {
+		result, err := db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": name + "-frag", "age": age})
+		assert.Equal(t, nil, err)
+		id, _ := result.LastInsertId()
+		ids = append(ids, id)
+	}
+	defer db.Acquire().Name(tablename).Where("name=?", name+"-frag").Delete()
+
+	adult := Frag("age>=?", 0)
+	named := func(n string) Fragment { return Frag("name=?", n) }
+	cond := And(adult, Or(named(name+"-frag"), named("nobody")), Fragment{})
+	assert.Equal(t, "(age>=?) and ((name=?) or (name=?))", cond.SQL)
+	assert.Equal(t, []interface{}{0, name + "-frag", "nobody"}, cond.Args)
+	assert.Equal(t, "id in (select 1)", Frag("select 1").Wrap("id in (", ")").SQL)
+
+	var rows []struct {
+		Id    uint64 `db:"id"`
+		Label string `db:"label"`
+	}
+	err := db.Acquire().Name(tablename).
+		WhatFragment(Frag("id"), Frag("? as label", "x")).
+		WhereFragment(cond).WhereFragment(Fragment{}).
+		OrderFragment(Frag("case when id=? then 0 else 1 end", ids[1]), Frag("id")).
+		FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(rows))
+	assert.EqualValues(t, ids[1], rows[0].Id)
+	assert.Equal(t, "x", rows[0].Label)
+
+	n, err := Count[int64](db.Acquire().Name(tablename).WhatFragment(Frag("? as label", "x")).WhereFragment(cond).OrderFragment(Frag("id=?", 2)))
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 2, n)
+}
+
+func TestLockingClause(t *testing.T) {
+	cases := []struct {
+		driver   string
+		opts     LockOptions
+		expected string
+	}{
+		{"mysql", LockOptions{Mode: LockShare}, "select id from t lock in share mode"},
+		{"mysql", LockOptions{Mode: LockUpdate, Tables: []string{"t"}, Wait: LockSkipLocked}, "select id from t for update of t skip locked"},
+		{"mysql", LockOptions{Mode: LockShare, Wait: LockNoWait}, "select id from t for share nowait"},
+		{"postgres", LockOptions{Mode: LockUpdate, Wait: LockNoWait}, "select id from t for update nowait"},
+		{"sqlite3", LockOptions{Mode: LockUpdate}, "select id from t"},
+	}
+	for _, c := range cases {
+		d := newDB(sqlx.NewDb(nil, c.driver), time.Second)
+		ctx := d.Acquire().Name("t").What([]string{"id"}).LockingClause(c.opts)
+		assert.Equal(t, nil, ctx.err)
+		assert.Equal(t, c.expected, ctx.sqlselect(nil), c.driver)
+		ctx.release()
+	}
+
+	var little LittleOrm
+	err := db.Acquire().Name(tablename).LockingClause(LockOptions{Wait: LockNoWait}).FindOne(&little)
+	assert.True(t, errors.Is(err, ErrUnsupportedLock))
+	d := newDB(sqlx.NewDb(nil, "sqlite3"), time.Second)
+	ctx := d.Acquire().LockingClause(LockOptions{Mode: LockUpdate, Wait: LockSkipLocked})
+	assert.True(t, errors.Is(ctx.err, ErrUnsupportedLock))
+	ctx.release()
+}
+
+func TestHints(t *testing.T) {
+	assert.Equal(t, "select id from user where age > ? and name in (?+) and note=?",
+		Fingerprint("SELECT id  FROM user\n WHERE age > 18 and name in ('a', 'b', 'c') and note=\"x\""))
+	assert.Equal(t, Fingerprint("select * from t where id in (?,?)"), Fingerprint("select /*+ NO_ICP(t) */ * from t where id in (1)"))
+
+	h := Hints{Index: "force index (idx_age)", Optimizer: "MAX_EXECUTION_TIME(1000)"}
+	assert.Equal(t, "select /*+ MAX_EXECUTION_TIME(1000) */ id from user force index (idx_age) where age>?", h.apply("select id from user where age>?", "user"))
+	assert.Equal(t, "select u.id from user u force index (idx_age) join t on t.id=u.id", Hints{Index: "force index (idx_age)"}.apply("select u.id from user u join t on t.id=u.id", "user"))
+
+	// 执行时自动加上提示，sqlite的`indexed by`也可以用来测试
+	query := "select count(*) from " + tablename + " where id>?"
+	db.PinHints(Fingerprint(query), Hints{Table: tablename, Index: "indexed by little_not_exists"})
+	var n int64
+	err := db.Acquire().Get(&n, query, 1)
+	assert.NotEqual(t, nil, err)
+	db.UnpinHints(Fingerprint(query))
+	err = db.Acquire().Get(&n, query, 1)
+	assert.Equal(t, nil, err)
+}
+
+func TestResultCache(t *testing.T) {
+	cache := NewMemoryCache(100)
+	db.ResultCache(cache)
+	defer db.ResultCache(nil)
+
+	result, err := db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": "cached", "age": 20})
+	assert.Equal(t, nil, err)
+	id, _ := result.LastInsertId()
+
+	find := func() (one LittleOrm, many []LittleOrm) {
+		err := db.Acquire().Name(tablename).Where("id=?", id).Cache(time.Minute).FindOne(&one)
+		assert.Equal(t, nil, err)
+		err = db.Acquire().Name(tablename).Where("name=?", "cached").Cache(time.Minute).FindMany(&many)
+		assert.Equal(t, nil, err)
+		return
+	}
+	one, many := find()
+	assert.EqualValues(t, 20, one.Age)
+	assert.Equal(t, 1, len(many))
+
+	// 直接`Exec`不会清掉缓存，读到的还是旧数据
+	_, err = db.Acquire().Exec("update "+tablename+" set age=? where id=?", 21, id)
+	assert.Equal(t, nil, err)
+	one, _ = find()
+	assert.EqualValues(t, 20, one.Age)
+	db.InvalidateKey(tablename, id)
+	one, many = find()
+	assert.EqualValues(t, 21, one.Age)
+	assert.EqualValues(t, 21, many[0].Age)
+
+	// 插入只清掉列表查询
+	_, err = db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": "cached", "age": 30})
+	assert.Equal(t, nil, err)
+	_, many = find()
+	assert.Equal(t, 2, len(many))
+
+	// 按主键更新
+	_, err = db.Acquire().Name(tablename).Where("id=?", id).UpdateMap(map[string]interface{}{"age": 22})
+	assert.Equal(t, nil, err)
+	one, _ = find()
+	assert.EqualValues(t, 22, one.Age)
+
+	// 其他条件的写入清掉整张表
+	_, err = db.Acquire().Name(tablename).Where("name=?", "cached").Delete()
+	assert.Equal(t, nil, err)
+	var rest []LittleOrm
+	err = db.Acquire().Name(tablename).Where("name=?", "cached").Cache(time.Minute).FindMany(&rest)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(rest))
+
+	// 事务中没提交的数据不能缓存给其他请求
+	rollback := errors.New("rollback")
+	err = db.Transaction(context.Background(), PropagationRequired, func(ctx context.Context) error {
+		_, err := db.From(ctx).Exec("insert into "+tablename+" (name, age) values (?, ?)", "cached", 40)
+		assert.Equal(t, nil, err)
+		var inTx []LittleOrm
+		err = db.From(ctx).Name(tablename).Where("name=?", "cached").Cache(time.Minute).FindMany(&inTx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, len(inTx))
+		return rollback
+	})
+	assert.Equal(t, rollback, err)
+	rest = nil
+	err = db.Acquire().Name(tablename).Where("name=?", "cached").Cache(time.Minute).FindMany(&rest)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(rest))
+}
+
+func TestEntityCache(t *testing.T) {
+	edb, err := Open("sqlite3", "file:"+t.TempDir()+"/entity.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer edb.Close()
+	_, err = edb.Acquire().RunScript(`
+create table region (id integer primary key, name varchar(32));
+insert into region values (1, 'east'), (2, 'west');
+`)
+	assert.Equal(t, nil, err)
+	var queries int32
+	edb.Use(func(next Executor) Executor {
+		return func(ctx context.Context, stmt *Statement) error {
+			if stmt.IsQuery() {
+				atomic.AddInt32(&queries, 1)
+				time.Sleep(10 * time.Millisecond)
+			}
+			return next(ctx, stmt)
+		}
+	})
+	edb.EntityCache(NewMemoryCache(0), time.Minute)
+
+	type region struct {
+		Id   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	find := func(id int) string {
+		var r region
+		err := edb.Acquire().Name("region").FindByPK(&r, id)
+		assert.Equal(t, nil, err)
+		return r.Name
+	}
+
+	// 同时查询只查一次数据库
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, "east", find(1))
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&queries))
+	assert.Equal(t, "east", find(1))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&queries))
+
+	// 写入清掉缓存，提交以后才执行`AfterCommit`
+	var hooked bool
+	err = edb.Transaction(context.Background(), PropagationRequired, func(ctx context.Context) error {
+		_, err := edb.From(ctx).Name("region").Where("id=?", 1).UpdateMap(map[string]interface{}{"name": "north"})
+		edb.AfterCommit(ctx, func() { hooked = true })
+		assert.False(t, hooked)
+		return err
+	})
+	assert.Equal(t, nil, err)
+	assert.True(t, hooked)
+	assert.Equal(t, "north", find(1))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&queries))
+
+	hooked = false
+	_ = edb.Transaction(context.Background(), PropagationRequired, func(ctx context.Context) error {
+		edb.AfterCommit(ctx, func() { hooked = true })
+		return errors.New("rollback")
+	})
+	assert.False(t, hooked)
+
+	assert.Equal(t, "north", find(1))
+	assert.Equal(t, "west", find(2))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&queries))
+}
+
+func TestPoolWait(t *testing.T) {
+	pdb, err := Open("sqlite3", "file:"+t.TempDir()+"/pool.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer pdb.Close()
+	pdb.SetMaxOpenConns(1)
+	var waited time.Duration
+	pdb.TrackPoolWait(true).Use(func(next Executor) Executor {
+		return func(ctx context.Context, stmt *Statement) error {
+			err := next(ctx, stmt)
+			waited = stmt.PoolWait
+			return err
+		}
+	})
+
+	// 占住唯一的连接，查询需要等待
+	conn, err := pdb.Conn(context.Background())
+	assert.Equal(t, nil, err)
+	time.AfterFunc(50*time.Millisecond, func() { conn.Close() })
+	var n int
+	err = pdb.Acquire().Get(&n, "select 1")
+	assert.Equal(t, nil, err)
+	assert.True(t, waited >= 40*time.Millisecond, waited)
+
+	_, err = pdb.Acquire().Exec("create table pool_wait (id int)")
+	assert.Equal(t, nil, err)
+	stats := pdb.QueryStats()
+	assert.EqualValues(t, 2, stats.Statements)
+	assert.True(t, stats.PoolWaitMax >= 40*time.Millisecond)
+	assert.True(t, stats.PoolWaitP50 < stats.PoolWaitMax)
+	assert.EqualValues(t, 1, stats.WaitCount)
+
+	// 结果集关闭以后连接放回连接池，唯一的连接不会被占住
+	rows, err := pdb.Acquire().Queryx("select 1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, rows.Close())
+	err = pdb.Acquire().QueryRowx("select 1").Scan(&n)
+	assert.Equal(t, nil, err)
+	for _, err := range FindSeq[int](pdb.Acquire().Name("pool_wait").What([]string{"id"})) {
+		assert.Equal(t, nil, err)
+	}
+	err = pdb.Acquire().Get(&n, "select 2")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestExists(t *testing.T) {
+	_, err := db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": "exists", "age": 40})
+	assert.Equal(t, nil, err)
+	ok, err := db.Acquire().Name(tablename).Where("name=?", "exists").Order("id desc").Exists()
+	assert.Equal(t, nil, err)
+	assert.True(t, ok)
+	ok, err = db.Acquire().Name(tablename).Where("name=?", "exists-not").Exists()
+	assert.Equal(t, nil, err)
+	assert.False(t, ok)
+
+	older, err := db.Acquire().Name(tablename+" o").Where("o.age>t.age and o.name=?", "exists").ExistsFragment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "exists (select 1 from "+tablename+" o where o.age>t.age and o.name=?)", older.SQL)
+	var names []string
+	err = db.Acquire().Name(tablename+" t").What([]string{"t.name"}).Where("t.name=?", "exists").
+		WhereFragment(older.Wrap("not ", "")).FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"exists"}, names)
+}
+
+func TestWhereInTuples(t *testing.T) {
+	columns := []string{"name", "age"}
+	assert.Equal(t, "(name, age) in ((?, ?), (?, ?))", tupleInWhere("mysql", columns, 2))
+	assert.Equal(t, "(name=? and age=?) or (name=? and age=?)", tupleInWhere("sqlite3", columns, 2))
+
+	_, err := db.Acquire().Name(tablename).InsertBatch(columns, []interface{}{"tuple-a", 1}, []interface{}{"tuple-b", 2}, []interface{}{"tuple-a", 2})
+	assert.Equal(t, nil, err)
+	var rows []LittleOrm
+	err = db.Acquire().Name(tablename).Where("name like ?", "tuple-%").
+		WhereInTuples(columns, [][]interface{}{{"tuple-a", 1}, {"tuple-b", 2}}).Order("name").FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "tuple-b", rows[1].Name)
+
+	err = db.Acquire().Name(tablename).WhereInTuples(columns, nil).FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(rows))
+	err = db.Acquire().Name(tablename).WhereInTuples(columns, [][]interface{}{{"tuple-a"}}).FindMany(&rows)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRefresh(t *testing.T) {
+	tx, err := db.Beginx()
+	assert.Equal(t, nil, err)
+	defer tx.Rollback()
+	result, err := db.AcquireTx(tx).Name(tablename).Insert(map[string]interface{}{"name": "refresh", "age": 50})
+	assert.Equal(t, nil, err)
+	id, _ := result.LastInsertId()
+
+	little := LittleOrm{Id: uint64(id)}
+	err = db.AcquireTx(tx).Name(tablename).Refresh(&little)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "refresh", little.Name)
+	assert.EqualValues(t, 50, little.Age)
+	assert.False(t, little.CreatedAt.IsZero())
+
+	err = db.AcquireTx(tx).Name(tablename).Refresh(&LittleOrm{})
+	assert.True(t, errors.Is(err, ErrNoPrimaryKey))
+}
+
+func TestListener(t *testing.T) {
+	_, err := db.NewListener()
+	assert.True(t, errors.Is(err, ErrUnsupportedListener))
+	err = db.Acquire().Notify("user_changed", "1")
+	assert.True(t, errors.Is(err, ErrUnsupportedListener))
+
+	pdb := newDB(sqlx.NewDb(nil, "postgres"), time.Second)
+	_, err = pdb.NewListener()
+	assert.True(t, errors.Is(err, ErrUnsupportedListener))
+
+	// 不连接数据库也可以测试分发
+	l := &Listener{handlers: make(map[string][]NotifyHandler)}
+	var got []string
+	l.handlers["user_changed"] = []NotifyHandler{func(n *Notification) { got = append(got, n.Payload) }}
+	l.dispatch(&Notification{Channel: "user_changed", Payload: "1"})
+	l.dispatch(&Notification{Channel: "other", Payload: "2"})
+	assert.Equal(t, []string{"1"}, got)
+}
+
+func TestPosition(t *testing.T) {
+	_, err := db.CurrentPosition(context.Background())
+	assert.True(t, errors.Is(err, ErrUnsupportedPosition))
+	err = WaitForPosition(context.Background(), db, Position{Dialect: "mysql", Value: "uuid:1-5"}, time.Second)
+	assert.True(t, errors.Is(err, ErrUnsupportedPosition))
+	assert.Equal(t, "postgres:0/16B3748", Position{Dialect: "postgres", Value: "0/16B3748"}.String())
+}
+
+func TestExecIdempotent(t *testing.T) {
+	idb, err := Open("sqlite3", "file:"+t.TempDir()+"/idempotent.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer idb.Close()
+	_, err = idb.Acquire().RunScript(`
+create table littleorm_idempotency (token varchar(64) primary key, created_at datetime not null default current_timestamp);
+create table account (id integer primary key, balance int);
+insert into account values (1, 100);
+`)
+	assert.Equal(t, nil, err)
+	pay := func(token string) error {
+		_, err := idb.Acquire().ExecIdempotent(token, "update account set balance=balance-? where id=?", 10, 1)
+		return err
+	}
+	balance := func() (n int) {
+		assert.Equal(t, nil, idb.Acquire().Get(&n, "select balance from account where id=1"))
+		return
+	}
+	assert.Equal(t, nil, pay("req-1"))
+	assert.True(t, errors.Is(pay("req-1"), ErrDuplicateToken))
+	assert.Equal(t, 90, balance())
+
+	// 语句失败时`token`也回滚，可以重试
+	_, err = idb.Acquire().ExecIdempotent("req-2", "update account_missing set balance=0")
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, nil, pay("req-2"))
+	assert.Equal(t, 80, balance())
+
+	// 记录`token`的语句不带业务表的表名
+	var tables []string
+	idb.Use(func(next Executor) Executor {
+		return func(ctx context.Context, stmt *Statement) error {
+			tables = append(tables, stmt.Table)
+			return next(ctx, stmt)
+		}
+	})
+	_, err = idb.Acquire().Name("account").ExecIdempotent("req-3", "update account set balance=0 where id=?", 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"", "account"}, tables)
+}
+
+func TestAcquireContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	var little []LittleOrm
+	err := db.AcquireContext(cancelled).Name(tablename).FindMany(&little)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.AcquireContext(cancelled).Name(tablename).Where("id=?", 0).Delete()
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.AcquireContext(cancelled).RunScript("select 1; select 2")
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// `ctx`中的事务也会使用
+	err = db.Transaction(context.Background(), PropagationRequired, func(ctx context.Context) error {
+		c := db.AcquireContext(ctx)
+		defer c.release()
+		assert.Equal(t, db.TxFromContext(ctx), c.tx)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	err = db.Acquire().Name(tablename).FindMany(&little)
+	assert.Equal(t, nil, err)
+}
+
+func TestCounterCache(t *testing.T) {
+	cdb, err := Open("sqlite3", "file:"+t.TempDir()+"/counter.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer cdb.Close()
+	_, err = cdb.Acquire().RunScript(`
+create table user (id integer primary key, order_count int not null default 0);
+create table orders (id integer primary key, user_id int, amount int);
+insert into user (id) values (1), (2);
+`)
+	assert.Equal(t, nil, err)
+	rule := CounterRule{Parent: "user", Counter: "order_count", Child: "orders", ForeignKey: "user_id"}
+	cdb.CounterCache(rule)
+	counts := func() (n []int) {
+		assert.Equal(t, nil, cdb.Acquire().Select(&n, "select order_count from user order by id"))
+		return
+	}
+
+	_, err = cdb.Acquire().Name("orders").Insert(map[string]interface{}{"user_id": 1, "amount": 10})
+	assert.Equal(t, nil, err)
+	_, err = cdb.Acquire().Name("orders").InsertBatch([]string{"user_id", "amount"}, []interface{}{1, 20}, []interface{}{2, 30}, []interface{}{nil, 40})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int{2, 1}, counts())
+
+	n, err := cdb.Acquire().Name("orders").Where("amount<?", 25).Delete()
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 2, n)
+	assert.Equal(t, []int{0, 1}, counts())
+
+	// 插入失败时计数也回滚
+	_, err = cdb.Acquire().Name("orders").InsertBatch([]string{"user_id", "missing"}, []interface{}{2, 1})
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, []int{0, 1}, counts())
+
+	_, err = cdb.Acquire().Exec("insert into orders (user_id, amount) values (1, 50)")
+	assert.Equal(t, nil, err)
+	_, err = cdb.RecountCounter(context.Background(), rule)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int{1, 1}, counts())
+}
+
+// 用`?N`编号占位符测试替换，sqlite3也支持这种写法
+type numberedDialect struct {
+	sqliteDialect
+	bound *int32
+}
+
+func (d numberedDialect) Placeholder(n int) string {
+	atomic.AddInt32(d.bound, 1)
+	return fmt.Sprintf("?%d", n)
+}
+
+func TestDialect(t *testing.T) {
+	pg := newDB(sqlx.NewDb(nil, "postgres"), time.Second)
+	assert.Equal(t, "postgres", pg.Dialect().Name())
+	ctx := pg.Acquire().Name("t").What([]string{"id"}).Where("a=? and b='?'", 1).Limit(10).Offset(20).
+		LockingClause(LockOptions{Mode: LockShare})
+	query := ctx.sqlselect(nil)
+	ctx.release()
+	assert.Equal(t, "select id from t where a=? and b='?' limit 10 offset 20 for share", query)
+	assert.Equal(t, "select id from t where a=$1 and b='?' /* ? */ and c=$2", bindQuery(pg.Dialect(), "select id from t where a=? and b='?' /* ? */ and c=?"))
+	assert.Equal(t, "select id from t where data ? 'a' and id=$1 and tags ?| array[$2]", bindQuery(pg.Dialect(), "select id from t where data ?? 'a' and id=? and tags ??| array[?]"))
+	assert.Equal(t, "select id from t where data ? 'a' and id=?", bindQuery(mysqlDialect{}, "select id from t where data ?? 'a' and id=?"))
+	assert.Equal(t, `select id from t where name like $1 escape '\' and id=$2`, bindQuery(pg.Dialect(), `select id from t where name like ? escape '\' and id=?`))
+	assert.Equal(t, 2, countPlaceholders("data ?? 'a' and id=? and tags ??| array[?]"))
+	assert.Equal(t, `"order"`, pg.Quote("order"))
+	assert.Equal(t, "`order`", newDB(sqlx.NewDb(nil, "mysql"), time.Second).Quote("order"))
+
+	ndb, err := Open("sqlite3", "file:"+t.TempDir()+"/dialect.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer ndb.Close()
+	var bound int32
+	ndb.SetDialect(numberedDialect{bound: &bound})
+	_, err = ndb.Acquire().Create("create table t (id integer primary key, name varchar(10))")
+	assert.Equal(t, nil, err)
+	_, err = ndb.Acquire().Name("t").InsertBatch([]string{"id", "name"}, []interface{}{1, "a"}, []interface{}{2, "b"})
+	assert.Equal(t, nil, err)
+	var names []string
+	err = ndb.Acquire().Name("t").What([]string{"name"}).Where("id>?", 0).Where("name<>?", "a").FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"b"}, names)
+	assert.True(t, atomic.LoadInt32(&bound) >= 6)
+
+	// 直接执行的语句也要按方言替换占位符
+	before := atomic.LoadInt32(&bound)
+	n, err := ndb.Acquire().Name("t").Where("id>?", 0).Anonymize(map[string]AnonymizeFunc{"name": func(key, value string) string { return "x" + key }})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), n)
+	assert.True(t, atomic.LoadInt32(&bound) > before)
+
+	// `SetDialect`以后引号和检查都按新的方言
+	custom := newDB(sqlx.NewDb(nil, "mysql"), time.Second).SetDialect(postgresDialect{})
+	assert.Equal(t, `"t"."id"`, custom.quoteIdent("t.id"))
+	assert.Equal(t, 1, len(custom.Lint("select `id` from t")))
+}
+
+func TestSoftSchema(t *testing.T) {
+	type next struct {
+		Id       uint64 `db:"id"`
+		Name     string `db:"name"`
+		Nickname string `db:"nickname"` //还没有加到表中的字段
+	}
+	type prev struct {
+		Id   uint64 `db:"id"`
+		Name string `db:"name"`
+	}
+	_, err := db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": "soft", "age": 60})
+	assert.Equal(t, nil, err)
+
+	var rows []next
+	err = db.Acquire().Name(tablename).Where("name=?", "soft").FindMany(&rows)
+	assert.NotEqual(t, nil, err)
+	var olds []prev
+	err = db.Acquire().Select(&olds, "select * from "+tablename+" where name=?", "soft")
+	assert.NotEqual(t, nil, err)
+
+	SoftSchema(next{})
+	SoftSchema(prev{})
+	err = db.Acquire().Name(tablename).Where("name=?", "soft").FindMany(&rows)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "", rows[0].Nickname)
+	err = db.Acquire().Select(&olds, "select * from "+tablename+" where name=?", "soft")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "soft", olds[0].Name)
+}
+
+func TestOrWhere(t *testing.T) {
+	d := newDB(sqlx.NewDb(nil, "mysql"), time.Second)
+	ctx := d.Acquire().Name("t").What([]string{"id"}).
+		WhereGroup(func(g *Condition) {
+			g.Where("a=?", 1).OrWhere("b=?", 2).Group(func(g *Condition) {
+				g.Where("c=?", 3).Where("d=?", 4)
+			})
+		}).Where("e=?", 5)
+	assert.Equal(t, "select id from t where ((a=?) or (b=?) and ((c=?) and (d=?))) and e=?", ctx.sqlselect(nil))
+	assert.Equal(t, []interface{}{1, 2, 3, 4, 5}, ctx.whereArgs)
+	ctx.release()
+
+	ctx = d.Acquire().Name("t").What([]string{"id"}).Where("a=?", 1).Where("b=?", 2).OrWhere("c=?", 3)
+	assert.Equal(t, "select id from t where ((a=?) and (b=?) or (c=?))", ctx.sqlselect(nil))
+	ctx.release()
+	ctx = d.Acquire().Name("t").What([]string{"id"}).Where("a=?", 1).OrWhere("b=?", 2).Where("c=?", 3)
+	assert.Equal(t, "select id from t where ((a=?) or (b=?)) and c=?", ctx.sqlselect(nil))
+	ctx.release()
+
+	ctx = d.Acquire().WhereGroup(func(g *Condition) { g.Where("a=?") })
+	assert.True(t, errors.Is(ctx.err, ErrPlaceholderMismatch))
+	ctx.release()
+
+	_, err := db.Acquire().Name(tablename).InsertBatch([]string{"name", "age"}, []interface{}{"or-a", 1}, []interface{}{"or-b", 2}, []interface{}{"or-c", 3})
+	assert.Equal(t, nil, err)
+	var names []string
+	err = db.Acquire().Name(tablename).What([]string{"name"}).
+		WhereGroup(func(g *Condition) { g.Where("name=?", "or-a").OrWhere("name=?", "or-c") }).
+		Where("age>?", 1).FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"or-c"}, names)
+
+	// 后面的条件要和整个`or`用`and`连接，不能只限制最后一个条件
+	names = nil
+	err = db.Acquire().Name(tablename).What([]string{"name"}).Where("name=?", "or-a").OrWhere("name=?", "or-c").
+		Where("age<?", 3).FindMany(&names)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"or-a"}, names)
+}
+
+func TestTableRouter(t *testing.T) {
+	rdb, err := Open("sqlite3", "file:"+t.TempDir()+"/router.db", time.Second)
+	assert.Equal(t, nil, err)
+	defer rdb.Close()
+	_, err = rdb.Acquire().RunScript(`
+create table events_202401 (id integer primary key, name varchar(10));
+create table events_202402 (id integer primary key, name varchar(10));
+create table events_202403 (id integer primary key, name varchar(10));
+`)
+	assert.Equal(t, nil, err)
+	rdb.RouteTable("events", MonthlyRouter{})
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 15, 0, 0, 0, 0, time.UTC) }
+	for i, m := range []time.Month{1, 1, 3} {
+		_, err = rdb.Acquire().Name("events").Route(month(m)).Insert(map[string]interface{}{"id": i + 1, "name": fmt.Sprintf("e%d", i+1)})
+		assert.Equal(t, nil, err)
+	}
+
+	type event struct {
+		Id   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	var events []event
+	err = rdb.Acquire().Name("events").RouteRange(month(1), month(3)).Order("id desc").FindMany(&events)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []event{{2, "e2"}, {1, "e1"}, {3, "e3"}}, events)
+	err = rdb.Acquire().Name("events").RouteRange(month(1), month(3)).Order("id").Limit(2).FindMany(&events)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(events))
+
+	var one event
+	err = rdb.Acquire().Name("events").RouteRange(month(2), month(3)).FindOne(&one)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "e3", one.Name)
+
+	_, err = rdb.Acquire().Name("events").RouteRange(month(1), month(3)).Delete()
+	assert.True(t, errors.Is(err, ErrTableRoute))
+	err = rdb.Acquire().Name("other").Route(month(1)).FindOne(&one)
+	assert.True(t, errors.Is(err, ErrTableRoute))
+}
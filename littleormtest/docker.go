@@ -0,0 +1,115 @@
+package littleormtest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/lujin123/littleorm"
+)
+
+// 等待容器中的数据库启动的最长时间
+var StartupTimeout = 2 * time.Minute
+
+// 启动一个临时的`MySQL`容器，返回连接好的数据库，测试结束以后自动删除容器
+// 需要本地安装了`docker`，没有安装直接跳过测试，镜像可以通过环境变量`LITTLEORM_MYSQL_IMAGE`指定，默认`mysql:5.7`
+func StartMySQL(tb testing.TB) *littleorm.DB {
+	tb.Helper()
+	return startContainer(tb, container{
+		image:  imageFromEnv("LITTLEORM_MYSQL_IMAGE", "mysql:5.7"),
+		port:   "3306/tcp",
+		env:    []string{"MYSQL_ROOT_PASSWORD=littleorm", "MYSQL_DATABASE=littleorm"},
+		driver: "mysql",
+		dsn: func(addr string) string {
+			return fmt.Sprintf("root:littleorm@tcp(%s)/littleorm?charset=utf8mb4&parseTime=true", addr)
+		},
+	})
+}
+
+// 启动一个临时的`PostgreSQL`容器，返回连接好的数据库，测试结束以后自动删除容器
+// 镜像可以通过环境变量`LITTLEORM_POSTGRES_IMAGE`指定，默认`postgres:13`
+func StartPostgres(tb testing.TB) *littleorm.DB {
+	tb.Helper()
+	return startContainer(tb, container{
+		image:  imageFromEnv("LITTLEORM_POSTGRES_IMAGE", "postgres:13"),
+		port:   "5432/tcp",
+		env:    []string{"POSTGRES_PASSWORD=littleorm", "POSTGRES_DB=littleorm"},
+		driver: "postgres",
+		dsn: func(addr string) string {
+			return fmt.Sprintf("postgres://postgres:littleorm@%s/littleorm?sslmode=disable", addr)
+		},
+	})
+}
+
+type container struct {
+	image  string
+	port   string
+	env    []string
+	driver string
+	dsn    func(addr string) string
+}
+
+func startContainer(tb testing.TB, c container) *littleorm.DB {
+	tb.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		tb.Skip("littleormtest: docker not found, skip")
+	}
+
+	args := []string{"run", "-d", "--rm", "-p", "127.0.0.1::" + strings.Split(c.port, "/")[0]}
+	for _, env := range c.env {
+		args = append(args, "-e", env)
+	}
+	id, err := docker(append(args, c.image)...)
+	if err != nil {
+		tb.Fatalf("littleormtest: start %s failed, err: %v", c.image, err)
+	}
+	tb.Cleanup(func() {
+		docker("rm", "-f", id)
+	})
+
+	addr, err := docker("port", id, c.port)
+	if err != nil {
+		tb.Fatalf("littleormtest: get port of %s failed, err: %v", c.image, err)
+	}
+	// 可能同时返回ipv4和ipv6的地址，取第一个就好
+	addr = strings.Split(addr, "\n")[0]
+
+	db, err := littleorm.Open(c.driver, c.dsn(addr), 10*time.Second)
+	if err != nil {
+		tb.Fatalf("littleormtest: open %s failed, err: %v", c.driver, err)
+	}
+	tb.Cleanup(func() {
+		db.Close()
+	})
+
+	deadline := time.Now().Add(StartupTimeout)
+	for {
+		if err = db.Ping(); err == nil {
+			return db
+		}
+		if time.Now().After(deadline) {
+			tb.Fatalf("littleormtest: wait for %s timeout, err: %v", c.image, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %v: %s", args[0], err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func imageFromEnv(key, image string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return image
+}
@@ -0,0 +1,161 @@
+package littleormtest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lujin123/littleorm"
+)
+
+// 设置了这个环境变量的话`Golden`会把生成的`SQL`写回文件，而不是比较
+// eg: LITTLEORM_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "LITTLEORM_UPDATE_GOLDEN"
+
+// 拦截`SQL`以后返回的错误，查询不会真正执行
+var errCaptured = errors.New("littleormtest: captured")
+
+// 把注册的查询生成的`SQL`和文件中记录的比较，`SQL`有变化的话测试失败
+// 像代码一样评审查询的变化，修改是预期内的话用`LITTLEORM_UPDATE_GOLDEN=1`重新生成
+type Golden struct {
+	tb   testing.TB
+	db   *littleorm.DB
+	path string
+
+	mu      sync.Mutex
+	current []string
+	queries map[string][]string //名字 => 生成的`SQL`和参数
+}
+
+// 创建`Golden`，`driver`决定生成`SQL`的方言，需要自己导入对应的驱动，`path`是记录`SQL`的文件
+// 查询不会连接数据库，`SQL`在执行前就被拦截了，所以事务之类需要连接的操作不能用
+// 测试结束时自动比较
+func NewGolden(tb testing.TB, driver, path string) *Golden {
+	tb.Helper()
+	db, err := littleorm.Open(driver, "", time.Second)
+	if err != nil {
+		tb.Fatalf("littleormtest: open %s failed, err: %v", driver, err)
+	}
+	g := &Golden{tb: tb, db: db, path: path, queries: make(map[string][]string)}
+	db.InjectFaults(littleorm.Fault{
+		Kind: littleorm.FaultError,
+		Match: func(query string, args []interface{}) bool {
+			g.mu.Lock()
+			// 记录替换占位符以后的语句，postgres记录的是`$1`
+			g.current = append(g.current, db.BindQuery(query), fmt.Sprintf("-- args: %#v", args))
+			g.mu.Unlock()
+			return true
+		},
+		Err: errCaptured,
+	})
+	tb.Cleanup(func() {
+		g.check()
+		db.Close()
+	})
+	return g
+}
+
+// 注册一个查询，`query`中用传入的`db`构造查询，可以执行多条语句，名字不能重复
+func (g *Golden) Add(name string, query func(db *littleorm.DB) error) {
+	g.tb.Helper()
+	if _, ok := g.queries[name]; ok {
+		g.tb.Fatalf("littleormtest: duplicate golden query %q", name)
+	}
+	g.mu.Lock()
+	g.current = nil
+	g.mu.Unlock()
+	if err := query(g.db); err != nil && !errors.Is(err, errCaptured) {
+		g.tb.Errorf("littleormtest: golden query %q failed, err: %v", name, err)
+	}
+	g.mu.Lock()
+	g.queries[name] = g.current
+	g.mu.Unlock()
+}
+
+// 和文件比较或者更新文件
+func (g *Golden) check() {
+	g.tb.Helper()
+	actual := g.render()
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(g.path), 0755); err != nil {
+			g.tb.Fatalf("littleormtest: %v", err)
+		}
+		if err := os.WriteFile(g.path, []byte(actual), 0644); err != nil {
+			g.tb.Fatalf("littleormtest: %v", err)
+		}
+		return
+	}
+	content, err := os.ReadFile(g.path)
+	if err != nil {
+		g.tb.Fatalf("littleormtest: read golden file failed, run with %s=1 to create it, err: %v", UpdateGoldenEnv, err)
+	}
+	expected := parseGolden(string(content))
+	for _, name := range g.names() {
+		got := strings.Join(g.queries[name], "\n")
+		want, ok := expected[name]
+		switch {
+		case !ok:
+			g.tb.Errorf("littleormtest: golden query %q is not recorded in %s", name, g.path)
+		case got != want:
+			g.tb.Errorf("littleormtest: golden query %q changed\nwant:\n%s\ngot:\n%s", name, want, got)
+		}
+		delete(expected, name)
+	}
+	for name := range expected {
+		g.tb.Errorf("littleormtest: golden query %q is recorded in %s but not registered", name, g.path)
+	}
+	if g.tb.Failed() {
+		g.tb.Logf("littleormtest: run with %s=1 to update %s if the changes are expected", UpdateGoldenEnv, g.path)
+	}
+}
+
+func (g *Golden) names() []string {
+	names := make([]string, 0, len(g.queries))
+	for name := range g.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 文件格式：每个查询以`=== 名字`开头，后面是`SQL`和参数
+func (g *Golden) render() string {
+	var b strings.Builder
+	for _, name := range g.names() {
+		b.WriteString("=== " + name + "\n")
+		for _, line := range g.queries[name] {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func parseGolden(content string) map[string]string {
+	queries := make(map[string]string)
+	var (
+		name  string
+		lines []string
+	)
+	flush := func() {
+		if name != "" {
+			queries[name] = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+		}
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "=== ") {
+			flush()
+			name, lines = strings.TrimPrefix(line, "=== "), nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return queries
+}
@@ -0,0 +1,47 @@
+// 测试辅助工具，提供基于内存`SQLite`的数据库，不用依赖真实的`MySQL`就能跑单元测试
+package littleormtest
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lujin123/littleorm"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var seq int64
+
+// 打开一个内存`SQLite`数据库，并依次执行`schema`中的建表语句，测试结束以后自动关闭
+// 每次调用都是一个全新的数据库，互不影响
+func Open(tb testing.TB, schema ...string) *littleorm.DB {
+	tb.Helper()
+	db, err := OpenMemory(schema...)
+	if err != nil {
+		tb.Fatalf("littleormtest: %v", err)
+	}
+	tb.Cleanup(func() {
+		db.Close()
+	})
+	return db
+}
+
+// 打开一个内存`SQLite`数据库，并依次执行`schema`中的建表语句，用完需要自己关闭
+// 没有`testing.TB`的地方用，比如`Example`
+func OpenMemory(schema ...string) (*littleorm.DB, error) {
+	name := fmt.Sprintf("file:littleormtest-%d?mode=memory&cache=shared", atomic.AddInt64(&seq, 1))
+	db, err := littleorm.Open("sqlite3", name, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite failed, err: %v", err)
+	}
+	// 内存数据库每个连接都是独立的，只能用一个连接
+	db.SetMaxOpenConns(1)
+	for _, sql := range schema {
+		if _, err = db.Acquire().Create(sql); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("create schema failed, err: %v", err)
+		}
+	}
+	return db, nil
+}
@@ -0,0 +1,107 @@
+package littleormtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lujin123/littleorm"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Id   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestOpen(t *testing.T) {
+	db := Open(t, "create table item (id integer primary key autoincrement, name varchar(32) not null)")
+	_, err := db.Acquire().Name("item").Insert(map[string]interface{}{"name": "allen"})
+	assert.Equal(t, nil, err)
+
+	var items []item
+	err = db.Acquire().Name("item").FindMany(&items)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(items))
+
+	// 每次打开的都是新的数据库
+	other := Open(t)
+	err = other.Acquire().Name("item").FindMany(&items)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestStartMySQL(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip docker test in short mode")
+	}
+	db := StartMySQL(t)
+	var one int
+	err := db.Acquire().Get(&one, "select 1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, one)
+}
+
+func TestStartPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip docker test in short mode")
+	}
+	db := StartPostgres(t)
+	var one int
+	err := db.Acquire().Get(&one, "select 1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, one)
+}
+
+func TestGolden(t *testing.T) {
+	g := NewGolden(t, "sqlite3", "testdata/golden.sql")
+	g.Add("find adults", func(db *littleorm.DB) error {
+		var items []item
+		return db.Acquire().Name("item").Where("age>=?", 18).Order("id desc").Limit(10).FindMany(&items)
+	})
+	g.Add("rename", func(db *littleorm.DB) error {
+		_, err := db.Acquire().Name("item").Where("id=?", 1).Update("name=?", "bob")
+		return err
+	})
+}
+
+func TestGoldenPostgres(t *testing.T) {
+	g := NewGolden(t, "postgres", "testdata/golden_postgres.sql")
+	g.Add("join values", func(db *littleorm.DB) error {
+		var items []item
+		v := littleorm.Values([]string{"vid", "score"}, []interface{}{1, 90}, []interface{}{2, 80})
+		return db.Acquire().Name("item").JoinValues(v, "v", "v.vid = item.id").Where("age>=?", 18).FindMany(&items)
+	})
+}
+
+// 记录错误但不让测试失败，用来验证`Golden`能发现变化
+type recordTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recordTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordTB) Cleanup(fn func()) {
+	r.cleanups = append(r.cleanups, fn)
+}
+
+func (r *recordTB) Failed() bool {
+	return len(r.errors) > 0
+}
+
+func TestGoldenChanged(t *testing.T) {
+	tb := &recordTB{TB: t}
+	g := NewGolden(tb, "sqlite3", "testdata/golden.sql")
+	g.Add("find adults", func(db *littleorm.DB) error {
+		var items []item
+		return db.Acquire().Name("item").Where("age>?", 18).Order("id desc").Limit(10).FindMany(&items)
+	})
+	for _, fn := range tb.cleanups {
+		fn()
+	}
+	assert.Equal(t, 2, len(tb.errors))
+	assert.Contains(t, tb.errors[0], `"find adults" changed`)
+	assert.Contains(t, tb.errors[1], `"rename" is recorded`)
+}
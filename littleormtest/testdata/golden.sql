@@ -0,0 +1,8 @@
+=== find adults
+select id, name from item where age>=? order by id desc limit 0, 10
+-- args: []interface {}{18}
+
+=== rename
+update item set name=? where id=?
+-- args: []interface {}{"bob", 1}
+
@@ -0,0 +1,4 @@
+=== join values
+select id, name from item join (values ($1::bigint, $2::bigint), ($3::bigint, $4::bigint)) as v (vid, score) on v.vid = item.id where age>=$5
+-- args: []interface {}{1, 90, 2, 80, 18}
+
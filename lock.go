@@ -0,0 +1,96 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedLock = errors.New("littleorm: unsupported locking clause")
+
+// 行锁的类型
+type LockMode int
+
+const (
+	LockNone   LockMode = iota
+	LockShare           //共享锁(S锁)
+	LockUpdate          //排他锁(X锁)
+)
+
+// 遇到已经被锁住的行时怎么处理
+type LockWait int
+
+const (
+	LockWaitDefault LockWait = iota //等待，直到超时
+	LockNoWait                      //不等待，直接报错
+	LockSkipLocked                  //跳过被锁住的行，适合任务队列
+)
+
+// 锁定子句的选项
+type LockOptions struct {
+	Mode   LockMode
+	Tables []string //只锁定关联查询中的这些表，为空锁定所有表
+	Wait   LockWait
+}
+
+// 指定锁定子句，根据数据库生成对应的语法，数据库不支持的组合执行时返回`ErrUnsupportedLock`
+// mysql: lock in share mode / for update，指定了`Tables`或者`Wait`时用`8.0`的 for share / for update of t nowait
+// postgres: for share / for update of t nowait / skip locked
+// sqlite3: 不支持行锁，只指定`Mode`时直接忽略，指定了`Tables`或者`Wait`时报错
+// eg: db.AcquireTx(tx).Name("job").Where("status=?", 0).Limit(10).LockingClause(littleorm.LockOptions{Mode: littleorm.LockUpdate, Wait: littleorm.LockSkipLocked})
+func (ctx *Context) LockingClause(opts LockOptions) *Context {
+	if _, err := ctx.db.dialect.LockClause(opts); err != nil {
+		if ctx.err == nil {
+			ctx.err = err
+		}
+		return ctx
+	}
+	ctx.lock = opts
+	return ctx
+}
+
+// 生成锁定子句，包括前面的空格
+func lockClause(driver string, opts LockOptions) (string, error) {
+	if opts.Mode == LockNone {
+		if len(opts.Tables) != 0 || opts.Wait != LockWaitDefault {
+			return "", fmt.Errorf("%w: tables or wait without lock mode", ErrUnsupportedLock)
+		}
+		return "", nil
+	}
+	if opts.Mode != LockShare && opts.Mode != LockUpdate {
+		return "", fmt.Errorf("%w: unknown lock mode %d", ErrUnsupportedLock, opts.Mode)
+	}
+	plain := len(opts.Tables) == 0 && opts.Wait == LockWaitDefault
+	switch driver {
+	case "sqlite3":
+		if !plain {
+			return "", fmt.Errorf("%w: sqlite3 does not support row locks", ErrUnsupportedLock)
+		}
+		return "", nil
+	case "mysql":
+		// 兼容`5.7`，简单的锁还是用老的语法
+		if plain {
+			if opts.Mode == LockShare {
+				return " lock in share mode", nil
+			}
+			return " for update", nil
+		}
+	}
+
+	clause := " for update"
+	if opts.Mode == LockShare {
+		clause = " for share"
+	}
+	if len(opts.Tables) != 0 {
+		clause += " of " + sqljoin(opts.Tables, SeqComma)
+	}
+	switch opts.Wait {
+	case LockWaitDefault:
+	case LockNoWait:
+		clause += " nowait"
+	case LockSkipLocked:
+		clause += " skip locked"
+	default:
+		return "", fmt.Errorf("%w: unknown lock wait %d", ErrUnsupportedLock, opts.Wait)
+	}
+	return clause, nil
+}
@@ -0,0 +1,49 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 遇到锁等待超时时用`timeout`作为超时时间重试一次，`LockNoWait`的锁重试时改成等待
+// 锁等待超时包括 mysql: 1205、3572(nowait)，postgres: 55P03，SQLite: database is locked，带锁的查询超过超时时间也算
+// `postgres`的事务中出错以后整个事务都不能用了，不会重试；死锁会回滚整个事务，也不会重试
+// 事务中带锁的查询超时也不重试，超时取消查询会关掉事务的连接
+// 只对`FindOne`、`FindMany`和`Insert`、`Update`、`Delete`这些单条语句有效，拆分执行和分表查询不重试
+// eg: db.AcquireTx(tx).Name("stock").Where("sku=?", sku).LockingClause(opts).RetryOnLock(5 * time.Second).FindOne(&stock)
+func (ctx *Context) RetryOnLock(timeout time.Duration) *Context {
+	ctx.lockRetry = timeout
+	return ctx
+}
+
+// 是否需要重试，需要的话修改超时时间和锁，只重试一次
+func (ctx *Context) retryLocked(err error, built bool) bool {
+	if err == nil || ctx.lockRetry <= 0 || !ctx.lockTimeout(err) {
+		return false
+	}
+	if ctx.tx != nil && ctx.db.dialect.Name() == "postgres" {
+		return false
+	}
+	// 超时取消查询时驱动会关掉连接，事务已经不能用了
+	if ctx.tx != nil && errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if ctx.parent != nil && ctx.parent.Err() != nil {
+		return false
+	}
+	ctx.timeout, ctx.lockRetry = ctx.lockRetry, 0
+	if built && ctx.lock.Wait == LockNoWait {
+		ctx.lock.Wait = LockWaitDefault
+		ctx.sql = ""
+	}
+	return true
+}
+
+// 是否是锁等待超时的错误
+func (ctx *Context) lockTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ctx.lock.Mode != LockNone
+	}
+	return IsLockTimeout(err)
+}
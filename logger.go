@@ -0,0 +1,86 @@
+package littleorm
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 日志级别，低于设置级别的日志不输出
+type LogLevel int
+
+const (
+	LogDebug  LogLevel = iota //执行的语句
+	LogInfo                   //兼容性检查、宽松表结构这些提示
+	LogError                  //后台同步、缓存这些不影响返回结果的错误
+	LogSilent                 //不输出
+)
+
+// 日志中的字段
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// 日志接口，可以接到zap、zerolog这些日志库
+type Logger interface {
+	Debug(msg string, fields ...LogField)
+	Info(msg string, fields ...LogField)
+	Error(msg string, fields ...LogField)
+}
+
+// 设置日志和级别，默认不输出日志，只在初始化的时候调用
+// 需要和以前一样输出到标准库的`log`可以用`NewStdLogger(nil)`
+// eg: db.Logger(littleorm.NewStdLogger(nil), littleorm.LogDebug)
+func (db *DB) Logger(logger Logger, level LogLevel) *DB {
+	db.logger, db.logLevel = logger, level
+	return db
+}
+
+func (db *DB) logDebug(msg string, fields ...LogField) {
+	if db.logger != nil && db.logLevel <= LogDebug {
+		db.logger.Debug(msg, fields...)
+	}
+}
+
+func (db *DB) logInfo(msg string, fields ...LogField) {
+	if db.logger != nil && db.logLevel <= LogInfo {
+		db.logger.Info(msg, fields...)
+	}
+}
+
+func (db *DB) logError(msg string, fields ...LogField) {
+	if db.logger != nil && db.logLevel <= LogError {
+		db.logger.Error(msg, fields...)
+	}
+}
+
+// 语句日志的字段
+func sqlFields(query string, args []interface{}) []LogField {
+	return []LogField{{"sql", query}, {"args", args}}
+}
+
+// 输出到标准库`log.Logger`的日志，格式是`[级别] 消息 key=value ...`，`l`为`nil`时用`log.Default()`
+func NewStdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s stdLogger) Debug(msg string, fields ...LogField) { s.print("debug", msg, fields) }
+func (s stdLogger) Info(msg string, fields ...LogField)  { s.print("info", msg, fields) }
+func (s stdLogger) Error(msg string, fields ...LogField) { s.print("error", msg, fields) }
+
+func (s stdLogger) print(level, msg string, fields []LogField) {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "[%s] %s", level, msg)
+	for _, f := range fields {
+		fmt.Fprintf(&buf, " %s=%#v", f.Key, f.Value)
+	}
+	s.l.Print(buf.String())
+}
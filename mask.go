@@ -0,0 +1,104 @@
+package littleorm
+
+import (
+	"reflect"
+	"strings"
+)
+
+// 字段脱敏函数，入参是数据库中的原始值，返回脱敏以后的值
+type MaskFunc func(value string) string
+
+// 配置表`table`中字段`column`的脱敏函数
+// 查询时如果`Context`指定的角色不在特权角色中，扫描出来的结果会用脱敏函数处理一遍，没有指定角色的也算非特权角色
+// `Preload`加载的关联数据没有角色，总是脱敏；`Queryx`、`QueryRowx`直接返回驱动的结果，不会脱敏
+// 只处理`string`类型的字段，其他类型的字段不会处理
+func (db *DB) Mask(table, column string, fn MaskFunc) *DB {
+	db.maskMu.Lock()
+	defer db.maskMu.Unlock()
+	if db.masks == nil {
+		db.masks = make(map[string]map[string]MaskFunc)
+	}
+	if db.masks[table] == nil {
+		db.masks[table] = make(map[string]MaskFunc)
+	}
+	db.masks[table][column] = fn
+	return db
+}
+
+// 指定特权角色，特权角色查询到的是原始数据
+func (db *DB) PrivilegedRoles(roles ...string) *DB {
+	db.maskMu.Lock()
+	defer db.maskMu.Unlock()
+	if db.privileged == nil {
+		db.privileged = make(map[string]bool)
+	}
+	for _, role := range roles {
+		db.privileged[role] = true
+	}
+	return db
+}
+
+// 邮箱脱敏，保留首字母和域名，eg: allen@gmail.com => a****@gmail.com
+func MaskEmail(value string) string {
+	at := strings.Index(value, "@")
+	if at <= 0 {
+		return MaskMiddle(value)
+	}
+	return value[:1] + strings.Repeat("*", at-1) + value[at:]
+}
+
+// 手机号脱敏，保留前三位和后四位，eg: 13812345678 => 138****5678
+func MaskPhone(value string) string {
+	if len(value) < 8 {
+		return MaskMiddle(value)
+	}
+	return value[:3] + strings.Repeat("*", len(value)-7) + value[len(value)-4:]
+}
+
+// 通用脱敏，只保留首尾字符
+func MaskMiddle(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
+
+// 对查询结果进行脱敏
+func (ctx *Context) mask(dest interface{}) {
+	if ctx.name == "" {
+		return
+	}
+	ctx.db.mask(ctx.name, ctx.role, dest)
+}
+
+// 按表`table`的配置对结果脱敏，`role`是特权角色的话不处理，空角色也要脱敏
+func (db *DB) mask(table, role string, dest interface{}) {
+	db.maskMu.RLock()
+	defer db.maskMu.RUnlock()
+	if db.privileged[role] {
+		return
+	}
+	columns := db.masks[table]
+	if len(columns) == 0 {
+		return
+	}
+	if rows, ok := dest.(*[]map[string]interface{}); ok {
+		for _, row := range *rows {
+			for column, fn := range columns {
+				if s, ok := row[column].(string); ok {
+					row[column] = fn(s)
+				}
+			}
+		}
+		return
+	}
+	eachStruct(dest, func(v reflect.Value) {
+		for column, fn := range columns {
+			field, ok := fieldByTag(v, column)
+			if ok && field.Kind() == reflect.String && field.CanSet() {
+				field.SetString(fn(field.String()))
+			}
+		}
+	})
+}
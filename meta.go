@@ -0,0 +1,60 @@
+package littleorm
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// 查询结果的字段信息，驱动不支持的信息为零值
+type ColumnMeta struct {
+	Name      string
+	Type      string       //数据库中的类型，eg: VARCHAR、INT、DECIMAL，SQLite中没有声明类型的表达式为空
+	Nullable  bool         //是否可以为`NULL`，驱动不知道时为`true`
+	Length    int64        //变长类型的长度，eg: varchar(20)的20
+	Precision int64        //`DECIMAL`的精度
+	Scale     int64        //`DECIMAL`的小数位数
+	ScanType  reflect.Type //驱动扫描时使用的类型
+}
+
+// 查询多条记录到`map`中，同时返回字段信息，给通用的导出、后台表格这类不知道表结构的场景使用
+// 没有指定`Coerce`时默认把文本字段转成字符串，和`FindMany`一样会做字段脱敏
+// eg: columns, err := db.Acquire().Name("user").Where("age>?", 18).FindManyMap(&rows)
+func (ctx *Context) FindManyMap(dest *[]map[string]interface{}) ([]ColumnMeta, error) {
+	if ctx.coerce == nil {
+		ctx.Coerce(Coercion{Text: true})
+	}
+	// `FindMany`执行以后`ctx`就放回池子了，字段信息不能放在`ctx`上
+	var columns []ColumnMeta
+	ctx.columns = &columns
+	err := ctx.FindMany(dest)
+	return columns, err
+}
+
+// 和`FindManyMap`一样，直接使用给定的`sql`和`args`
+// eg: columns, err := db.Acquire().QueryMeta(&rows, "select * from user where age>?", 18)
+func (ctx *Context) QueryMeta(dest *[]map[string]interface{}, sql string, args ...interface{}) ([]ColumnMeta, error) {
+	ctx.sql = sql
+	ctx.args = append(ctx.args[:0], args...)
+	return ctx.FindManyMap(dest)
+}
+
+// 转换驱动返回的字段信息
+func columnMetas(types []*sql.ColumnType) []ColumnMeta {
+	columns := make([]ColumnMeta, len(types))
+	for i, ct := range types {
+		nullable, ok := ct.Nullable()
+		columns[i] = ColumnMeta{
+			Name:     ct.Name(),
+			Type:     ct.DatabaseTypeName(),
+			Nullable: nullable || !ok,
+			ScanType: ct.ScanType(),
+		}
+		if length, ok := ct.Length(); ok {
+			columns[i].Length = length
+		}
+		if precision, scale, ok := ct.DecimalSize(); ok {
+			columns[i].Precision, columns[i].Scale = precision, scale
+		}
+	}
+	return columns
+}
@@ -0,0 +1,62 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// 要执行的语句，中间件可以修改`Query`和`Args`，比如加提示、改表名分表
+type Statement struct {
+	Query string
+	Args  []interface{}
+	Table string      //`Name`指定的表名，直接执行`SQL`时可能为空
+	Dest  interface{} //查询的目标对象，更新语句为`nil`
+	// 更新语句执行的结果，更新语句的中间件不往下执行的话需要自己设置
+	Result sql.Result
+	// 等待连接池分配连接的时间，开启`TrackPoolWait`以后执行完才有
+	PoolWait time.Duration
+
+	prepare bool //`PrepareNamed`的预编译，不执行，不触发生命周期钩子
+}
+
+// 是否是查询语句
+func (s *Statement) IsQuery() bool {
+	return s.Dest != nil
+}
+
+// 执行语句，查询的结果扫描到`stmt.Dest`中，更新的结果保存在`stmt.Result`中
+type Executor func(ctx context.Context, stmt *Statement) error
+
+// 中间件，包装下一个`Executor`，可以修改语句、直接返回结果(缓存)或者记录执行情况
+// eg:
+//
+//	db.Use(func(next littleorm.Executor) littleorm.Executor {
+//		return func(ctx context.Context, stmt *littleorm.Statement) error {
+//			start := time.Now()
+//			err := next(ctx, stmt)
+//			metrics.Observe(stmt.Table, time.Since(start), err)
+//			return err
+//		}
+//	})
+type Middleware func(next Executor) Executor
+
+// 添加中间件，先添加的在外层，只在初始化的时候调用，不是线程安全的
+// 中间件包装的是`FindOne`、`FindMany`、`Select`、`Get`这些查询和`Insert`、`Update`、`Delete`、`Exec`这些更新，
+// `FindSeq`、`Queryx`、`QueryRowx`、`Preload`这些返回结果集的查询`stmt.Dest`是结果集的指针，由调用方扫描
+// 查询结果的脱敏、钩子这些处理在中间件外面，中间件直接返回的结果也会处理
+func (db *DB) Use(middlewares ...Middleware) *DB {
+	db.middlewares = append(db.middlewares, middlewares...)
+	return db
+}
+
+// 用中间件包装`exec`
+func (db *DB) chain(exec Executor) Executor {
+	// 提示在最里面，按中间件改写以后的语句计算指纹
+	exec = db.hinted(exec)
+	for i := len(db.middlewares) - 1; i >= 0; i-- {
+		exec = db.middlewares[i](exec)
+	}
+	// 生命周期钩子在最外面，看到的是中间件处理以后的结果
+	return db.lifecycled(exec)
+}
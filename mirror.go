@@ -0,0 +1,133 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 冗余字段的同步规则，`Target`表的`TargetColumn`冗余了`Source`表的`SourceColumn`，通过`TargetKey = SourceKey`关联
+// eg: 订单表冗余了用户名，MirrorRule{Source: "user", SourceColumn: "name", SourceKey: "id", Target: "orders", TargetColumn: "user_name", TargetKey: "user_id"}
+type MirrorRule struct {
+	Source       string
+	SourceColumn string
+	SourceKey    string
+	Target       string
+	TargetColumn string
+	TargetKey    string
+	// 异步同步，更新提交以后在后台同步，失败只记录日志；默认在同一个事务中同步
+	// 更新本身在外面的事务中时，异步同步可能在外面的事务提交前执行，读到旧的数据，这种情况请用同步
+	Async bool
+}
+
+// 注册冗余字段的同步规则，用`UpdateMap`更新源表的`SourceColumn`以后，自动更新目标表中冗余的字段
+// 同步时先查出要更新的记录的`SourceKey`，再按这些值更新目标表，没有事务的话会开一个事务把更新和同步放在一起
+// 只对`UpdateMap`有效，`Update`直接拼的`SQL`不知道改了哪些字段
+func (db *DB) Mirror(rule MirrorRule) *DB {
+	db.mirrorMu.Lock()
+	defer db.mirrorMu.Unlock()
+	if db.mirrors == nil {
+		db.mirrors = make(map[string][]MirrorRule)
+	}
+	db.mirrors[rule.Source] = append(db.mirrors[rule.Source], rule)
+	return db
+}
+
+// 这次更新涉及的同步规则
+func (db *DB) mirrorsOf(table string, data map[string]interface{}) []MirrorRule {
+	db.mirrorMu.RLock()
+	defer db.mirrorMu.RUnlock()
+	var rules []MirrorRule
+	for _, rule := range db.mirrors[table] {
+		if _, ok := data[rule.SourceColumn]; ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// 更新并同步冗余字段
+func (ctx *Context) updateMirrored(data map[string]interface{}, rules []MirrorRule) (rowsAffected int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	var (
+		db        = ctx.db
+		parent    = ctx.parent
+		where     = sqlwhere(ctx.wheres, Grouping)
+		whereArgs = append([]interface{}(nil), ctx.whereArgs...)
+		async     = make(map[int][]interface{}) //异步同步的规则 => 关联的值
+		pending   = true                        //`ctx`还没有执行，出错时需要放回池子
+	)
+	defer func() {
+		if pending {
+			ctx.release()
+		}
+	}()
+	if ctx.err != nil {
+		return 0, ctx.err
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	// 更新前查出关联的值，更新的字段可能就在条件中，更新以后就查不到了
+	keysOf := func(tx *sqlx.Tx, rule MirrorRule) (keys []interface{}, err error) {
+		query := fmt.Sprintf("select %s from %s %s", rule.SourceKey, rule.Source, where)
+		err = db.AcquireTx(tx).WithContext(parent).Select(&keys, query, whereArgs...)
+		return
+	}
+	run := func(tx *sqlx.Tx) (err error) {
+		keys := make([][]interface{}, len(rules))
+		for i, rule := range rules {
+			if keys[i], err = keysOf(tx, rule); err != nil {
+				return
+			}
+		}
+		ctx.tx, pending = tx, false
+		if rowsAffected, err = ctx.updateMap(data); err != nil {
+			return
+		}
+		for i, rule := range rules {
+			if rule.Async {
+				async[i] = keys[i]
+				continue
+			}
+			if err = db.syncMirror(db.AcquireTx(tx).WithContext(parent), rule, keys[i]); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	if ctx.tx != nil {
+		err = run(ctx.tx)
+	} else {
+		err = db.Transaction(parent, PropagationRequired, func(c context.Context) error {
+			return run(db.TxFromContext(c))
+		})
+	}
+	if err != nil {
+		return
+	}
+	for i, keys := range async {
+		go func(rule MirrorRule, keys []interface{}) {
+			if err := db.syncMirror(db.Acquire(), rule, keys); err != nil {
+				db.logError("littleorm mirror failed", LogField{"source", rule.Source + "." + rule.SourceColumn}, LogField{"target", rule.Target + "." + rule.TargetColumn}, LogField{"error", err})
+			}
+		}(rules[i], keys)
+	}
+	return
+}
+
+// 按关联的值更新目标表的冗余字段
+func (db *DB) syncMirror(ctx *Context, rule MirrorRule, keys []interface{}) error {
+	if len(keys) == 0 {
+		ctx.release()
+		return nil
+	}
+	set := fmt.Sprintf("%s = (select %s from %s where %s.%s = %s.%s)",
+		rule.TargetColumn, rule.SourceColumn, rule.Source, rule.Source, rule.SourceKey, rule.Target, rule.TargetKey)
+	_, err := ctx.Name(rule.Target).WhereIn(rule.TargetKey, keys).Update(set)
+	return err
+}
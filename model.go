@@ -0,0 +1,158 @@
+package littleorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 模型的元数据，解析一次以后缓存起来，避免每次查询都反射解析`db`标签
+type model struct {
+	columns   []string               //`db`标签指定的字段，按结构体中的顺序
+	selects   string                 //拼接好的查询字段
+	index     map[string]int         //字段 => 结构体中的下标
+	readonly  map[string]bool        //只读字段，写入结构体时跳过，eg: `db:"created_at,readonly"`
+	autoincr  string                 //自增字段，值为零时写入结构体跳过，eg: `db:"uid,autoincr"`，没有标记时默认是`id`
+	pk        string                 //主键，eg: `db:"uid,pk"`，没有标记时用自增字段
+	computed  map[string]string      //计算字段 => 表达式，查询时用表达式代替字段
+	relations map[string]*ManyToMany //关联名 => 多对多关联
+	hasMany   map[string]*HasMany    //关联名 => 一对多关联
+	lazies    map[int]string         //`Lazy`字段的下标 => 关联名
+	hooks     *modelHooks            //`AfterScan`之类的钩子
+	soft      bool                   //宽松的表结构检查，见`SoftSchema`
+	deletedAt string                 //软删除字段，eg: `db:"deleted_at,softdelete"`，写入结构体时跳过
+}
+
+// 模型缓存，reflect.Type => *model
+var models sync.Map
+
+// 修改已经缓存的模型时加锁，查询时直接读缓存，修改时复制一份再替换
+var modelMu sync.Mutex
+
+// 预先注册模型，参数是对象或者对象指针，eg: Little{}, &Little{}
+// 不注册也可以，第一次查询时会自动解析并缓存，注册只是把解析提前到启动阶段
+func Register(values ...interface{}) {
+	for _, value := range values {
+		if t := structType(value); t != nil {
+			lookupModel(t)
+		}
+	}
+}
+
+// 给模型注册计算字段，查询时用`expr as column`代替`column`，字段自动变成只读的，写入时跳过
+// 和其他注册一样在启动时调用，`column`必须是模型中`db`标签指定的字段
+// eg: littleorm.Computed(User{}, "full_name", "concat(first_name, ' ', last_name)")
+func Computed(value interface{}, column, expr string) {
+	t := structType(value)
+	if t == nil {
+		panic(fmt.Sprintf("littleorm: Computed must be registered on a struct, got %T", value))
+	}
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	m := *lookupModel(t)
+	if _, ok := m.index[column]; !ok {
+		panic(fmt.Sprintf("littleorm: Computed column %q is not a field of %s", column, t))
+	}
+	m.setComputed(column, expr)
+	models.Store(t, &m)
+}
+
+// 获取模型元数据，没有的话解析并缓存
+func lookupModel(t reflect.Type) *model {
+	if m, ok := models.Load(t); ok {
+		return m.(*model)
+	}
+	m := &model{index: make(map[string]int, t.NumField()), hooks: &modelHooks{}}
+	for i := 0; i < t.NumField(); i++ {
+		if relation := t.Field(i).Tag.Get("relation"); relation != "" && reflect.PointerTo(t.Field(i).Type).Implements(lazyBinderType) {
+			if m.lazies == nil {
+				m.lazies = make(map[int]string)
+			}
+			m.lazies[i] = relation
+			continue
+		}
+		column, opts := parseTag(t.Field(i).Tag.Get(DBTag))
+		if column == "" || column == "-" {
+			continue
+		}
+		m.columns = append(m.columns, column)
+		m.index[column] = i
+		if containsString(opts, "readonly") {
+			if m.readonly == nil {
+				m.readonly = make(map[string]bool)
+			}
+			m.readonly[column] = true
+		}
+		if containsString(opts, "autoincr") {
+			m.autoincr = column
+		}
+		if containsString(opts, "softdelete") {
+			m.deletedAt = column
+		}
+		if containsString(opts, "pk") {
+			m.pk = column
+		}
+	}
+	if _, ok := m.index["id"]; ok && m.autoincr == "" {
+		m.autoincr = "id"
+	}
+	if m.pk == "" {
+		m.pk = m.autoincr
+	}
+	m.selects = sqljoin(m.columns, SeqComma)
+	actual, _ := models.LoadOrStore(t, m)
+	return actual.(*model)
+}
+
+// 获取目标对象的模型元数据，不是结构体返回`nil`
+func modelOf(dest interface{}) *model {
+	t := structType(dest)
+	if t == nil {
+		return nil
+	}
+	return lookupModel(t)
+}
+
+// 查询的字段，计算字段替换成表达式
+func (m *model) selectColumns(columns []string) []string {
+	if len(m.computed) == 0 {
+		return columns
+	}
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		if expr, ok := m.computed[column]; ok {
+			selects[i] = fmt.Sprintf("%s as %s", expr, column)
+		} else {
+			selects[i] = column
+		}
+	}
+	return selects
+}
+
+// 设置计算字段，调用前需要先复制模型，不能修改缓存中的模型
+func (m *model) setComputed(column, expr string) {
+	m.computed = copyStrings(m.computed)
+	m.computed[column] = expr
+	readonly := make(map[string]bool, len(m.readonly)+1)
+	for k, v := range m.readonly {
+		readonly[k] = v
+	}
+	readonly[column] = true
+	m.readonly = readonly
+	m.selects = sqljoin(m.selectColumns(m.columns), SeqComma)
+}
+
+// 解析`db`标签，eg: `db:"created_at,readonly"` => created_at, [readonly]
+func parseTag(tag string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
+func copyStrings(m map[string]string) map[string]string {
+	copied := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,69 @@
+package littleorm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 空值的排序位置
+type Nulls int
+
+const (
+	NullsDefault Nulls = iota //数据库默认的位置，mysql和SQLite空值最小，postgres空值最大
+	NullsFirst                //空值排在最前面
+	NullsLast                 //空值排在最后面
+)
+
+// 按字段排序，多次调用会追加，字段名会自动加上引号，`direction`是`asc`或者`desc`，不区分大小写
+// 只能是字段名，表达式请用`OrderByExpr`，否则执行时返回`ErrInvalidIdentifier`
+// `postgres`和`SQLite`用`nulls first/last`，`mysql`不支持，用`isnull(字段)`模拟
+// `Order`和`OrderFragment`会覆盖之前的排序，需要组合的话都用`OrderBy`和`OrderByExpr`
+// eg: OrderBy("score", "desc", littleorm.NullsLast).OrderBy("id", "asc", littleorm.NullsDefault)
+func (ctx *Context) OrderBy(column, direction string, nulls Nulls) *Context {
+	column = strings.TrimSpace(column)
+	if !identPattern.MatchString(column) {
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("%w: order by %q, use OrderByExpr for expressions", ErrInvalidIdentifier, column)
+		}
+		return ctx
+	}
+	direction = strings.ToLower(strings.TrimSpace(direction))
+	if direction != "asc" && direction != "desc" {
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("littleorm: order by %q has invalid direction %q", column, direction)
+		}
+		return ctx
+	}
+	column = ctx.db.quoteIdent(column)
+	order := column + " " + direction
+	switch {
+	case nulls == NullsDefault:
+	case ctx.db.dialect.Name() == "mysql":
+		// `isnull`为真是1，空值排在前面就按它降序
+		if nulls == NullsFirst {
+			order = fmt.Sprintf("isnull(%s) desc, %s", column, order)
+		} else {
+			order = fmt.Sprintf("isnull(%s), %s", column, order)
+		}
+	case nulls == NullsFirst:
+		order += " nulls first"
+	default:
+		order += " nulls last"
+	}
+	return ctx.appendOrder(order, nil)
+}
+
+// 排序表达式，原样拼接，多次调用会追加，eg: OrderByExpr("field(status, ?, ?)", 2, 1)，OrderByExpr("length(name) desc")
+func (ctx *Context) OrderByExpr(expr string, args ...interface{}) *Context {
+	ctx.checkPlaceholders("order by", expr, args)
+	return ctx.appendOrder(expr, args)
+}
+
+func (ctx *Context) appendOrder(order string, args []interface{}) *Context {
+	if ctx.order != "" {
+		order = ctx.order + SeqComma + order
+	}
+	ctx.order = order
+	ctx.orderArgs = append(ctx.orderArgs, args...)
+	return ctx
+}
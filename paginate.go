@@ -0,0 +1,50 @@
+package littleorm
+
+import (
+	"errors"
+)
+
+var ErrInvalidPage = errors.New("littleorm: page and perPage must be positive")
+
+// 分页查询，返回总条数，第`page`页(从1开始)的数据放到`dest`中，`dest`必须是数组指针
+// 统计和查询用同样的条件、`Join`和`Group`，统计时忽略`Order`和锁，在同一个事务或者连接上执行
+// eg: total, err := db.Acquire().Name("user").Where("age>?", 18).Order("id desc").Paginate(2, 20, &users)
+func (ctx *Context) Paginate(page, perPage int64, dest interface{}) (total int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	if page < 1 || perPage < 1 {
+		ctx.release()
+		return 0, ErrInvalidPage
+	}
+	if ctx.err != nil {
+		err = ctx.err
+		ctx.release()
+		return
+	}
+
+	// 数据查询执行以后`ctx`就放回池子了，先拼好统计的语句
+	what, whatArgs, order, orderArgs, lock := ctx.what, ctx.whatArgs, ctx.order, ctx.orderArgs, ctx.lock
+	ctx.order, ctx.orderArgs, ctx.lock = "", nil, LockOptions{}
+	ctx.limit, ctx.offset = 0, 0
+	var query string
+	if len(ctx.groups) == 0 {
+		ctx.What([]string{"count(*)"})
+		query = ctx.sqlselect(dest)
+	} else {
+		ctx.What([]string{"1"})
+		query = "select count(*) from (" + ctx.sqlselect(dest) + ") t"
+	}
+	args := append([]interface{}(nil), ctx.selectArgs()...)
+	ctx.what, ctx.whatArgs, ctx.order, ctx.orderArgs, ctx.lock = what, whatArgs, order, orderArgs, lock
+
+	counter := ctx.db.Acquire()
+	counter.name, counter.tx, counter.conn, counter.parent = ctx.name, ctx.tx, ctx.conn, ctx.parent
+	counter.timeout, counter.dry = ctx.timeout, ctx.dry
+	if err = counter.Get(&total, query, args...); err != nil {
+		ctx.release()
+		return
+	}
+	err = ctx.Limit(perPage).Offset((page - 1) * perPage).FindMany(dest)
+	return
+}
@@ -0,0 +1,195 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// `Queryx`返回的结果集，关闭的时候同时释放超时的`context.Context`，扫描结构体时会执行`AfterScan`钩子
+type Rows struct {
+	*sqlx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *Rows) StructScan(dest interface{}) error {
+	if err := r.Rows.StructScan(dest); err != nil {
+		return err
+	}
+	return runAfterScan(dest)
+}
+
+// 关闭结果集，一定要调用，否则要等到超时才释放
+func (r *Rows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// `QueryRowx`返回的单行结果，扫描以后释放超时的`context.Context`，扫描结构体时会执行`AfterScan`钩子
+type Row struct {
+	*sqlx.Row
+	err    error //执行前的错误
+	cancel context.CancelFunc
+}
+
+func (r *Row) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Row.Err()
+}
+
+func (r *Row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+func (r *Row) StructScan(dest interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.cancel()
+	if err := r.Row.StructScan(dest); err != nil {
+		return err
+	}
+	return runAfterScan(dest)
+}
+
+func (r *Row) MapScan(dest map[string]interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.cancel()
+	return r.Row.MapScan(dest)
+}
+
+func (r *Row) SliceScan() ([]interface{}, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	defer r.cancel()
+	return r.Row.SliceScan()
+}
+
+// 直接调用`sqlx`的`QueryxContext`，需要自己处理结果集的时候用，有事务用事务，同样有超时、日志和兼容性检查
+// 和其他查询一样经过中间件、生命周期钩子和故障注入
+// 结果集不会按`DB.Mask`脱敏，敏感字段需要自己处理
+// eg:
+//
+//	rows, err := db.Acquire().Queryx("select * from user where age>?", 18)
+//	defer rows.Close()
+//	for rows.Next() { rows.StructScan(&user) }
+func (ctx *Context) Queryx(query string, args ...interface{}) (*Rows, error) {
+	ttx, cancel, err := ctx.passthrough(query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer ctx.release()
+	rows, done, err := ctx.queryRows(ttx, query, args)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Rows{Rows: rows, cancel: func() {
+		done()
+		cancel()
+	}}, nil
+}
+
+// 直接调用`sqlx`的`QueryRowxContext`，错误在扫描的时候返回，和`Queryx`一样不会脱敏
+func (ctx *Context) QueryRowx(query string, args ...interface{}) *Row {
+	ttx, cancel, err := ctx.passthrough(query, args)
+	if err != nil {
+		return &Row{err: err}
+	}
+	defer ctx.release()
+	var (
+		row     *sqlx.Row
+		release = func() {}
+	)
+	stmt := &Statement{Query: query, Args: args, Table: ctx.name, Dest: &row}
+	err = ctx.db.chain(func(ttx context.Context, stmt *Statement) error {
+		if handled, err := ctx.db.injectFault(ttx, stmt.Query, stmt.Args, nil); handled {
+			return err
+		}
+		conn, err := ctx.waitConn(ttx, stmt)
+		if err != nil {
+			return err
+		}
+		q := ctx.queryer()
+		if conn != nil {
+			q = ctx.db.bound(conn)
+		}
+		release = releaseOnDone(ttx, conn)
+		row = q.QueryRowxContext(ttx, stmt.Query, stmt.Args...)
+		return row.Err()
+	})(ttx, stmt)
+	done := func() {
+		release()
+		cancel()
+	}
+	if err == nil && row == nil {
+		err = fmt.Errorf("littleorm: no row returned by middlewares for %s", query)
+	}
+	if err != nil {
+		done()
+		return &Row{err: err}
+	}
+	return &Row{Row: row, cancel: done}
+}
+
+// 预编译带命名参数的语句，有事务的话在事务中预编译，超时只对预编译本身有效
+// 预编译会经过中间件，`Statement`中没有结果，之后用返回的语句执行不会再经过中间件
+// eg: stmt, err := db.Acquire().PrepareNamed("select * from user where name=:name")
+func (ctx *Context) PrepareNamed(query string) (*sqlx.NamedStmt, error) {
+	ttx, cancel, err := ctx.passthrough(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ctx.release()
+	defer cancel()
+	var named *sqlx.NamedStmt
+	stmt := &Statement{Query: query, Table: ctx.name, prepare: true}
+	err = ctx.db.chain(func(ttx context.Context, stmt *Statement) (err error) {
+		if handled, err := ctx.db.injectFault(ttx, stmt.Query, nil, nil); handled {
+			return err
+		}
+		if ctx.tx != nil {
+			named, err = ctx.tx.PrepareNamedContext(ttx, stmt.Query)
+		} else {
+			named, err = ctx.db.Pool().PrepareNamedContext(ttx, stmt.Query)
+		}
+		return
+	})(ttx, stmt)
+	if err == nil && named == nil {
+		err = fmt.Errorf("littleorm: no statement returned by middlewares for %s", query)
+	}
+	return named, err
+}
+
+// 直接执行`sqlx`方法前的公共处理，返回超时的`context.Context`，没有出错的话`Context`由调用方放回池子
+// 只读模式下只能执行`select`这些查询，其他语句返回`ErrReadOnly`
+func (ctx *Context) passthrough(query string, args []interface{}) (ttx context.Context, cancel context.CancelFunc, err error) {
+	ctx.db.logDebug("littleorm sql", sqlFields(query, args)...)
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	switch {
+	case ctx.err != nil:
+		err = ctx.err
+	case ctx.db.IsReadOnly() && !readOnlyQuery(query):
+		err = ErrReadOnly
+	default:
+		err = ctx.db.lint(query)
+	}
+	if err != nil {
+		ctx.release()
+		return
+	}
+	ttx, cancel = ctx.context()
+	return
+}
@@ -0,0 +1,42 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrPlaceholderMismatch = errors.New("littleorm: placeholder count mismatch")
+
+// 检查占位符的个数，只记录第一个错误
+func (ctx *Context) checkPlaceholders(clause, fragment string, args []interface{}) {
+	if ctx.err != nil {
+		return
+	}
+	if n := countPlaceholders(fragment); n != len(args) {
+		ctx.err = fmt.Errorf("%w: %s %q has %d placeholders but %d args", ErrPlaceholderMismatch, clause, fragment, n, len(args))
+	}
+}
+
+// 统计`SQL`片段中占位符的个数，忽略字符串和引号中的`?`和转义的`??`
+func countPlaceholders(fragment string) (n int) {
+	var quote byte
+	for i := 0; i < len(fragment); i++ {
+		c := fragment[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case strings.HasPrefix(fragment[i:], escapedMarker):
+			i++
+		case c == '?':
+			n++
+		}
+	}
+	return
+}
@@ -0,0 +1,247 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 单表继承的类型映射，同一张表中的数据根据类型字段的值扫描到不同的结构体中
+type polymorphic struct {
+	column string                  //类型字段
+	types  map[string]reflect.Type //类型字段的值 => 注册的类型，结构体或者结构体指针
+}
+
+// 接口类型 => *polymorphic
+var polymorphics sync.Map
+
+// 注册的结构体类型 => discriminator，插入时用来写类型字段
+var discriminators sync.Map
+
+// 结构体对应的类型字段和值
+type discriminator struct {
+	column string
+	value  string
+}
+
+// 注册接口`I`的单表继承映射，`column`是区分类型的字段，`types`是字段值和对应的实现
+// 之后`FindMany(&[]I)`和`FindOne(&i)`会根据每一行`column`的值创建对应的结构体，结构体中没有的字段会忽略
+// eg: littleorm.Polymorphic[Animal]("kind", map[string]Animal{"dog": &Dog{}, "cat": &Cat{}})
+func Polymorphic[I any](column string, types map[string]I) {
+	iface := reflect.TypeOf((*I)(nil)).Elem()
+	if iface.Kind() != reflect.Interface {
+		panic("littleorm: Polymorphic must be registered on an interface type, got " + iface.String())
+	}
+	p := &polymorphic{column: column, types: make(map[string]reflect.Type, len(types))}
+	for value, impl := range types {
+		t := reflect.TypeOf(impl)
+		if t == nil || structType(impl) == nil || (t.Kind() == reflect.Ptr && t.Elem().Kind() != reflect.Struct) {
+			panic(fmt.Sprintf("littleorm: Polymorphic type for %q must be a struct or struct pointer, got %T", value, impl))
+		}
+		p.types[value] = t
+		discriminators.Store(structType(impl), discriminator{column: column, value: value})
+	}
+	polymorphics.Store(iface, p)
+}
+
+// 结构体注册过单表继承的话返回类型字段和值
+func discriminatorOf(t reflect.Type) (discriminator, bool) {
+	d, ok := discriminators.Load(t)
+	if !ok {
+		return discriminator{}, false
+	}
+	return d.(discriminator), true
+}
+
+// 目标对象是注册过的接口或者接口数组的话返回类型映射
+func polymorphicOf(dest interface{}) *polymorphic {
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil
+	}
+	t = t.Elem()
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Interface {
+		return nil
+	}
+	if p, ok := polymorphics.Load(t); ok {
+		return p.(*polymorphic)
+	}
+	return nil
+}
+
+// 查询多条，签名和`sqlx.SelectContext`一样
+func (p *polymorphic) selectContext(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	return p.scan(ctx, q, dest, query, args, false)
+}
+
+// 查询单条，签名和`sqlx.GetContext`一样
+func (p *polymorphic) getContext(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	return p.scan(ctx, q, dest, query, args, true)
+}
+
+func (p *polymorphic) scan(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args []interface{}, one bool) error {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	discriminator := -1
+	for i, column := range columns {
+		if column == p.column {
+			discriminator = i
+		}
+	}
+	if discriminator < 0 {
+		return fmt.Errorf("littleorm: polymorphic column %q is not selected", p.column)
+	}
+
+	var (
+		target    = reflect.ValueOf(dest).Elem()
+		values    = make([]interface{}, len(columns))
+		scanners  = make([]interface{}, len(columns))
+		traversal = make(map[reflect.Type][][]int)
+	)
+	for i := range values {
+		scanners[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(scanners...); err != nil {
+			return err
+		}
+		kind := fmt.Sprint(asString(values[discriminator]))
+		t, ok := p.types[kind]
+		if !ok {
+			return fmt.Errorf("littleorm: unknown polymorphic %s %q", p.column, kind)
+		}
+		base := t
+		if base.Kind() == reflect.Ptr {
+			base = base.Elem()
+		}
+		if _, ok = traversal[base]; !ok {
+			traversal[base] = rows.Mapper.TraversalsByName(base, columns)
+		}
+		item := reflect.New(base)
+		if err = assignColumns(item.Elem(), traversal[base], columns, values); err != nil {
+			return err
+		}
+		if err = runAfterScan(item.Interface()); err != nil {
+			return err
+		}
+		if t.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		if one {
+			target.Set(item)
+			return rows.Close()
+		}
+		target.Set(reflect.Append(target, item))
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if one {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// 把一行数据赋给结构体，`traversals`是每一列对应的字段下标，结构体中没有的列会忽略
+func assignColumns(item reflect.Value, traversals [][]int, columns []string, values []interface{}) error {
+	for i, fields := range traversals {
+		if len(fields) == 0 {
+			continue
+		}
+		if err := assignValue(item.FieldByIndex(fields), values[i]); err != nil {
+			return fmt.Errorf("littleorm: scan column %q: %w", columns[i], err)
+		}
+	}
+	return nil
+}
+
+// 驱动返回的值转换成字符串
+func asString(src interface{}) interface{} {
+	if b, ok := src.([]byte); ok {
+		return string(b)
+	}
+	return src
+}
+
+// 把驱动返回的值赋给字段，支持`sql.Scanner`和基本类型
+func assignValue(dst reflect.Value, src interface{}) error {
+	if scanner, ok := dst.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		v := reflect.New(dst.Type().Elem())
+		if err := assignValue(v.Elem(), src); err != nil {
+			return err
+		}
+		dst.Set(v)
+		return nil
+	}
+	if b, ok := src.([]byte); ok && dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+		dst.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+	if t, ok := src.(time.Time); ok && dst.Type() == timeType {
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+	s := fmt.Sprint(asString(src))
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(s)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(v)
+	default:
+		if dst.Type() == timeType {
+			v, err := time.Parse("2006-01-02 15:04:05", s)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(v))
+			return nil
+		}
+		return fmt.Errorf("unsupported type %s for %T", dst.Type(), src)
+	}
+	return nil
+}
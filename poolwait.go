@@ -0,0 +1,110 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 统计等待连接时间时保留最近多少条语句
+const poolWaitSamples = 1024
+
+// 语句执行的统计
+type QueryStats struct {
+	sql.DBStats // 连接池的统计
+
+	Statements  int64         //统计了等待时间的语句数
+	PoolWaitP50 time.Duration //最近的语句等待连接时间的中位数
+	PoolWaitP95 time.Duration //最近的语句等待连接时间的`p95`
+	PoolWaitMax time.Duration //最近的语句等待连接的最长时间
+}
+
+// 最近的语句等待连接的时间
+type poolWaits struct {
+	mu      sync.Mutex
+	total   int64
+	samples []time.Duration
+	next    int
+}
+
+// 是否统计语句等待连接的时间，默认关闭
+// 开启以后不在事务中的语句执行前先从连接池取出连接，记录取连接花的时间，用`QueryStats`查看，
+// 中间件中也可以在执行以后从`Statement.PoolWait`拿到，用来区分是连接池不够用还是查询本身慢
+func (db *DB) TrackPoolWait(enabled bool) *DB {
+	db.trackPoolWait = enabled
+	return db
+}
+
+// 执行统计，等待连接的时间需要开启`TrackPoolWait`
+func (db *DB) QueryStats() QueryStats {
+	stats := QueryStats{DBStats: db.Pool().Stats()}
+	db.poolWaits.mu.Lock()
+	stats.Statements = db.poolWaits.total
+	samples := append([]time.Duration(nil), db.poolWaits.samples...)
+	db.poolWaits.mu.Unlock()
+	if len(samples) == 0 {
+		return stats
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	stats.PoolWaitP50 = samples[(len(samples)-1)*50/100]
+	stats.PoolWaitP95 = samples[(len(samples)-1)*95/100]
+	stats.PoolWaitMax = samples[len(samples)-1]
+	return stats
+}
+
+func (w *poolWaits) record(wait time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.total++
+	if len(w.samples) < poolWaitSamples {
+		w.samples = append(w.samples, wait)
+		return
+	}
+	w.samples[w.next] = wait
+	w.next = (w.next + 1) % poolWaitSamples
+}
+
+// 先从连接池取出连接，记录等待的时间，返回的`release`把连接放回连接池
+func (ctx *Context) pinConn(ttx context.Context, stmt *Statement) (release func(), err error) {
+	release = func() {}
+	conn, err := ctx.waitConn(ttx, stmt)
+	if err != nil || conn == nil {
+		return
+	}
+	ctx.conn = conn
+	return func() {
+		ctx.conn = nil
+		conn.Close()
+	}, nil
+}
+
+// 从连接池取出连接并记录等待的时间，没有开启`TrackPoolWait`或者已经有事务、连接的话返回`nil`
+func (ctx *Context) waitConn(ttx context.Context, stmt *Statement) (*sqlx.Conn, error) {
+	if !ctx.db.trackPoolWait || ctx.tx != nil || ctx.conn != nil {
+		return nil, nil
+	}
+	start := time.Now()
+	conn, err := ctx.db.Pool().Connx(ttx)
+	if err != nil {
+		return nil, err
+	}
+	stmt.PoolWait = time.Since(start)
+	ctx.db.poolWaits.record(stmt.PoolWait)
+	return conn, nil
+}
+
+// 结果集关闭以后才能把连接放回连接池，调用方忘了关闭的话超时以后也会放回
+func releaseOnDone(ttx context.Context, conn *sqlx.Conn) (release func()) {
+	if conn == nil {
+		return func() {}
+	}
+	stop := context.AfterFunc(ttx, func() { conn.Close() })
+	return func() {
+		stop()
+		conn.Close()
+	}
+}
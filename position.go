@@ -0,0 +1,105 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnsupportedPosition = errors.New("littleorm: replication position not supported")
+	ErrPositionTimeout     = errors.New("littleorm: timeout waiting for replication position")
+)
+
+// `postgres`从库没有`WAIT_FOR_EXECUTED_GTID_SET`，轮询检查的间隔
+const positionPoll = 50 * time.Millisecond
+
+// 复制的位置，mysql是`GTID`集合，postgres是`WAL`的`LSN`
+type Position struct {
+	Dialect string
+	Value   string
+}
+
+func (p Position) String() string {
+	return p.Dialect + ":" + p.Value
+}
+
+// 当前已经执行的位置，在主库写入以后调用，把位置传给`WaitForPosition`等从库追上，实现读自己写入的数据
+// mysql需要开启`GTID`，读取`@@global.gtid_executed`；postgres读取`pg_current_wal_lsn()`，sqlite3不支持
+func (db *DB) CurrentPosition(ctx context.Context) (pos Position, err error) {
+	pos.Dialect = db.dialect.Name()
+	var query string
+	switch pos.Dialect {
+	case "mysql":
+		query = "select @@global.gtid_executed"
+	case "postgres":
+		query = "select pg_current_wal_lsn()::text"
+	default:
+		return pos, fmt.Errorf("%w: dialect %s", ErrUnsupportedPosition, db.dialect.Name())
+	}
+	ttx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+	err = db.Pool().GetContext(ttx, &pos.Value, query)
+	return
+}
+
+// 等待从库`replica`执行到位置`pos`，超过`timeout`还没有追上返回`ErrPositionTimeout`，可以改为读主库
+// mysql用`WAIT_FOR_EXECUTED_GTID_SET`，postgres轮询`pg_last_wal_replay_lsn()`
+// eg:
+//
+//	pos, err := master.CurrentPosition(ctx)
+//	if err = littleorm.WaitForPosition(ctx, replica, pos, time.Second); err == nil {
+//		err = replica.Acquire().Name("user").Where("id=?", id).FindOne(&user)
+//	}
+func WaitForPosition(ctx context.Context, replica *DB, pos Position, timeout time.Duration) error {
+	dialect := replica.dialect.Name()
+	if dialect != pos.Dialect {
+		return fmt.Errorf("%w: position from %s, replica is %s", ErrUnsupportedPosition, pos.Dialect, dialect)
+	}
+	switch dialect {
+	case "mysql":
+		return waitGTID(ctx, replica, pos.Value, timeout)
+	case "postgres":
+		return waitLSN(ctx, replica, pos.Value, timeout)
+	}
+	return fmt.Errorf("%w: dialect %s", ErrUnsupportedPosition, replica.dialect.Name())
+}
+
+// `WAIT_FOR_EXECUTED_GTID_SET`超时返回1，超时时间是秒，可以是小数
+func waitGTID(ctx context.Context, replica *DB, gtid string, timeout time.Duration) error {
+	ttx, cancel := context.WithTimeout(ctx, timeout+replica.timeout)
+	defer cancel()
+	var timedOut int
+	if err := replica.Pool().GetContext(ttx, &timedOut, "select wait_for_executed_gtid_set(?, ?)", gtid, timeout.Seconds()); err != nil {
+		return err
+	}
+	if timedOut != 0 {
+		return fmt.Errorf("%w: gtid %s", ErrPositionTimeout, gtid)
+	}
+	return nil
+}
+
+func waitLSN(ctx context.Context, replica *DB, lsn string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ttx, cancel := context.WithTimeout(ctx, replica.timeout)
+		var caught bool
+		err := replica.Pool().GetContext(ttx, &caught, "select coalesce(pg_last_wal_replay_lsn() >= $1::pg_lsn, true)", lsn)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if caught {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: lsn %s", ErrPositionTimeout, lsn)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(positionPoll):
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package littleorm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// 注册投影结构体，投影是模型的一部分字段，列表接口只查需要的字段时用
+// 注册时检查投影的每个字段都在模型中并且类型一样，不一样直接`panic`，字段改名以后启动时就能发现
+// 投影会继承模型的计算字段和`SoftSchema`设置，需要在模型的`Computed`、`SoftSchema`之后注册
+// eg:
+//
+//	type UserItem struct {
+//		Id   int64  `db:"id"`
+//		Name string `db:"name"`
+//	}
+//	littleorm.Projection(UserItem{}, User{})
+//	err := db.Acquire().Name("user").FindMany(&items) // select id, name from user
+func Projection(projection, model interface{}) {
+	pt, mt := structType(projection), structType(model)
+	if pt == nil || mt == nil {
+		panic(fmt.Sprintf("littleorm: Projection must be registered on structs, got %T and %T", projection, model))
+	}
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	base := lookupModel(mt)
+	p := *lookupModel(pt)
+	for _, column := range p.columns {
+		i, ok := base.index[column]
+		if !ok {
+			panic(fmt.Sprintf("littleorm: projection %s column %q is not a field of %s", pt, column, mt))
+		}
+		if want, got := mt.Field(i).Type, pt.Field(p.index[column]).Type; indirectType(want) != indirectType(got) {
+			panic(fmt.Sprintf("littleorm: projection %s column %q is %s, but %s in %s", pt, column, got, want, mt))
+		}
+		if expr, ok := base.computed[column]; ok {
+			p.setComputed(column, expr)
+		}
+	}
+	p.soft = p.soft || base.soft
+	models.Store(pt, &p)
+}
+
+// 去掉指针，`*string`和`string`当作一样的类型
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,85 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 分批删除过期数据，用`DB.PurgeOlderThan`创建，`Run`执行
+type Purger struct {
+	db       *DB
+	table    string
+	column   string
+	age      time.Duration
+	batch    int
+	interval time.Duration
+	progress func(deleted, total int64)
+}
+
+// 删除表`table`中`timeColumn`早于`age`之前的数据，每次最多删除`batchSize`条，避免一个大事务长时间锁表
+// eg: db.PurgeOlderThan("log", "created_at", 30*24*time.Hour, 1000).Interval(time.Second).Run(ctx)
+func (db *DB) PurgeOlderThan(table, timeColumn string, age time.Duration, batchSize int) *Purger {
+	return &Purger{db: db, table: table, column: timeColumn, age: age, batch: batchSize}
+}
+
+// 每批之间的休眠时间，给其他事务让出锁和复制延迟的时间
+func (p *Purger) Interval(interval time.Duration) *Purger {
+	p.interval = interval
+	return p
+}
+
+// 每删除一批调用一次，`deleted`是这一批删除的行数，`total`是累计删除的行数
+func (p *Purger) Progress(fn func(deleted, total int64)) *Purger {
+	p.progress = fn
+	return p
+}
+
+// 开始删除，直到没有过期数据或者`ctx`被取消，返回累计删除的行数
+// 截止时间在开始时计算，删除过程中新过期的数据不会处理
+func (p *Purger) Run(ctx context.Context) (total int64, err error) {
+	if p.batch <= 0 {
+		return 0, fmt.Errorf("littleorm: invalid purge batch size %d", p.batch)
+	}
+	cutoff := time.Now().Add(-p.age)
+	query := p.query()
+	for {
+		var deleted int64
+		if deleted, err = p.purgeBatch(ctx, query, cutoff); err != nil {
+			return
+		}
+		total += deleted
+		if p.progress != nil {
+			p.progress(deleted, total)
+		}
+		if deleted < int64(p.batch) {
+			return
+		}
+		if p.interval > 0 {
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(p.interval):
+			}
+		} else if err = ctx.Err(); err != nil {
+			return
+		}
+	}
+}
+
+func (p *Purger) purgeBatch(ctx context.Context, query string, cutoff time.Time) (int64, error) {
+	result, err := p.db.AcquireContext(ctx).Exec(query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// mysql支持`delete ... limit`，其他数据库通过主键子查询限制行数
+func (p *Purger) query() string {
+	if p.db.dialect.Name() == "mysql" {
+		return fmt.Sprintf("delete from %s where %s < %s limit %d", p.table, p.column, ParamMarker, p.batch)
+	}
+	return fmt.Sprintf("delete from %s where id in (select id from %s where %s < %s limit %d)",
+		p.table, p.table, p.column, ParamMarker, p.batch)
+}
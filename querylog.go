@@ -0,0 +1,233 @@
+package littleorm
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 语句日志的格式
+type QueryLogFormat int
+
+const (
+	// 和`MySQL`的`general log`一样的格式，参数直接替换到语句中，耗时放在语句后面的注释里
+	QueryLogGeneral QueryLogFormat = iota
+	// 每行一个`JSON`对象
+	QueryLogJSON
+)
+
+// 把执行的语句和耗时写到文件中，服务端不能开`general log`的时候用，文件超过大小以后自动滚动
+// eg:
+//
+//	qlog, err := littleorm.OpenQueryLog("/var/log/app/sql.log", littleorm.QueryLogJSON, 100<<20, 5)
+//	db.Use(qlog.Middleware())
+//	defer qlog.Close()
+type QueryLog struct {
+	mu         sync.Mutex
+	path       string
+	format     QueryLogFormat
+	maxSize    int64 //文件大小上限，`0`表示不滚动
+	maxBackups int   //保留的旧文件个数，旧文件是`path.1`、`path.2`...，数字越大越旧
+	file       *os.File
+	size       int64
+	logger     Logger //写日志失败时记录错误，见`SetLogger`
+}
+
+// 打开日志文件，已经存在的话追加
+func OpenQueryLog(path string, format QueryLogFormat, maxSize int64, maxBackups int) (*QueryLog, error) {
+	l := &QueryLog{path: path, format: format, maxSize: maxSize, maxBackups: maxBackups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// 记录语句的中间件，写日志失败不影响语句的执行
+func (l *QueryLog) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return func(ctx context.Context, stmt *Statement) error {
+			start := time.Now()
+			err := next(ctx, stmt)
+			if werr := l.write(l.format.line(start, time.Since(start), stmt, err)); werr != nil && l.logger != nil {
+				l.logger.Error("littleorm write query log failed", LogField{"error", werr})
+			}
+			return err
+		}
+	}
+}
+
+// 设置写日志失败时记录错误的日志，默认不记录
+func (l *QueryLog) SetLogger(logger Logger) *QueryLog {
+	l.logger = logger
+	return l
+}
+
+func (l *QueryLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+func (l *QueryLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *QueryLog) write(line []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// 滚动文件，`path.n`依次改名成`path.n+1`，超过保留个数的删掉
+func (l *QueryLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	backup := func(i int) string {
+		return l.path + "." + strconv.Itoa(i)
+	}
+	if l.maxBackups <= 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return l.open()
+	}
+	_ = os.Remove(backup(l.maxBackups))
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(l.path, backup(1)); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// `JSON`格式的一行
+type queryLogEntry struct {
+	Time     string        `json:"time"`
+	Duration float64       `json:"duration_ms"`
+	Table    string        `json:"table,omitempty"`
+	Query    string        `json:"query"`
+	Args     []interface{} `json:"args,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// 生成一行日志
+func (f QueryLogFormat) line(start time.Time, took time.Duration, stmt *Statement, err error) []byte {
+	if f == QueryLogJSON {
+		entry := queryLogEntry{
+			Time:     start.UTC().Format(time.RFC3339Nano),
+			Duration: float64(took.Microseconds()) / 1000,
+			Table:    stmt.Table,
+			Query:    stmt.Query,
+			Args:     stmt.Args,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		data, merr := json.Marshal(entry)
+		if merr != nil {
+			// 参数不能序列化的时候转成字符串
+			entry.Args = []interface{}{fmt.Sprintf("%#v", stmt.Args)}
+			data, _ = json.Marshal(entry)
+		}
+		return append(data, '\n')
+	}
+	// Time Id Command Argument，客户端没有连接`id`，写`0`
+	comment := fmt.Sprintf("/* %.3fms */", float64(took.Microseconds())/1000)
+	if err != nil {
+		comment = fmt.Sprintf("/* %.3fms, error: %s */", float64(took.Microseconds())/1000, strings.ReplaceAll(err.Error(), "*/", "* /"))
+	}
+	query := strings.ReplaceAll(interpolate(stmt.Query, stmt.Args), "\n", " ")
+	return []byte(fmt.Sprintf("%s\t%7d Query\t%s %s\n", start.UTC().Format("2006-01-02T15:04:05.000000Z"), 0, query, comment))
+}
+
+// 把参数替换到语句中，只用来记录日志，不能用来执行
+func interpolate(query string, args []interface{}) string {
+	if len(args) == 0 {
+		return query
+	}
+	var (
+		buf   strings.Builder
+		quote byte
+		n     int
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(query) {
+				buf.WriteByte(c)
+				i++
+				c = query[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && n < len(args):
+			buf.WriteString(literal(args[n]))
+			n++
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// 参数转成`SQL`字面量
+func literal(arg interface{}) string {
+	if valuer, ok := arg.(sqldriver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return "?"
+		}
+		arg = value
+	}
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quoteLiteral(v)
+	case []byte:
+		return quoteLiteral(string(v))
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999") + "'"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`).Replace(s) + "'"
+}
@@ -0,0 +1,55 @@
+package littleorm
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+var ErrReadOnly = errors.New("littleorm: db is read only")
+
+// 以只读模式打开数据库，适合连接从库使用
+func OpenReadOnly(driverName, dataSourceName string, timeout time.Duration) (*DB, error) {
+	db, err := Open(driverName, dataSourceName, timeout)
+	if err != nil {
+		return nil, err
+	}
+	db.SetReadOnly(true)
+	return db, nil
+}
+
+// 设置只读模式，只读模式下`Insert`、`Update`、`Delete`、`Exec`等写操作直接返回`ErrReadOnly`，不会发送到数据库
+// 可以在运行时切换，比如维护期间临时冻结写入，不依赖数据库的权限配置
+func (db *DB) SetReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+	atomic.StoreInt32(&db.readOnly, v)
+}
+
+// 是否只读模式
+func (db *DB) IsReadOnly() bool {
+	return atomic.LoadInt32(&db.readOnly) == 1
+}
+
+// `with`语句中的写入，postgres可以在`CTE`中修改数据
+var writeKeyword = regexp.MustCompile(`(?i)\b(insert|update|delete|replace|merge)\b`)
+
+// 直接执行的`SQL`是不是只读的，不认识的语句都当成写入
+func readOnlyQuery(query string) bool {
+	fields := strings.FieldsFunc(query, func(r rune) bool { return unicode.IsSpace(r) || r == '(' })
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "select", "show", "explain", "describe", "desc":
+		return true
+	case "with":
+		return !writeKeyword.MatchString(query)
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package littleorm
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// 保存请求`trace id`的标签，`Recorder`按这个标签区分请求
+const TagTraceID = "trace_id"
+
+// 记录的语句
+type RecordedStatement struct {
+	Query    string
+	Args     []interface{}
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// 按请求记录执行过的语句，出错的时候可以把请求执行过的`SQL`一起上报，排查线上问题用
+// 每个请求只保留最近的`perTrace`条语句，最多保留`maxTraces`个请求，超过的丢掉最早的请求
+// eg:
+//
+//	recorder := littleorm.NewRecorder(50, 1000)
+//	db.Use(recorder.Middleware())
+//	ctx = littleorm.WithTags(ctx, map[string]string{littleorm.TagTraceID: traceID})
+//	...
+//	report.SQL = recorder.Statements(traceID)
+type Recorder struct {
+	mu        sync.Mutex
+	perTrace  int
+	maxTraces int
+	traces    map[string]*statementRing
+	order     *list.List //请求的先后顺序，用来淘汰最早的请求，元素是`trace id`
+}
+
+// 环形缓冲区，满了以后覆盖最早的语句
+type statementRing struct {
+	items []RecordedStatement
+	next  int
+	full  bool
+	elem  *list.Element //在`order`中的位置
+}
+
+func NewRecorder(perTrace, maxTraces int) *Recorder {
+	return &Recorder{
+		perTrace:  perTrace,
+		maxTraces: maxTraces,
+		traces:    make(map[string]*statementRing),
+		order:     list.New(),
+	}
+}
+
+// 记录语句的中间件，没有`trace id`的语句不记录
+func (r *Recorder) Middleware() Middleware {
+	return func(next Executor) Executor {
+		return func(ctx context.Context, stmt *Statement) error {
+			traceID := TagsFromContext(ctx)[TagTraceID]
+			if traceID == "" {
+				return next(ctx, stmt)
+			}
+			start := time.Now()
+			err := next(ctx, stmt)
+			r.record(traceID, RecordedStatement{
+				Query:    stmt.Query,
+				Args:     append([]interface{}(nil), stmt.Args...),
+				Start:    start,
+				Duration: time.Since(start),
+				Err:      err,
+			})
+			return err
+		}
+	}
+}
+
+// 请求执行过的语句，按执行顺序返回
+func (r *Recorder) Statements(traceID string) []RecordedStatement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ring, ok := r.traces[traceID]
+	if !ok {
+		return nil
+	}
+	if !ring.full {
+		return append([]RecordedStatement(nil), ring.items[:ring.next]...)
+	}
+	return append(append([]RecordedStatement(nil), ring.items[ring.next:]...), ring.items[:ring.next]...)
+}
+
+// 删除请求的记录，请求正常结束不需要上报的时候可以提前释放
+func (r *Recorder) Forget(traceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ring, ok := r.traces[traceID]; ok {
+		r.order.Remove(ring.elem)
+		delete(r.traces, traceID)
+	}
+}
+
+func (r *Recorder) record(traceID string, statement RecordedStatement) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ring, ok := r.traces[traceID]
+	if !ok {
+		r.evict()
+		ring = &statementRing{items: make([]RecordedStatement, r.perTrace)}
+		ring.elem = r.order.PushBack(traceID)
+		r.traces[traceID] = ring
+	}
+	if len(ring.items) == 0 {
+		return
+	}
+	ring.items[ring.next] = statement
+	ring.next++
+	if ring.next == len(ring.items) {
+		ring.next, ring.full = 0, true
+	}
+}
+
+// 请求太多的时候丢掉最早的请求
+func (r *Recorder) evict() {
+	for len(r.traces) >= r.maxTraces && r.order.Len() > 0 {
+		delete(r.traces, r.order.Remove(r.order.Front()).(string))
+	}
+}
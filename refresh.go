@@ -0,0 +1,40 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrNoPrimaryKey = errors.New("littleorm: no primary key")
+
+// 按结构体中的主键`id`重新查询一次，把数据库生成的字段(默认值、触发器、`ON UPDATE`的时间)填充回`dest`
+// 在`Insert`、`Update`以后调用，在事务中要用同一个事务的`Context`才能读到还没提交的数据
+// 不走事务内的缓存和实体缓存
+// eg:
+//
+//	result, err := db.AcquireTx(tx).Name("user").Insert(data)
+//	id, _ := result.LastInsertId()
+//	user.Id = uint64(id)
+//	err = db.AcquireTx(tx).Name("user").Refresh(&user)
+func (ctx *Context) Refresh(dest interface{}) error {
+	if err := ctx.inUse(); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		ctx.release()
+		return fmt.Errorf("littleorm: Refresh needs a pointer to struct, got %T", dest)
+	}
+	pk, ok := fieldByTag(v.Elem(), "id")
+	if !ok {
+		ctx.release()
+		return fmt.Errorf("%w: field id not found in %s", ErrNoPrimaryKey, v.Elem().Type())
+	}
+	if pk.IsZero() {
+		ctx.release()
+		return fmt.Errorf("%w: id of %s is zero", ErrNoPrimaryKey, v.Elem().Type())
+	}
+	ctx.identity = nil
+	return ctx.Where("id=?", pk.Interface()).FindOne(dest)
+}
@@ -0,0 +1,237 @@
+package littleorm
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 查询结果缓存，结果用`JSON`编码保存，可以自己用`Redis`之类实现
+// 每个缓存项带有标签，写入的时候按标签清掉相关的缓存
+type ResultCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string)
+	// 清掉带有任意一个标签的缓存
+	Invalidate(ctx context.Context, tags ...string)
+}
+
+// 设置查询结果缓存，`nil`表示关闭，默认关闭，只有用`Context.Cache`指定了的查询才会缓存
+// 通过`Name`指定表名的写入会自动清掉这张表相关的缓存：插入只清掉列表查询，按主键`id=?`更新和删除只清掉这个主键的缓存和列表查询，其他写入清掉整张表
+// 直接`Exec`的`SQL`不知道改了哪张表，需要自己调用`InvalidateTable`或者`InvalidateKey`
+// 事务中的写入会马上清掉缓存，事务提交前其他请求可能又把旧数据缓存了，对一致性要求高的数据不要缓存
+func (db *DB) ResultCache(cache ResultCache) *DB {
+	db.resultCache = cache
+	return db
+}
+
+// 缓存这次查询的结果，`tables`是查询涉及的表，不指定的话用`Name`指定的表，写入这些表时清掉缓存
+// 事务中的查询不会读写缓存
+// eg: db.Acquire().Name("region").Cache(time.Minute).FindMany(&regions)
+func (ctx *Context) Cache(ttl time.Duration, tables ...string) *Context {
+	ctx.cacheTTL, ctx.cacheTables = ttl, tables
+	return ctx
+}
+
+// 清掉表`table`相关的所有缓存，包括实体缓存
+func (db *DB) InvalidateTable(table string) {
+	db.invalidate(context.Background(), tagAll(table))
+}
+
+// 清掉表`table`中主键为`key`的缓存，同时清掉这张表的列表查询
+func (db *DB) InvalidateKey(table string, key interface{}) {
+	db.invalidate(context.Background(), tagKey(table, key), tagList(table))
+}
+
+// 缓存的标签，按主键查询的缓存: all、key，其他查询: all、list
+func tagAll(table string) string                  { return "all:" + table }
+func tagList(table string) string                 { return "list:" + table }
+func tagKey(table string, key interface{}) string { return fmt.Sprintf("key:%s:%v", table, key) }
+
+// 按主键`id=?`查询或者写入时的主键
+func (ctx *Context) primaryKey() (interface{}, bool) {
+	if len(ctx.wheres) != 1 || len(ctx.whereArgs) != 1 || !identityWhere.MatchString(ctx.wheres[0]) {
+		return nil, false
+	}
+	return ctx.whereArgs[0], true
+}
+
+// 查询缓存的`key`和标签，不需要缓存返回`false`
+// 事务中的查询不走缓存，和实体缓存一样，否则会把没提交的数据缓存给其他请求，或者把其他事务的结果共用过来
+func (ctx *Context) resultCacheKey(dest interface{}) (key string, tags []string, ok bool) {
+	cache, ttl := ctx.cacheOf()
+	if cache == nil || ttl <= 0 || ctx.tx != nil || ctx.filter != nil || ctx.coerce != nil && !ctx.implicitCoerce() {
+		return "", nil, false
+	}
+	tables := ctx.cacheTables
+	if len(tables) == 0 && ctx.name != "" {
+		tables = []string{ctx.name}
+	}
+	if len(tables) == 0 {
+		return "", nil, false
+	}
+	pk, byKey := ctx.primaryKey()
+	byKey = byKey && len(tables) == 1 && len(ctx.joins) == 0
+	for _, table := range tables {
+		tags = append(tags, tagAll(table))
+		if byKey {
+			tags = append(tags, tagKey(table, pk))
+		} else {
+			tags = append(tags, tagList(table))
+		}
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%T\x00%s\x00%#v", dest, ctx.sql, ctx.args)))
+	return "littleorm:" + hex.EncodeToString(sum[:]), tags, true
+}
+
+// 从缓存中读取结果
+func (ctx *Context) loadResult(ttx context.Context, key string, dest interface{}) bool {
+	cache, _ := ctx.cacheOf()
+	data, ok := cache.Get(ttx, key)
+	return ok && ctx.decodeResult(data, dest)
+}
+
+func (ctx *Context) decodeResult(data []byte, dest interface{}) bool {
+	if err := json.Unmarshal(data, dest); err != nil {
+		ctx.db.logError("littleorm decode cached result failed", LogField{"error", err})
+		return false
+	}
+	return true
+}
+
+// 缓存查询结果，返回编码以后的结果，编码失败返回`nil`
+func (ctx *Context) storeResult(ttx context.Context, key string, tags []string, dest interface{}) []byte {
+	data, err := json.Marshal(dest)
+	if err != nil {
+		ctx.db.logError("littleorm encode result failed", LogField{"error", err})
+		return nil
+	}
+	cache, ttl := ctx.cacheOf()
+	cache.Set(ttx, key, data, ttl, tags)
+	return data
+}
+
+// 写入以后清掉相关的缓存，`Transaction`中的写入提交以后再清一次
+func (ctx *Context) invalidateResults(ttx context.Context, query string) {
+	if (ctx.db.resultCache == nil && ctx.db.entityCache == nil) || ctx.name == "" {
+		return
+	}
+	var tags []string
+	if hasPrefixFold(strings.TrimSpace(query), "insert") {
+		tags = []string{tagList(ctx.name)}
+	} else if pk, ok := ctx.primaryKey(); ok {
+		tags = []string{tagKey(ctx.name, pk), tagList(ctx.name)}
+	} else {
+		tags = []string{tagAll(ctx.name)}
+	}
+	db := ctx.db
+	db.invalidate(ttx, tags...)
+	if ctx.scope != nil && ctx.scope.managed {
+		ctx.scope.onCommit(func() {
+			db.invalidate(context.Background(), tags...)
+		})
+	}
+}
+
+// 内存中的查询结果缓存，超过容量时先清掉过期的，还是超过的话随机清掉一些
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	items      map[string]memoryItem
+	tags       map[string]map[string]struct{} //标签 => 缓存的`key`
+}
+
+type memoryItem struct {
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// 创建内存缓存，`maxEntries`为`0`表示不限制个数
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		items:      make(map[string]memoryItem),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(item.expires) {
+		c.remove(key)
+		return nil, false
+	}
+	return item.value, true
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration, tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+	if c.maxEntries > 0 && len(c.items) >= c.maxEntries {
+		c.evict()
+	}
+	c.items[key] = memoryItem{value: value, expires: time.Now().Add(ttl), tags: tags}
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+}
+
+func (c *MemoryCache) Invalidate(_ context.Context, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.remove(key)
+		}
+	}
+}
+
+// 删除缓存项和标签的索引
+func (c *MemoryCache) remove(key string) {
+	item, ok := c.items[key]
+	if !ok {
+		return
+	}
+	delete(c.items, key)
+	for _, tag := range item.tags {
+		delete(c.tags[tag], key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// 先清掉过期的，还是满的话清掉四分之一，`map`遍历的顺序是随机的
+func (c *MemoryCache) evict() {
+	now := time.Now()
+	for key, item := range c.items {
+		if now.After(item.expires) {
+			c.remove(key)
+		}
+	}
+	if len(c.items) < c.maxEntries {
+		return
+	}
+	n := len(c.items)/4 + 1
+	for key := range c.items {
+		if n == 0 {
+			break
+		}
+		c.remove(key)
+		n--
+	}
+}
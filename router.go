@@ -0,0 +1,163 @@
+package littleorm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrTableRoute = errors.New("littleorm: table route failed")
+
+// 分表路由，按路由值(比如时间)找到实际的表
+type TableRouter interface {
+	// 路由值`value`所在的表
+	Route(table string, value interface{}) (string, error)
+	// `from`到`to`之间的所有表，按顺序返回
+	Tables(table string, from, to interface{}) ([]string, error)
+}
+
+// 按月分表，表名加上`_YYYYMM`后缀，路由值是`time.Time`，按路由值的时区计算月份
+// eg: events => events_202401
+type MonthlyRouter struct{}
+
+func (MonthlyRouter) Route(table string, value interface{}) (string, error) {
+	t, err := routeTime(value)
+	if err != nil {
+		return "", err
+	}
+	return table + t.Format("_200601"), nil
+}
+
+func (MonthlyRouter) Tables(table string, from, to interface{}) ([]string, error) {
+	start, err := routeTime(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := routeTime(to)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+	for !month.After(end) {
+		tables = append(tables, table+month.Format("_200601"))
+		month = month.AddDate(0, 1, 0)
+	}
+	return tables, nil
+}
+
+func routeTime(value interface{}) (time.Time, error) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t != nil {
+			return *t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: route value must be time.Time, got %T", ErrTableRoute, value)
+}
+
+// 注册表`table`的分表路由，之后可以用`Context.Route`和`Context.RouteRange`
+// eg: db.RouteTable("events", littleorm.MonthlyRouter{})
+func (db *DB) RouteTable(table string, router TableRouter) *DB {
+	db.routerMu.Lock()
+	defer db.routerMu.Unlock()
+	if db.routers == nil {
+		db.routers = make(map[string]TableRouter)
+	}
+	db.routers[table] = router
+	return db
+}
+
+func (db *DB) routerOf(table string) (TableRouter, bool) {
+	db.routerMu.RLock()
+	defer db.routerMu.RUnlock()
+	router, ok := db.routers[table]
+	return router, ok
+}
+
+// 按路由值把`Name`指定的表换成实际的表，需要先调用`Name`
+// eg: db.Acquire().Name("events").Route(event.CreatedAt).Insert(data)
+func (ctx *Context) Route(value interface{}) *Context {
+	router, ok := ctx.db.routerOf(ctx.name)
+	if !ok {
+		return ctx.routeError(fmt.Errorf("%w: no router for table %q", ErrTableRoute, ctx.name))
+	}
+	table, err := router.Route(ctx.name, value)
+	if err != nil {
+		return ctx.routeError(err)
+	}
+	ctx.name = table
+	return ctx
+}
+
+// 查询`from`到`to`之间的所有分表，按表的顺序依次查询并合并结果，只能用于查询
+// `FindMany`的`Order`只在每张表内有效，`Limit`是总的条数，查够了就不再查后面的表，不支持`Offset`
+// `FindOne`返回第一张有数据的表中的记录
+// eg: db.Acquire().Name("events").RouteRange(from, to).Where("user_id=?", uid).Order("created_at").Limit(100).FindMany(&events)
+func (ctx *Context) RouteRange(from, to interface{}) *Context {
+	router, ok := ctx.db.routerOf(ctx.name)
+	if !ok {
+		return ctx.routeError(fmt.Errorf("%w: no router for table %q", ErrTableRoute, ctx.name))
+	}
+	tables, err := router.Tables(ctx.name, from, to)
+	if err != nil {
+		return ctx.routeError(err)
+	}
+	ctx.routes = tables
+	return ctx
+}
+
+func (ctx *Context) routeError(err error) *Context {
+	if ctx.err == nil {
+		ctx.err = err
+	}
+	return ctx
+}
+
+// 依次查询每张分表
+func (ctx *Context) findRoutes(dest interface{}, fn selectFunc) error {
+	if ctx.offset > 0 || ctx.sql != "" || len(ctx.groups) > 0 {
+		return fmt.Errorf("%w: RouteRange does not support offset, group by or raw sql", ErrTableRoute)
+	}
+	tables := ctx.routes
+	if len(tables) == 0 {
+		return fmt.Errorf("%w: no table in range", ErrTableRoute)
+	}
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return fmt.Errorf("%w: dest must be a pointer, got %T", ErrTableRoute, dest)
+	}
+	if t.Elem().Kind() != reflect.Slice {
+		for i, table := range tables {
+			ctx.name, ctx.sql = table, ""
+			err := ctx.query(dest, fn)
+			if err == nil || !errors.Is(err, sql.ErrNoRows) || i == len(tables)-1 {
+				return err
+			}
+		}
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	merged := reflect.MakeSlice(rows.Type(), 0, 0)
+	limit := ctx.limit
+	for _, table := range tables {
+		if limit > 0 {
+			ctx.limit = limit - int64(merged.Len())
+		}
+		part := reflect.New(rows.Type())
+		ctx.name, ctx.sql = table, ""
+		if err := ctx.query(part.Interface(), fn); err != nil {
+			return err
+		}
+		merged = reflect.AppendSlice(merged, part.Elem())
+		if limit > 0 && int64(merged.Len()) >= limit {
+			break
+		}
+	}
+	rows.Set(merged)
+	return nil
+}
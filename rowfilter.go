@@ -0,0 +1,94 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// `FilterRows`的过滤函数
+type rowFilter func(row interface{}) bool
+
+// 扫描时逐行过滤，`fn`返回`false`的行直接丢掉，不会先把所有结果读到内存里再过滤
+// 用于没法写在`SQL`里的条件，比如解密以后再匹配，`row`是当前行的指针，eg: *User
+// `AfterScan`钩子在过滤前逐行执行，`Limit`在数据库中执行，过滤以后的行数可能比`Limit`少
+// 对`FindMany`、`FindOne`和`FindSeq`有效，带过滤的查询不走查询结果缓存
+// eg:
+//
+//	err := db.Acquire().Name("user").FilterRows(func(row interface{}) bool {
+//		return strings.HasPrefix(decrypt(row.(*User).Phone), "138")
+//	}).FindMany(&users)
+func (ctx *Context) FilterRows(fn func(row interface{}) bool) *Context {
+	ctx.filter = fn
+	return ctx
+}
+
+// 自己逐行扫描的查询，用于`FilterRows`和`Coerce`，`one`为`true`时返回第一条通过过滤的记录
+// 返回的函数签名和`sqlx.SelectContext`一样，执行时才读取`Context`上的设置，`prepare`会修改`unsafe`
+func (ctx *Context) manualSelect(one bool) selectFunc {
+	return func(ttx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+		rows, err := q.QueryxContext(ttx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		var types []*sql.ColumnType
+		if ctx.coerce != nil || ctx.columns != nil {
+			if types, err = rows.ColumnTypes(); err != nil {
+				return err
+			}
+		}
+		if ctx.columns != nil {
+			*ctx.columns = columnMetas(types)
+		}
+
+		target := reflect.ValueOf(dest).Elem()
+		elemType := target.Type()
+		if !one {
+			elemType = elemType.Elem()
+		}
+		isPtr := elemType.Kind() == reflect.Ptr
+		base := elemType
+		if isPtr {
+			base = base.Elem()
+		}
+		structScan := !scannable(base)
+		for rows.Next() {
+			row := reflect.New(base)
+			switch {
+			case ctx.coerce != nil:
+				err = ctx.coerce.scan(rows, types, row, ctx.unsafe)
+			case structScan:
+				err = rows.StructScan(row.Interface())
+			default:
+				err = rows.Scan(row.Interface())
+			}
+			if err == nil {
+				err = runAfterScan(row.Interface())
+			}
+			if err != nil {
+				return err
+			}
+			if ctx.filter != nil && !ctx.filter(row.Interface()) {
+				continue
+			}
+			if !isPtr {
+				row = row.Elem()
+			}
+			if one {
+				target.Set(row)
+				return rows.Close()
+			}
+			target.Set(reflect.Append(target, row))
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if one {
+			return sql.ErrNoRows
+		}
+		return nil
+	}
+}
@@ -0,0 +1,198 @@
+package littleorm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 脚本中某条语句执行失败
+type ScriptError struct {
+	Index     int    //第几条语句，从0开始
+	Statement string //执行失败的语句
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("littleorm: script statement #%d failed: %v, sql: <%s>", e.Index, e.Err, e.Statement)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// 执行多条语句的脚本，比如导出的表结构，按顺序一条一条执行，遇到错误就停止
+// 返回执行成功的语句条数，失败时返回`*ScriptError`，可以知道是哪一条语句失败了
+// 语句按数据库的规则拆分，见`SplitDialectStatements`，有事务的话在事务中执行
+func (ctx *Context) RunScript(script string) (n int, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	db, tx, parent := ctx.db, ctx.tx, ctx.parent
+	ctx.release()
+
+	statements, err := SplitDialectStatements(db.dialect.Name(), script)
+	if err != nil {
+		return
+	}
+	for i, statement := range statements {
+		if _, err = db.AcquireTx(tx).WithContext(parent).Exec(statement); err != nil {
+			return n, &ScriptError{Index: i, Statement: statement, Err: err}
+		}
+		n++
+	}
+	return
+}
+
+// 按`mysql`的规则拆分多条语句，见`SplitDialectStatements`
+func SplitStatements(script string) ([]string, error) {
+	return SplitDialectStatements("mysql", script)
+}
+
+// 按数据库的规则拆分多条语句，`dialect`是`Dialect.Name()`，默认用`;`分隔
+// 字符串、引号、注释中的分隔符不会拆分，单行注释会去掉
+// mysql: 支持客户端的`DELIMITER`命令修改分隔符(存储过程、触发器)，`#`和`-- `开头的是注释，字符串中可以用反斜杠转义
+// postgres: 支持`$$`、`$tag$`引起来的字符串(函数体)，`--`开头的是注释
+// 其他数据库按标准`SQL`，`--`开头的是注释
+func SplitDialectStatements(dialect, script string) (statements []string, err error) {
+	var (
+		mysql     = dialect == "mysql"
+		delimiter = ";"
+		current   strings.Builder
+		lineStart = true
+	)
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+		if !isBlankStatement(statement) {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+		if lineStart && mysql {
+			// 行首的`DELIMITER xx`是客户端命令，不发送到数据库
+			j := i
+			for j < len(script) && (script[j] == ' ' || script[j] == '\t') {
+				j++
+			}
+			if hasPrefixFold(script[j:], "delimiter ") {
+				end := strings.IndexByte(script[j:], '\n')
+				if end < 0 {
+					end = len(script) - j
+				}
+				flush()
+				delimiter = strings.TrimSpace(script[j+len("delimiter ") : j+end])
+				if delimiter == "" {
+					return nil, fmt.Errorf("littleorm: empty delimiter at offset %d", j)
+				}
+				i = j + end
+				continue
+			}
+		}
+		lineStart = false
+
+		switch {
+		case c == '\n':
+			lineStart = true
+			current.WriteByte(c)
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			end := closingQuote(script, i, mysql)
+			if end < 0 {
+				return nil, fmt.Errorf("littleorm: unterminated quote at offset %d", i)
+			}
+			current.WriteString(script[i : end+1])
+			i = end + 1
+		case mysql && (c == '#' || strings.HasPrefix(script[i:], "-- ")) || !mysql && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end
+			}
+		case c == '$' && dialect == "postgres" && dollarTag(script, i) != "":
+			tag := dollarTag(script, i)
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("littleorm: unterminated dollar quote at offset %d", i)
+			}
+			current.WriteString(script[i : i+len(tag)+end+len(tag)])
+			i += len(tag) + end + len(tag)
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("littleorm: unterminated comment at offset %d", i)
+			}
+			current.WriteString(script[i : i+2+end+2])
+			i += 2 + end + 2
+		case strings.HasPrefix(script[i:], delimiter):
+			flush()
+			i += len(delimiter)
+		default:
+			current.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return
+}
+
+// 找到引号结束的位置，支持两个引号的转义，`backslash`表示支持反斜杠转义(mysql)
+func closingQuote(script string, start int, backslash bool) int {
+	quote := script[start]
+	for i := start + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if backslash && quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// postgres美元符号引用的开始标记，eg: `$$`、`$body$`，不是的话返回空，`$1`这样的参数不算
+func dollarTag(script string, start int) string {
+	if start > 0 && isIdentByte(script[start-1]) {
+		return ""
+	}
+	for i := start + 1; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '$':
+			return script[start : i+1]
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80:
+		case '0' <= c && c <= '9' && i > start+1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
+
+// 只有普通注释的语句不需要执行，`/*! */`是`MySQL`的条件注释，需要执行
+func isBlankStatement(statement string) bool {
+	for statement != "" {
+		if !strings.HasPrefix(statement, "/*") || strings.HasPrefix(statement, "/*!") {
+			return false
+		}
+		end := strings.Index(statement, "*/")
+		statement = strings.TrimSpace(statement[end+2:])
+	}
+	return true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,139 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrUnknownSeed = errors.New("littleorm: unknown seed")
+	ErrSeedCycle   = errors.New("littleorm: seed dependency cycle")
+)
+
+// 记录已经执行过的种子数据的表
+const SeedTable = "littleorm_seeds"
+
+// 种子数据函数，在事务中执行，执行成功以后会记录到`SeedTable`中，不会重复执行
+type SeedFunc func(ctx context.Context, tx *sqlx.Tx) error
+
+type seeder struct {
+	fn   SeedFunc
+	deps []string
+}
+
+// 种子数据注册表，name => *seeder
+var (
+	seedMu  sync.RWMutex
+	seeders = make(map[string]*seeder)
+)
+
+// 注册种子数据，`deps`是依赖的种子数据，会先于当前的种子执行
+// 一般在`init`中注册，名字重复的话后注册的覆盖前面的
+func RegisterSeed(name string, fn SeedFunc, deps ...string) {
+	seedMu.Lock()
+	defer seedMu.Unlock()
+	seeders[name] = &seeder{fn: fn, deps: deps}
+}
+
+// 执行指定的种子数据，不指定的话执行所有注册的种子数据，依赖的种子数据会先执行
+// 每个种子数据在单独的事务中执行，执行过的会跳过，所以可以在每次启动时调用
+// 种子数据函数直接拿到事务，只读模式下直接返回`ErrReadOnly`，一个种子都不执行
+func (db *DB) Seed(ctx context.Context, names ...string) error {
+	order, err := seedOrder(names)
+	if err != nil {
+		return err
+	}
+	if db.IsReadOnly() {
+		return ErrReadOnly
+	}
+	if _, err = db.AcquireContext(ctx).Create("create table if not exists " + SeedTable + " (name varchar(191) not null primary key, applied_at bigint not null)"); err != nil {
+		return err
+	}
+	for _, name := range order {
+		if err = db.seedOne(ctx, name); err != nil {
+			return fmt.Errorf("littleorm: seed %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) seedOne(ctx context.Context, name string) (err error) {
+	seedMu.RLock()
+	s := seeders[name]
+	seedMu.RUnlock()
+
+	tx, err := db.Pool().BeginTxx(ctx, nil)
+	if err != nil {
+		return
+	}
+	// 已经执行过或者出错都需要回滚，提交以后再回滚什么也不做
+	defer tx.Rollback()
+
+	var applied int64
+	if err = db.AcquireTx(tx).Get(&applied, "select count(*) from "+SeedTable+" where name=?", name); err != nil || applied > 0 {
+		return
+	}
+	if err = s.fn(ctx, tx); err != nil {
+		return
+	}
+	// 主键冲突说明其他进程同时执行了这个种子，事务回滚
+	if _, err = db.AcquireTx(tx).Name(SeedTable).Insert(map[string]interface{}{"name": name, "applied_at": time.Now().Unix()}); err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+// 按依赖关系排序，依赖的在前面，不存在的种子返回`ErrUnknownSeed`，循环依赖返回`ErrSeedCycle`
+func seedOrder(names []string) ([]string, error) {
+	seedMu.RLock()
+	defer seedMu.RUnlock()
+	if len(names) == 0 {
+		for name := range seeders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	var (
+		order []string
+		state = make(map[string]int, len(seeders))
+		visit func(name string, path []string) error
+	)
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %v", ErrSeedCycle, append(path, name))
+		}
+		s, ok := seeders[name]
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownSeed, name)
+		}
+		state[name] = visiting
+		for _, dep := range s.deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,101 @@
+package littleorm
+
+import (
+	"database/sql"
+	"iter"
+	"reflect"
+	"time"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// 以迭代器的方式返回查询结果，一次只扫描一行，不需要把所有结果都放到切片中，适合结果比较多的查询
+// eg: for user, err := range littleorm.FindSeq[User](db.Acquire().Name("user").Where("age>?", 18)) {}
+// `T`是结构体的话按`db`标签扫描，否则扫描单个字段，出错时返回一次错误然后结束
+// 整个遍历过程都在`DB`的超时时间内，提前`break`会关闭结果集，`Context`在遍历结束以后放回池子，只能遍历一次
+func FindSeq[T any](ctx *Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if err := ctx.inUse(); err != nil {
+			yield(zero, err)
+			return
+		}
+		defer ctx.release()
+		if ctx.err != nil {
+			yield(zero, ctx.err)
+			return
+		}
+		ctx.applyZeroDates()
+		ttx, cancel := ctx.context()
+		defer cancel()
+		if err := ctx.prepare(ttx, &zero); err != nil {
+			yield(zero, err)
+			return
+		}
+		if err := ctx.dryRun(ctx.sql, ctx.args); err != nil {
+			yield(zero, err)
+			return
+		}
+		stop, err := ctx.watchCancel(ttx)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer stop()
+		rows, done, err := ctx.queryRows(ttx, ctx.sql, ctx.args)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer done()
+		var types []*sql.ColumnType
+		if ctx.coerce != nil {
+			if types, err = rows.ColumnTypes(); err != nil {
+				yield(zero, err)
+				return
+			}
+		}
+
+		structScan := !scannable(reflect.TypeOf(zero))
+		for rows.Next() {
+			var row T
+			switch {
+			case ctx.coerce != nil:
+				err = ctx.coerce.scan(rows, types, reflect.ValueOf(&row), ctx.unsafe)
+			case structScan:
+				err = rows.StructScan(&row)
+			default:
+				err = rows.Scan(&row)
+			}
+			if err == nil {
+				ctx.db.bindLazies(&row)
+				err = runAfterScan(&row)
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if ctx.filter != nil && !ctx.filter(&row) {
+				continue
+			}
+			ctx.mask(&row)
+			if !yield(row, nil) {
+				return
+			}
+		}
+		if err = rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// 可以直接扫描的类型，不是结构体或者实现了`sql.Scanner`，和sqlx的判断保持一致
+func scannable(t reflect.Type) bool {
+	if t == nil || t.Kind() != reflect.Struct || t == timeType {
+		return true
+	}
+	return reflect.PointerTo(t).Implements(scannerType)
+}
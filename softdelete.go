@@ -0,0 +1,49 @@
+package littleorm
+
+// 开启软删除，`column`是记录删除时间的字段，为空表示没有删除
+// 开启以后`Delete`改成`update ... set column=current_timestamp`，查询和更新自动加上`column is null`
+// 也可以在模型中用标签开启，eg: `db:"deleted_at,softdelete"`，查询、`UpdateStruct`时按目标对象的模型判断
+// `Delete`不知道模型，用标签开启时删除还是要调用`SoftDelete`，计数缓存只在真删除时更新
+// 关联多张表时最好带上表名，eg: SoftDelete("u.deleted_at")
+func (ctx *Context) SoftDelete(column string) *Context {
+	ctx.softDelete = column
+	return ctx
+}
+
+// 忽略软删除，查询时包括已经删除的记录，`Delete`也会真删除
+func (ctx *Context) Unscoped() *Context {
+	ctx.unscoped = true
+	return ctx
+}
+
+// 真删除，不管有没有开启软删除，已经软删除的记录也会删掉
+func (ctx *Context) ForceDelete() (rowsAffected int64, err error) {
+	return ctx.Unscoped().Delete()
+}
+
+// 软删除字段，`SoftDelete`指定的优先，没有的话用目标对象模型中的字段，`Unscoped`以后返回空
+func (ctx *Context) softColumn(dest interface{}) string {
+	if ctx.unscoped {
+		return ""
+	}
+	if ctx.softDelete != "" {
+		return ctx.softDelete
+	}
+	if m := modelOf(dest); m != nil {
+		return m.deletedAt
+	}
+	return ""
+}
+
+// 加上软删除过滤以后的条件，原来的条件整体加上括号，避免`OrWhere`的`or`和过滤条件的优先级混在一起
+func (ctx *Context) scopedWheres(dest interface{}) []string {
+	column := ctx.softColumn(dest)
+	if column == "" {
+		return ctx.wheres
+	}
+	filter := column + " is null"
+	if len(ctx.wheres) == 0 {
+		return []string{filter}
+	}
+	return []string{"(" + sqljoin(ctx.wheres, Grouping) + ")", filter}
+}
@@ -0,0 +1,91 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 表结构缓存的时间，滚动发布时加了字段以后过一会就能查到
+const tableColumnsTTL = time.Minute
+
+// 表中的字段
+type tableColumns struct {
+	columns map[string]bool
+	loaded  time.Time
+}
+
+// 给模型开启宽松的表结构检查，用于表结构和代码不是同时发布的场景，和其他注册一样在启动时调用
+// 开启以后查询这个模型时:
+//   - 结构体中有、表中还没有的字段不查询，保持零值，第一次遇到时打印警告
+//   - `Select`、`Get`直接用`select *`查出来的、结构体中没有的字段忽略，不再报`missing destination name`
+//
+// 表结构会缓存一分钟，固定连接(`TempTable.Acquire`)上的查询不会忽略多出来的字段
+// eg: littleorm.SoftSchema(User{})
+func SoftSchema(value interface{}) {
+	t := structType(value)
+	if t == nil {
+		panic(fmt.Sprintf("littleorm: SoftSchema must be registered on a struct, got %T", value))
+	}
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	m := *lookupModel(t)
+	m.soft = true
+	models.Store(t, &m)
+}
+
+// 宽松检查的模型只查询表中已经有的字段
+func (ctx *Context) resolveSoftSchema(ttx context.Context, dest interface{}) error {
+	m := modelOf(dest)
+	if m == nil || !m.soft || len(ctx.what) != 0 || len(ctx.joins) != 0 || ctx.name == "" {
+		return nil
+	}
+	columns, err := ctx.db.tableColumns(ttx, ctx.queryer(), ctx.name)
+	if err != nil {
+		return err
+	}
+	selected := make([]string, 0, len(m.columns))
+	for _, column := range m.columns {
+		if _, ok := m.computed[column]; ok || columns[column] {
+			selected = append(selected, column)
+			continue
+		}
+		if _, warned := ctx.db.softWarned.LoadOrStore(ctx.name+"."+column, true); !warned {
+			ctx.db.logInfo("littleorm soft schema: column not found in table, skipped", LogField{"column", column}, LogField{"model", structType(dest)}, LogField{"table", ctx.name})
+		}
+	}
+	ctx.What(m.selectColumns(selected))
+	return nil
+}
+
+// 查询表中的字段，缓存一段时间
+func (db *DB) tableColumns(ttx context.Context, q sqlx.QueryerContext, table string) (map[string]bool, error) {
+	db.softMu.Lock()
+	cached, ok := db.softTables[table]
+	db.softMu.Unlock()
+	if ok && time.Since(cached.loaded) < tableColumnsTTL {
+		return cached.columns, nil
+	}
+	rows, err := q.QueryxContext(ttx, "select * from "+table+" where 1=0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[name] = true
+	}
+	db.softMu.Lock()
+	if db.softTables == nil {
+		db.softTables = make(map[string]tableColumns)
+	}
+	db.softTables[table] = tableColumns{columns: columns, loaded: time.Now()}
+	db.softMu.Unlock()
+	return columns, nil
+}
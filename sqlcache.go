@@ -0,0 +1,88 @@
+package littleorm
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+)
+
+// 缓存拼接好的`SQL`，key是查询的结构特征(表名、字段、条件、排序、分组、分页、锁)，不包含参数
+// 同样结构的查询在循环里反复执行时可以跳过拼接，可以自己实现替换默认的缓存
+type SQLCache interface {
+	Get(key string) (string, bool)
+	Set(key, sql string)
+}
+
+// 设置`SQL`缓存，`nil`表示关闭，默认关闭
+func (db *DB) SQLCache(cache SQLCache) *DB {
+	db.sqlCache = cache
+	return db
+}
+
+// 默认的`SQL`缓存，超过容量以后随机淘汰一个
+type sqlCache struct {
+	mu    sync.RWMutex
+	size  int
+	items map[string]string
+}
+
+// 创建一个容量为`size`的`SQL`缓存
+func NewSQLCache(size int) SQLCache {
+	return &sqlCache{size: size, items: make(map[string]string, size)}
+}
+
+func (c *sqlCache) Get(key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	sql, ok := c.items[key]
+	return sql, ok
+}
+
+func (c *sqlCache) Set(key, sql string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; !ok && len(c.items) >= c.size {
+		// map的遍历是随机的，随便删一个
+		for k := range c.items {
+			delete(c.items, k)
+			break
+		}
+	}
+	c.items[key] = sql
+}
+
+// 查询的结构特征，各部分用`\x00`分隔，避免拼接以后出现歧义
+func (ctx *Context) shapeKey(dest interface{}) string {
+	buf := sqlBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		sqlBufferPool.Put(buf)
+	}()
+	buf.WriteString(ctx.name)
+	buf.WriteByte(0)
+	writeJoin(buf, ctx.joins, "\x01")
+	buf.WriteByte(0)
+	if len(ctx.what) != 0 {
+		writeJoin(buf, ctx.what, SeqComma)
+	} else if m := modelOf(dest); m != nil {
+		buf.WriteString(m.selects)
+	}
+	buf.WriteByte(0)
+	writeJoin(buf, ctx.wheres, "\x01")
+	buf.WriteByte(0)
+	buf.WriteString(ctx.softColumn(dest))
+	buf.WriteByte(0)
+	writeJoin(buf, ctx.groups, SeqComma)
+	buf.WriteByte(0)
+	writeConditions(buf, ctx.havings)
+	buf.WriteByte(0)
+	buf.WriteString(ctx.order)
+	buf.WriteByte(0)
+	buf.WriteString(strconv.FormatInt(ctx.offset, 10))
+	buf.WriteByte(0)
+	buf.WriteString(strconv.FormatInt(ctx.limit, 10))
+	buf.WriteByte(0)
+	clause, _ := ctx.db.dialect.LockClause(ctx.lock)
+	buf.WriteString(clause)
+	return buf.String()
+}
@@ -0,0 +1,94 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 表的统计信息
+type TableStat struct {
+	Name          string `db:"name" json:"name"`
+	Rows          int64  `db:"rows" json:"rows"`                     //行数，mysql和postgres是估算值
+	DataSize      int64  `db:"data_size" json:"data_size"`           //数据大小，字节
+	IndexSize     int64  `db:"index_size" json:"index_size"`         //索引大小，字节
+	AutoIncrement int64  `db:"auto_increment" json:"auto_increment"` //下一个自增值，没有自增字段是0
+}
+
+// 获取表的行数、数据和索引大小、自增值，不指定表的话返回当前库所有的表
+// mysql从`information_schema.tables`中读取，postgres从`pg_stat_user_tables`中读取，自增值是0
+// sqlite3没有统计表，行数用`count(*)`计算，大小是0
+func (db *DB) TableStats(ctx context.Context, tables ...string) ([]TableStat, error) {
+	var query string
+	switch db.dialect.Name() {
+	case "sqlite3":
+		return db.sqliteTableStats(ctx, tables)
+	case "postgres":
+		query = `select relname as name, n_live_tup as rows,
+			pg_relation_size(relid) as data_size, pg_indexes_size(relid) as index_size, 0 as auto_increment
+			from pg_stat_user_tables`
+		if len(tables) > 0 {
+			query += " where relname in (?)"
+		}
+	default:
+		query = `select table_name as name, coalesce(table_rows, 0) as ` + "`rows`" + `,
+			coalesce(data_length, 0) as data_size, coalesce(index_length, 0) as index_size,
+			coalesce(auto_increment, 0) as auto_increment
+			from information_schema.tables where table_schema = database()`
+		if len(tables) > 0 {
+			query += " and table_name in (?)"
+		}
+	}
+	query += " order by name"
+
+	var (
+		args []interface{}
+		err  error
+	)
+	if len(tables) > 0 {
+		if query, args, err = sqlx.In(query, tables); err != nil {
+			return nil, err
+		}
+	}
+	var stats []TableStat
+	err = sqlx.SelectContext(ctx, db.bound(db.Pool()), &stats, query, args...)
+	return stats, err
+}
+
+func (db *DB) sqliteTableStats(ctx context.Context, tables []string) ([]TableStat, error) {
+	var names []string
+	err := db.Pool().SelectContext(ctx, &names, "select name from sqlite_master where type = 'table' and name not like 'sqlite_%' order by name")
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) > 0 {
+		var filtered []string
+		for _, name := range names {
+			if containsString(tables, name) {
+				filtered = append(filtered, name)
+			}
+		}
+		names = filtered
+	}
+
+	// 有`autoincrement`字段的表才会有`sqlite_sequence`
+	var sequence int64
+	if err = db.Pool().GetContext(ctx, &sequence, "select count(*) from sqlite_master where type = 'table' and name = 'sqlite_sequence'"); err != nil {
+		return nil, err
+	}
+	stats := make([]TableStat, 0, len(names))
+	for _, name := range names {
+		stat := TableStat{Name: name}
+		if err = db.Pool().GetContext(ctx, &stat.Rows, fmt.Sprintf(`select count(*) from "%s"`, name)); err != nil {
+			return nil, err
+		}
+		if sequence > 0 {
+			if err = db.Pool().GetContext(ctx, &stat.AutoIncrement, "select coalesce(max(seq), 0) + 1 from sqlite_sequence where name = ?", name); err != nil {
+				return nil, err
+			}
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
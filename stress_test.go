@@ -0,0 +1,97 @@
+//go:build stress
+// +build stress
+
+// 并发压力测试，默认使用`MySQL`，需要先启动测试数据库(docker-compose up -d)，然后执行:
+//
+//	go test -race -tags stress -run Stress
+//
+// 设置`LITTLEORM_TEST_DRIVER=sqlite3`可以在内存中的`SQLite`上跑，只有一个连接，测不出连接之间的并发问题
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+const stressWorkers = 200
+
+// 没有设置`LITTLEORM_TEST_DRIVER`时使用`MySQL`，包级变量在`init`之前初始化
+var _ = func() bool {
+	if driver == "" {
+		driver = "mysql"
+	}
+	return true
+}()
+
+var errStressRollback = errors.New("stress rollback")
+
+func TestStressConcurrentQueries(t *testing.T) {
+	result, err := db.Acquire().Name(tablename).Insert(map[string]interface{}{"name": name + "-stress", "age": age})
+	assert.Equal(t, nil, err)
+	seed, err := result.LastInsertId()
+	assert.Equal(t, nil, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressWorkers)
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := stressWorker(i, seed); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func stressWorker(i int, seed int64) error {
+	var (
+		little  LittleOrm
+		littles []LittleOrm
+	)
+	if err := db.Acquire().Name(tablename).Where("id=?", seed).FindOne(&little); err != nil {
+		return fmt.Errorf("worker %d find one: %w", i, err)
+	}
+	if err := db.Acquire().Name(tablename).WhereIn("id", []interface{}{seed, seed + 1}).Order("id").FindMany(&littles); err != nil {
+		return fmt.Errorf("worker %d find many: %w", i, err)
+	}
+	data := map[string]interface{}{"name": fmt.Sprintf("%s-stress-%d", name, i), "age": i % 100}
+	if _, err := db.Acquire().Name(tablename).Insert(data); err != nil {
+		return fmt.Errorf("worker %d insert: %w", i, err)
+	}
+	if _, err := db.Acquire().Name(tablename).Where("name=?", data["name"]).Update("age=age+?", 1); err != nil {
+		return fmt.Errorf("worker %d update: %w", i, err)
+	}
+	// 一半的事务提交，一半的事务回滚
+	err := db.WithTx(func(tx *sqlx.Tx, args interface{}) error {
+		var row LittleOrm
+		if err := db.AcquireTx(tx).Name(tablename).Where("name=?", args).FindOne(&row); err != nil {
+			return err
+		}
+		if _, err := db.AcquireTx(tx).Name(tablename).Where("id=?", row.Id).Update("age=?", 0); err != nil {
+			return err
+		}
+		if i%2 == 0 {
+			return errStressRollback
+		}
+		return nil
+	}, data["name"])
+	if i%2 == 0 && !errors.Is(err, errStressRollback) {
+		return fmt.Errorf("worker %d tx: expect rollback error, got %v", i, err)
+	}
+	if i%2 == 1 && err != nil {
+		return fmt.Errorf("worker %d tx: %w", i, err)
+	}
+	_, err = db.Acquire().Name(tablename).Where("name=?", data["name"]).Delete()
+	return err
+}
@@ -0,0 +1,143 @@
+package littleorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrRefreshSummary = errors.New("littleorm: invalid summary refresh")
+
+// 汇总表的刷新方式
+type RefreshMode int
+
+const (
+	// 全量重建，`MySQL`先把数据写到新表，再用`rename`原子替换，刷新过程中查询不受影响；其他数据库在事务中删除再插入
+	RefreshFull RefreshMode = iota
+	// 增量刷新，按唯一键插入或者更新查询的结果，不会删除汇总表中已有的其他数据
+	RefreshIncremental
+)
+
+// 用查询的结果刷新汇总表，模拟物化视图，汇总表需要自己先建好
+// `sel`是汇总的查询，需要用`What`指定字段，字段名(或者`as`后面的别名)就是汇总表的字段
+// 增量刷新需要`keys`指定汇总表的唯一键，`MySQL`用`on duplicate key update`，其他数据库用`on conflict`
+// eg:
+//
+//	sel := db.Acquire().Name("orders").What([]string{"user_id", "count(*) as orders", "sum(amount) as amount"}).
+//		Where("created_at>=?", since).GroupExpr("user_id")
+//	err := db.RefreshSummary(ctx, "user_order_summary", sel, littleorm.RefreshIncremental, "user_id")
+func (db *DB) RefreshSummary(ctx context.Context, name string, sel *Context, mode RefreshMode, keys ...string) error {
+	query, args, columns, err := summarySelect(sel)
+	if err != nil {
+		return err
+	}
+	insert := fmt.Sprintf("insert into %s (%s) %s", name, sqljoin(columns, SeqComma), query)
+	dialect := db.dialect.Name()
+
+	switch mode {
+	case RefreshFull:
+		if dialect == "mysql" {
+			return db.rebuildSummary(ctx, name, columns, query, args)
+		}
+		return db.Transaction(ctx, PropagationRequired, func(ctx context.Context) error {
+			if _, err := db.From(ctx).Exec("delete from " + name); err != nil {
+				return err
+			}
+			_, err := db.From(ctx).Exec(insert, args...)
+			return err
+		})
+	case RefreshIncremental:
+		if len(keys) == 0 {
+			return fmt.Errorf("%w: incremental refresh needs unique keys", ErrRefreshSummary)
+		}
+		sets := make([]string, 0, len(columns))
+		for _, column := range columns {
+			if containsString(keys, column) {
+				continue
+			}
+			if dialect == "mysql" {
+				sets = append(sets, fmt.Sprintf("%s=values(%s)", column, column))
+			} else {
+				sets = append(sets, fmt.Sprintf("%s=excluded.%s", column, column))
+			}
+		}
+		if dialect == "mysql" {
+			insert += " on duplicate key update " + sqljoin(sets, SeqComma)
+		} else {
+			// `SQLite`的`insert ... select`后面直接跟`on conflict`有歧义，需要包一层带`where`的查询
+			insert = fmt.Sprintf("insert into %s (%s) select * from (%s) t where true on conflict (%s) do ",
+				name, sqljoin(columns, SeqComma), query, sqljoin(keys, SeqComma))
+			if len(sets) == 0 {
+				insert += "nothing"
+			} else {
+				insert += "update set " + sqljoin(sets, SeqComma)
+			}
+		}
+		return db.Transaction(ctx, PropagationRequired, func(ctx context.Context) error {
+			_, err := db.From(ctx).Exec(insert, args...)
+			return err
+		})
+	}
+	return fmt.Errorf("%w: unknown mode %d", ErrRefreshSummary, mode)
+}
+
+// `MySQL`的全量重建，`DDL`会隐式提交，不能放在事务中，用新表加`rename`保证替换是原子的
+func (db *DB) rebuildSummary(ctx context.Context, name string, columns []string, query string, args []interface{}) (err error) {
+	fresh, stale := name+"__new", name+"__old"
+	exec := func(query string, args ...interface{}) error {
+		_, err := db.Acquire().WithContext(ctx).Exec(query, args...)
+		return err
+	}
+	if err = exec(fmt.Sprintf("drop table if exists %s, %s", fresh, stale)); err != nil {
+		return
+	}
+	if err = exec(fmt.Sprintf("create table %s like %s", fresh, name)); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = exec("drop table if exists " + fresh)
+		}
+	}()
+	if err = exec(fmt.Sprintf("insert into %s (%s) %s", fresh, sqljoin(columns, SeqComma), query), args...); err != nil {
+		return
+	}
+	if err = exec(fmt.Sprintf("rename table %s to %s, %s to %s", name, stale, fresh, name)); err != nil {
+		return
+	}
+	return exec("drop table " + stale)
+}
+
+// 生成汇总查询的语句，返回语句、参数和字段名，`sel`会放回池子
+func summarySelect(sel *Context) (query string, args []interface{}, columns []string, err error) {
+	if err = sel.inUse(); err != nil {
+		return
+	}
+	defer sel.release()
+	if sel.err != nil {
+		return "", nil, nil, sel.err
+	}
+	if len(sel.what) == 0 {
+		return "", nil, nil, fmt.Errorf("%w: summary select needs What", ErrRefreshSummary)
+	}
+	columns = make([]string, len(sel.what))
+	for i, item := range sel.what {
+		columns[i] = outputColumn(item)
+	}
+	sel.args = sel.selectArgs()
+	query = sel.sqlselect(nil)
+	return query, append([]interface{}(nil), sel.args...), columns, nil
+}
+
+// 查询字段的输出名，有别名用别名，否则去掉表名，eg: count(*) as total => total, u.name => name
+func outputColumn(item string) string {
+	item = strings.TrimSpace(item)
+	if i := strings.LastIndex(strings.ToLower(item), " as "); i >= 0 {
+		return strings.TrimSpace(item[i+4:])
+	}
+	if i := strings.LastIndex(item, "."); i >= 0 {
+		return item[i+1:]
+	}
+	return item
+}
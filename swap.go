@@ -0,0 +1,74 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// `SwapTarget`切换以后的连接池
+type target struct {
+	db  *sqlx.DB
+	dsn string
+}
+
+// 切换到`newDSN`的连接池，之后执行的语句都用新的连接池，旧连接池等一个超时时间以后再关闭，
+// 已经拿到旧连接池还没开始执行的语句不会失败，关闭时还在执行的语句和事务执行完以后才关闭
+// 用来轮换数据库密码、蓝绿切换到新的库，先连上新库再切换，连不上返回错误，还是用原来的连接池
+// 新连接池沿用原来的最大连接数，其他设置用`Pool`拿到新连接池以后再设置
+// 切换以后内嵌的`*sqlx.DB`还是最开始的连接池，直接调用它的方法(比如`db.Beginx`)在旧连接池关闭以后会返回连接池已关闭，用`Pool`
+// `NewFromDB`、`NewFromSqlx`传进来的连接池由调用方管理，切换以后不会关闭
+// eg: err := db.SwapTarget("user:rotated@tcp(127.0.0.1:3306)/test")
+func (db *DB) SwapTarget(newDSN string) error {
+	next, err := sqlx.Open(db.DriverName(), newDSN)
+	if err != nil {
+		return fmt.Errorf("littleorm: swap target: %w", err)
+	}
+	ttx, cancel := context.WithTimeout(context.Background(), db.timeout)
+	defer cancel()
+	if err = next.PingContext(ttx); err != nil {
+		next.Close()
+		return fmt.Errorf("littleorm: swap target: %w", err)
+	}
+
+	db.swapMu.Lock()
+	defer db.swapMu.Unlock()
+	old := db.Pool()
+	next.SetMaxOpenConns(old.Stats().MaxOpenConnections)
+	next.Mapper = old.Mapper
+	prev := db.target.Swap(&target{db: next, dsn: newDSN})
+	if prev == nil && db.dataSourceName == "" {
+		return nil
+	}
+	// 切换前拿到旧连接池的语句可能还没开始执行，等一个超时时间再关，`Close`会等正在执行的语句执行完
+	time.AfterFunc(db.timeout, func() {
+		if err := old.Close(); err != nil {
+			db.logError("littleorm swap target close failed", LogField{"error", err})
+		}
+	})
+	db.logInfo("littleorm swap target", LogField{"driver", db.DriverName()})
+	return nil
+}
+
+// 当前使用的连接池，`SwapTarget`切换过的话是新的连接池
+func (db *DB) Pool() *sqlx.DB {
+	if t := db.target.Load(); t != nil {
+		return t.db
+	}
+	return db.DB
+}
+
+// 当前连接池的连接配置
+func (db *DB) source() string {
+	if t := db.target.Load(); t != nil {
+		return t.dsn
+	}
+	return db.dataSourceName
+}
+
+// 关闭当前的连接池
+func (db *DB) Close() error {
+	return db.Pool().Close()
+}
@@ -0,0 +1,33 @@
+package littleorm
+
+import "context"
+
+// `context.Context`中保存标签的`key`
+type tagsKey struct{}
+
+// 给`ctx`加上标签，比如接口名，方便统计和日志按来源区分查询，和`ctx`中已有的标签合并
+// eg: ctx = littleorm.WithTags(ctx, map[string]string{"grpc.method": "GetUser"})
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for k, v := range TagsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// 获取`ctx`中的标签，不要修改返回的`map`
+func TagsFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// 查询的标签，来自`WithContext`指定的`context.Context`
+func (ctx *Context) Tags() map[string]string {
+	return TagsFromContext(ctx.parent)
+}
@@ -0,0 +1,141 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 临时表批量插入时每批的行数
+const tempTableBatch = 500
+
+// 临时表，只在创建它的连接上可见，所以会固定一个连接，用完必须`Close`把连接还回去
+// 适合把大量`id`插入临时表再关联查询，比很长的`in`列表快
+type TempTable struct {
+	db   *DB
+	conn *sqlx.Conn
+	name string
+}
+
+// 创建临时表，`ddlOrStruct`是字段定义(eg: "id bigint primary key")或者结构体，结构体根据`db`标签和字段类型生成字段定义
+// eg:
+//
+//	tmp, err := db.CreateTempTable(ctx, "tmp_ids", "id bigint primary key")
+//	defer tmp.Close()
+//	err = tmp.Insert([]string{"id"}, ids...)
+//	err = tmp.Acquire().Name("user join tmp_ids on tmp_ids.id = user.id").What([]string{"user.*"}).FindMany(&users)
+func (db *DB) CreateTempTable(ctx context.Context, name string, ddlOrStruct interface{}) (*TempTable, error) {
+	var ddl string
+	switch v := ddlOrStruct.(type) {
+	case string:
+		ddl = v
+	default:
+		var err error
+		if ddl, err = db.structDDL(v); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := db.Pool().Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t := &TempTable{db: db, conn: conn, name: name}
+	if _, err = t.Acquire().Exec(fmt.Sprintf("create temporary table %s (%s)", name, ddl)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// 临时表的表名
+func (t *TempTable) Name() string {
+	return t.name
+}
+
+// 获取在临时表所在连接上执行的`Context`，用来关联查询临时表
+func (t *TempTable) Acquire() *Context {
+	ctx := t.db.Acquire()
+	ctx.conn = t.conn
+	return ctx
+}
+
+// 批量插入临时表，数据很多时分批插入
+func (t *TempTable) Insert(fields []string, rows ...[]interface{}) error {
+	for start := 0; start < len(rows); start += tempTableBatch {
+		end := start + tempTableBatch
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if _, err := t.Acquire().Name(t.name).InsertBatch(fields, rows[start:end]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 插入单个字段的值，eg: tmp.InsertValues("id", 1, 2, 3)
+func (t *TempTable) InsertValues(field string, values ...interface{}) error {
+	rows := make([][]interface{}, len(values))
+	for i, value := range values {
+		rows[i] = []interface{}{value}
+	}
+	return t.Insert([]string{field}, rows...)
+}
+
+// 删除临时表并把连接还回连接池
+func (t *TempTable) Close() error {
+	_, err := t.Acquire().Exec("drop table " + t.name)
+	if cerr := t.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// 根据结构体生成字段定义
+func (db *DB) structDDL(value interface{}) (string, error) {
+	t := structType(value)
+	if t == nil {
+		return "", fmt.Errorf("littleorm: temp table definition must be a ddl string or struct, got %T", value)
+	}
+	m := lookupModel(t)
+	columns := make([]string, 0, len(m.columns))
+	for _, column := range m.columns {
+		columnType, err := db.columnType(t.Field(m.index[column]).Type)
+		if err != nil {
+			return "", fmt.Errorf("littleorm: temp table column %s: %w", column, err)
+		}
+		columns = append(columns, column+" "+columnType)
+	}
+	return sqljoin(columns, SeqComma), nil
+}
+
+// Go类型对应的数据库类型
+func (db *DB) columnType(t reflect.Type) (string, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return "timestamp", nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "bigint", nil
+	case reflect.Float32, reflect.Float64:
+		return "double precision", nil
+	case reflect.String:
+		return "varchar(255)", nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if db.dialect.Name() == "postgres" {
+				return "bytea", nil
+			}
+			return "blob", nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", t)
+}
@@ -0,0 +1,39 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUnknownTier = errors.New("littleorm: unknown timeout tier")
+
+// 注册超时档位，查询时用`Tier`按名字选择，统一各个业务的超时时间，不用到处写`time.Second * 3`
+// 和其他配置一样在启动时调用，重复注册会覆盖
+// eg: db.TimeoutTier("interactive", 2*time.Second).TimeoutTier("report", time.Minute)
+func (db *DB) TimeoutTier(name string, timeout time.Duration) *DB {
+	db.tierMu.Lock()
+	defer db.tierMu.Unlock()
+	if db.tiers == nil {
+		db.tiers = make(map[string]time.Duration)
+	}
+	db.tiers[name] = timeout
+	return db
+}
+
+// 使用注册的超时档位代替`Open`时的超时时间，没有注册的档位执行时返回`ErrUnknownTier`
+// 调用方的`context.Context`有更早的截止时间时以调用方的为准
+// eg: db.Acquire().Name("order").Tier("report").FindMany(&orders)
+func (ctx *Context) Tier(name string) *Context {
+	ctx.db.tierMu.RLock()
+	timeout, ok := ctx.db.tiers[name]
+	ctx.db.tierMu.RUnlock()
+	if !ok {
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("%w: %q", ErrUnknownTier, name)
+		}
+		return ctx
+	}
+	ctx.timeout = timeout
+	return ctx
+}
@@ -0,0 +1,63 @@
+package littleorm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 多个字段组合的`in`条件，用于联合主键之类的查询
+// `mysql`和`postgres`生成`(a, b) in ((?, ?), (?, ?))`，其他数据库生成`(a=? and b=?) or (a=? and b=?)`
+// `tuples`为空时生成`1=0`，什么都查不到，每个元组的长度必须和`columns`一样
+// eg: db.Acquire().Name("stock").WhereInTuples([]string{"shop_id", "sku_id"}, [][]interface{}{{1, 100}, {2, 200}}).FindMany(&stocks)
+func (ctx *Context) WhereInTuples(columns []string, tuples [][]interface{}) *Context {
+	if len(columns) == 0 {
+		ctx.err = fmt.Errorf("littleorm: WhereInTuples needs columns")
+		return ctx
+	}
+	if len(tuples) == 0 {
+		return ctx.Where("1=0")
+	}
+	args := make([]interface{}, 0, len(columns)*len(tuples))
+	for i, tuple := range tuples {
+		if len(tuple) != len(columns) {
+			ctx.err = fmt.Errorf("littleorm: tuple #%d has %d values, want %d", i, len(tuple), len(columns))
+			return ctx
+		}
+		args = append(args, tuple...)
+	}
+	return ctx.Where(tupleInWhere(ctx.db.dialect.Name(), columns, len(tuples)), args...)
+}
+
+// 拼接多个字段的`in`条件
+func tupleInWhere(driverName string, columns []string, n int) string {
+	var buf strings.Builder
+	switch lintDialect(driverName) {
+	case "mysql", "postgres":
+		places := make([]string, len(columns))
+		for i := range places {
+			places[i] = ParamMarker
+		}
+		tuple := "(" + sqljoin(places, SeqComma) + ")"
+		buf.WriteString("(" + sqljoin(columns, SeqComma) + ") in (")
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				buf.WriteString(SeqComma)
+			}
+			buf.WriteString(tuple)
+		}
+		buf.WriteString(")")
+	default:
+		conds := make([]string, len(columns))
+		for i, column := range columns {
+			conds[i] = column + "=" + ParamMarker
+		}
+		tuple := "(" + strings.Join(conds, " and ") + ")"
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				buf.WriteString(" or ")
+			}
+			buf.WriteString(tuple)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,154 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 事务传播方式，决定嵌套调用时怎么使用`context.Context`中已经存在的事务
+type Propagation int
+
+const (
+	PropagationRequired     Propagation = iota //有事务就加入，没有就新建一个，默认
+	PropagationRequiresNew                     //总是新建一个事务(新的连接)，和外面的事务互不影响
+	PropagationNotSupported                    //不使用事务，外面的事务被挂起，里面的操作直接提交
+)
+
+// `context.Context`中保存事务的`key`，每个`DB`单独一个，多个库的事务不会混在一起
+type txKey struct {
+	db *DB
+}
+
+// 保存在`context.Context`中的事务和事务内的缓存
+type txScope struct {
+	tx       *sqlx.Tx
+	identity *identityMap
+	managed  bool //是否由`Transaction`负责提交，负责提交的才会执行提交以后的回调
+
+	mu    sync.Mutex
+	hooks []func() //提交以后的回调
+}
+
+// 添加提交以后的回调
+func (s *txScope) onCommit(fn func()) {
+	s.mu.Lock()
+	s.hooks = append(s.hooks, fn)
+	s.mu.Unlock()
+}
+
+// 提交以后按添加的顺序执行回调
+func (s *txScope) committed() {
+	s.mu.Lock()
+	hooks := s.hooks
+	s.hooks = nil
+	s.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// 把事务保存到`ctx`中
+func (db *DB) withTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	scope := &txScope{tx: tx}
+	if !db.identityOff {
+		scope.identity = newIdentityMap()
+	}
+	return context.WithValue(ctx, txKey{db}, scope)
+}
+
+// 获取`ctx`中当前`DB`的事务，没有返回`nil`，可以直接传给`AcquireTx`
+func (db *DB) TxFromContext(ctx context.Context) *sqlx.Tx {
+	if scope, _ := ctx.Value(txKey{db}).(*txScope); scope != nil {
+		return scope.tx
+	}
+	return nil
+}
+
+// 按传播方式执行`fn`，事务保存在传给`fn`的`ctx`中，用`db.From(ctx)`使用
+// `fn`返回错误或者`panic`时回滚，否则提交，加入外面的事务时由外面的事务负责提交和回滚
+// eg:
+//
+//	err := db.Transaction(ctx, PropagationRequired, func(ctx context.Context) error {
+//		_, err := db.From(ctx).Name("user").Insert(data)
+//		return err
+//	})
+func (db *DB) Transaction(ctx context.Context, propagation Propagation, fn func(ctx context.Context) error) error {
+	switch propagation {
+	case PropagationNotSupported:
+		return fn(context.WithValue(ctx, txKey{db}, (*txScope)(nil)))
+	case PropagationRequired:
+		if db.TxFromContext(ctx) != nil {
+			return fn(ctx)
+		}
+	}
+	return db.runTx(ctx, nil, fn)
+}
+
+// 在只读的一致性快照中执行`fn`，`fn`中用`db.From(ctx)`执行的查询看到的是同一个时间点的数据
+// 适合分页导出之类需要多次查询的场景，使用`REPEATABLE READ`隔离级别，普通的查询不加锁，不会阻塞写入
+// `fn`执行完以后提交，只读事务中不能写入
+func (db *DB) WithReadSnapshot(ctx context.Context, fn func(ctx context.Context) error) error {
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	if db.dialect.Name() == "sqlite3" {
+		// sqlite3的事务本身就是串行化的，不支持设置隔离级别
+		opts.Isolation = sql.LevelDefault
+	}
+	return db.runTx(ctx, opts, fn)
+}
+
+// 开启一个事务并保存到返回的`ctx`中，之后用`db.From(ctx)`获取的`Context`都会使用这个事务
+// 不用再把`*sqlx.Tx`一层层传下去，提交和回滚还是用返回的`tx`自己处理
+// 自己提交的事务不会执行`AfterCommit`的回调，实体缓存只在写入时清掉，提交前可能又被其他请求缓存了旧数据，需要的话用`Transaction`
+func (db *DB) BeginIntoContext(ctx context.Context) (context.Context, *sqlx.Tx, error) {
+	tx, err := db.Pool().BeginTxx(ctx, nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return db.withTx(ctx, tx), tx, nil
+}
+
+// 获取`Context`，`ctx`中有事务的话使用事务，和`AcquireTx(TxFromContext(ctx))`一样
+// 查询使用`ctx`的取消和超时，见`Context.WithContext`
+// 事务中按主键`FindOne`会使用事务内的缓存，见`DB.IdentityMap`
+func (db *DB) From(ctx context.Context) *Context {
+	scope, _ := ctx.Value(txKey{db}).(*txScope)
+	if scope == nil {
+		return db.Acquire().WithContext(ctx)
+	}
+	c := db.AcquireTx(scope.tx).WithContext(ctx)
+	c.identity = scope.identity
+	c.scope = scope
+	return c
+}
+
+// 新建一个事务执行`fn`
+func (db *DB) runTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) (err error) {
+	tx, err := db.Pool().BeginTxx(ctx, opts)
+	if err != nil {
+		return
+	}
+	defer func() {
+		// 回滚失败也返回原来的错误
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	ctx = db.withTx(ctx, tx)
+	scope := ctx.Value(txKey{db}).(*txScope)
+	scope.managed = true
+	if err = fn(ctx); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	scope.committed()
+	return
+}
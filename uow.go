@@ -0,0 +1,262 @@
+package littleorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// 一组写操作，先记录下来，`Commit`时在一个事务中按表的依赖顺序执行
+// 插入和更新先父表后子表，删除先子表后父表，同一行的多次更新会合并成一次
+// 适合一次要写多张表的聚合，不用自己考虑执行顺序
+type UnitOfWork struct {
+	db      *DB
+	deps    map[string][]string //表 => 依赖的表(父表)
+	inserts []uowIntent
+	updates []*uowIntent
+	updated map[string]*uowIntent //表+主键 => 更新
+	deletes []uowIntent
+	deleted map[string]bool //表+主键
+	err     error           //记录时的错误，`Commit`时返回
+}
+
+type uowIntent struct {
+	table string
+	pk    string //主键字段
+	id    interface{}
+	data  map[string]interface{}
+	value interface{} //结构体，不为空时用`InsertStruct`、`UpdateStruct`写入
+	opts  []UpdateOption
+}
+
+// 创建`UnitOfWork`
+func (db *DB) UnitOfWork() *UnitOfWork {
+	return &UnitOfWork{
+		db:      db,
+		deps:    make(map[string][]string),
+		updated: make(map[string]*uowIntent),
+		deleted: make(map[string]bool),
+	}
+}
+
+// 声明表`table`依赖`parents`，比如订单明细依赖订单，依赖的表先插入后删除
+func (u *UnitOfWork) DependsOn(table string, parents ...string) *UnitOfWork {
+	u.deps[table] = append(u.deps[table], parents...)
+	return u
+}
+
+// 记录插入
+func (u *UnitOfWork) Insert(table string, data map[string]interface{}) *UnitOfWork {
+	u.inserts = append(u.inserts, uowIntent{table: table, data: data})
+	return u
+}
+
+// 记录结构体的插入，通过`InsertStruct`执行
+func (u *UnitOfWork) InsertStruct(table string, v interface{}) *UnitOfWork {
+	u.inserts = append(u.inserts, uowIntent{table: table, value: v})
+	return u
+}
+
+// 记录按主键`id`更新，同一行的多次更新合并，后面的覆盖前面的字段
+func (u *UnitOfWork) Update(table string, id interface{}, data map[string]interface{}) *UnitOfWork {
+	key := uowKey(table, id)
+	if intent, ok := u.updated[key]; ok && intent.value == nil {
+		for k, v := range data {
+			intent.data[k] = v
+		}
+		return u
+	}
+	intent := &uowIntent{table: table, pk: "id", id: id, data: make(map[string]interface{}, len(data))}
+	for k, v := range data {
+		intent.data[k] = v
+	}
+	u.updated[key] = intent
+	u.updates = append(u.updates, intent)
+	return u
+}
+
+// 记录结构体的更新，按模型的主键更新，通过`UpdateStruct`执行，`opts`和`UpdateStruct`一样
+// 同一行的多次结构体更新只执行最后一次，和`Update`记录的更新不合并，按记录的顺序执行
+func (u *UnitOfWork) UpdateStruct(table string, v interface{}, opts ...UpdateOption) *UnitOfWork {
+	pk, id, err := primaryKeyOf(v)
+	if err != nil {
+		u.fail(fmt.Errorf("littleorm: unit of work update %s: %w", table, err))
+		return u
+	}
+	key := uowKey(table, id)
+	if intent, ok := u.updated[key]; ok && intent.value != nil {
+		intent.value, intent.opts = v, opts
+		return u
+	}
+	intent := &uowIntent{table: table, pk: pk, id: id, value: v, opts: opts}
+	u.updated[key] = intent
+	u.updates = append(u.updates, intent)
+	return u
+}
+
+// 记录按主键`id`删除，删除的行之前记录的更新不再执行
+func (u *UnitOfWork) Delete(table string, id interface{}) *UnitOfWork {
+	return u.delete(table, "id", id)
+}
+
+// 记录结构体的删除，按模型的主键删除
+func (u *UnitOfWork) DeleteStruct(table string, v interface{}) *UnitOfWork {
+	pk, id, err := primaryKeyOf(v)
+	if err != nil {
+		u.fail(fmt.Errorf("littleorm: unit of work delete %s: %w", table, err))
+		return u
+	}
+	return u.delete(table, pk, id)
+}
+
+func (u *UnitOfWork) delete(table, pk string, id interface{}) *UnitOfWork {
+	key := uowKey(table, id)
+	if !u.deleted[key] {
+		u.deleted[key] = true
+		u.deletes = append(u.deletes, uowIntent{table: table, pk: pk, id: id})
+	}
+	return u
+}
+
+func (u *UnitOfWork) fail(err error) {
+	if u.err == nil {
+		u.err = err
+	}
+}
+
+// 在事务中执行记录的写操作，`ctx`中有事务的话加入这个事务，执行成功以后清空记录
+// 记录结构体时出错(比如没有主键)的话直接返回这个错误，什么都不执行
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	if u.err != nil {
+		return u.err
+	}
+	order, err := u.tableOrder()
+	if err != nil {
+		return err
+	}
+	err = u.db.Transaction(ctx, PropagationRequired, func(ctx context.Context) error {
+		for _, table := range order {
+			for _, intent := range u.inserts {
+				if intent.table != table {
+					continue
+				}
+				var err error
+				if intent.value != nil {
+					_, err = u.db.From(ctx).Name(table).InsertStruct(intent.value)
+				} else {
+					_, err = u.db.From(ctx).Name(table).Insert(intent.data)
+				}
+				if err != nil {
+					return fmt.Errorf("littleorm: unit of work insert %s: %w", table, err)
+				}
+			}
+		}
+		for _, table := range order {
+			for _, intent := range u.updates {
+				if intent.table != table || u.deleted[uowKey(table, intent.id)] {
+					continue
+				}
+				var err error
+				c := u.db.From(ctx).Name(table).Where(intent.pk+"=?", intent.id)
+				if intent.value != nil {
+					_, err = c.UpdateStruct(intent.value, intent.opts...)
+				} else {
+					_, err = c.UpdateMap(intent.data)
+				}
+				if err != nil {
+					return fmt.Errorf("littleorm: unit of work update %s %v: %w", table, intent.id, err)
+				}
+			}
+		}
+		for i := len(order) - 1; i >= 0; i-- {
+			for _, intent := range u.deletes {
+				if intent.table != order[i] {
+					continue
+				}
+				if _, err := u.db.From(ctx).Name(intent.table).Where(intent.pk+"=?", intent.id).Delete(); err != nil {
+					return fmt.Errorf("littleorm: unit of work delete %s %v: %w", intent.table, intent.id, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		u.inserts, u.updates, u.deletes = nil, nil, nil
+		u.updated = make(map[string]*uowIntent)
+		u.deleted = make(map[string]bool)
+	}
+	return err
+}
+
+// 涉及的表按依赖排序，父表在前面，循环依赖返回错误
+func (u *UnitOfWork) tableOrder() ([]string, error) {
+	var (
+		tables []string
+		seen   = make(map[string]bool)
+	)
+	add := func(table string) {
+		if !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	for _, intent := range u.inserts {
+		add(intent.table)
+	}
+	for _, intent := range u.updates {
+		add(intent.table)
+	}
+	for _, intent := range u.deletes {
+		add(intent.table)
+	}
+
+	var (
+		order []string
+		state = make(map[string]int)
+		visit func(table string) error
+	)
+	visit = func(table string) error {
+		switch state[table] {
+		case 2:
+			return nil
+		case 1:
+			return fmt.Errorf("littleorm: unit of work dependency cycle on %s", table)
+		}
+		state[table] = 1
+		for _, parent := range u.deps[table] {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[table] = 2
+		order = append(order, table)
+		return nil
+	}
+	for _, table := range tables {
+		if err := visit(table); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// 结构体的主键字段和值，主键见模型的`pk`标签，没有主键或者主键是零值时返回`ErrNoPrimaryKey`
+func primaryKeyOf(v interface{}) (string, interface{}, error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("littleorm: needs a struct or pointer to struct, got %T", v)
+	}
+	m := lookupModel(value.Type())
+	if m.pk == "" {
+		return "", nil, fmt.Errorf("%w: %s has no primary key", ErrNoPrimaryKey, value.Type())
+	}
+	id := value.Field(m.index[m.pk])
+	if id.IsZero() {
+		return "", nil, fmt.Errorf("%w: %s of %s is zero", ErrNoPrimaryKey, m.pk, value.Type())
+	}
+	return m.pk, id.Interface(), nil
+}
+
+func uowKey(table string, id interface{}) string {
+	return fmt.Sprintf("%s\x00%v", table, id)
+}
@@ -0,0 +1,120 @@
+package littleorm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// 按主键`id`批量更新结构体数组，参数同`eachStruct`，eg: []User, &[]*User
+// `columns`是需要更新的字段，不传的话更新除了主键、只读字段、计算字段和软删除字段以外的所有字段
+// 每个字段拼成`col=case id when ? then ? ... else col end`，一条语句更新多行，`Context`上的条件会一起带上
+// 设置了`DB.InChunkSize`时按上限拆成多条语句执行，和`WhereIn`拆分一样多次执行不是原子的，需要的话自己开事务
+// eg: db.Acquire().Name("user").UpdateBatchStruct(users, "name", "age")
+func (ctx *Context) UpdateBatchStruct(slice interface{}, columns ...string) (rowsAffected int64, err error) {
+	if err = ctx.inUse(); err != nil {
+		return
+	}
+	defer ctx.release()
+	if ctx.err != nil {
+		return 0, ctx.err
+	}
+	t := structType(slice)
+	if t == nil {
+		return 0, fmt.Errorf("littleorm: UpdateBatchStruct needs a slice of struct, got %T", slice)
+	}
+	m := lookupModel(t)
+	pk, ok := m.index["id"]
+	if !ok {
+		return 0, fmt.Errorf("%w: field id not found in %s", ErrNoPrimaryKey, t)
+	}
+	if len(columns) == 0 {
+		columns = m.updateColumns()
+	}
+	for _, column := range columns {
+		if _, ok := m.index[column]; !ok || column == "id" || m.readonly[column] {
+			return 0, fmt.Errorf("littleorm: UpdateBatchStruct column %q is not an updatable field of %s", column, t)
+		}
+	}
+
+	var rows []reflect.Value
+	eachStruct(slice, func(v reflect.Value) {
+		if ctx.err == nil {
+			ctx.validate(v.Interface(), structData(m, v, columns))
+		}
+		rows = append(rows, v)
+	})
+	if ctx.err != nil {
+		return 0, ctx.err
+	}
+	size := ctx.db.inChunkSize
+	if size <= 0 {
+		size = len(rows)
+	}
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		query, args := ctx.updateCases(m, pk, columns, rows[start:end])
+		ctx.db.logDebug("littleorm exec sql", sqlFields(query, args)...)
+		result, err := ctx.execute(query, args...)
+		if err != nil {
+			return rowsAffected, err
+		}
+		n, err := result.RowsAffected()
+		rowsAffected += n
+		if err != nil {
+			return rowsAffected, err
+		}
+	}
+	return
+}
+
+// 拼接一批数据的`case`更新语句
+func (ctx *Context) updateCases(m *model, pk int, columns []string, rows []reflect.Value) (string, []interface{}) {
+	var (
+		sets = make([]string, 0, len(columns))
+		ids  = make([]interface{}, len(rows))
+		args = make([]interface{}, 0, len(columns)*len(rows)*2+len(rows)+len(ctx.whereArgs))
+	)
+	for i, row := range rows {
+		ids[i] = row.Field(pk).Interface()
+	}
+	for _, column := range columns {
+		buf := fmt.Sprintf("%s=case id", column)
+		for i, row := range rows {
+			buf += fmt.Sprintf(" when %s then %s", ParamMarker, ParamMarker)
+			args = append(args, ids[i], row.Field(m.index[column]).Interface())
+		}
+		// `else`带上字段本身，`postgres`才能推断出`case`的类型
+		sets = append(sets, fmt.Sprintf("%s else %s end", buf, column))
+	}
+	wheres := []string{inWhere("id", len(ids))}
+	args = append(args, ids...)
+	if scoped := ctx.scopedWheres(rows[0].Interface()); len(scoped) > 0 {
+		wheres = append(wheres, fmt.Sprintf("(%s)", sqljoin(scoped, Grouping)))
+		args = append(args, ctx.whereArgs...)
+	}
+	query := fmt.Sprintf("update %s set %s %s", ctx.name, sqljoin(sets, SeqComma), sqlwhere(wheres, Grouping))
+	return query, args
+}
+
+// 结构体中可以更新的字段，跳过主键、只读字段、计算字段和软删除字段
+func (m *model) updateColumns() []string {
+	columns := make([]string, 0, len(m.columns))
+	for _, column := range m.columns {
+		if column != "id" && !m.readonly[column] && column != m.deletedAt {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// 结构体中指定字段的值，用于校验
+func structData(m *model, v reflect.Value, columns []string) map[string]interface{} {
+	data := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		data[column] = v.Field(m.index[column]).Interface()
+	}
+	return data
+}
@@ -0,0 +1,105 @@
+package littleorm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// `UpdateStruct`的选项
+type UpdateOption func(opts *updateOptions)
+
+type updateOptions struct {
+	skipZero bool
+	only     map[string]bool
+	omit     map[string]bool
+}
+
+// 跳过值为零的字段，注意`0`、`false`、`""`也会跳过，需要更新成零值的字段用`OnlyColumns`指定
+func SkipZero() UpdateOption {
+	return func(opts *updateOptions) {
+		opts.skipZero = true
+	}
+}
+
+// 只更新指定的字段，可以和`SkipZero`一起用
+func OnlyColumns(columns ...string) UpdateOption {
+	return func(opts *updateOptions) {
+		opts.only = addColumns(opts.only, columns)
+	}
+}
+
+// 不更新指定的字段
+func OmitColumns(columns ...string) UpdateOption {
+	return func(opts *updateOptions) {
+		opts.omit = addColumns(opts.omit, columns)
+	}
+}
+
+func addColumns(set map[string]bool, columns []string) map[string]bool {
+	if set == nil {
+		set = make(map[string]bool, len(columns))
+	}
+	for _, column := range columns {
+		set[column] = true
+	}
+	return set
+}
+
+// 使用结构体更新，字段从`db`标签中读取，跳过主键、只读字段、计算字段和软删除字段，参数是结构体或者结构体指针
+// 没有设置条件时按结构体中的主键`id`更新，主键也是零值的话返回`ErrNoPrimaryKey`，避免更新整张表
+// 结构体实现了`Validator`的话写入前先校验，注册的校验函数只拿到需要更新的字段
+// eg: db.Acquire().Name("user").UpdateStruct(&user, littleorm.SkipZero(), littleorm.OmitColumns("created_at"))
+func (ctx *Context) UpdateStruct(v interface{}, opts ...UpdateOption) (rowsAffected int64, err error) {
+	var options updateOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	data, err := ctx.structSets(v, options)
+	if err != nil && ctx.err == nil {
+		ctx.err = err
+	}
+	ctx.validate(v, data)
+	if rules := ctx.db.mirrorsOf(ctx.name, data); len(rules) > 0 {
+		return ctx.updateMirrored(data, rules)
+	}
+	return ctx.updateMap(data)
+}
+
+// 根据选项取出结构体中需要更新的字段，没有条件时按主键添加条件
+func (ctx *Context) structSets(v interface{}, options updateOptions) (map[string]interface{}, error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("littleorm: UpdateStruct needs a struct or pointer to struct, got %T", v)
+	}
+	m := lookupModel(value.Type())
+	if ctx.softDelete == "" {
+		ctx.softDelete = m.deletedAt
+	}
+	for column := range options.only {
+		if _, ok := m.index[column]; !ok {
+			return nil, fmt.Errorf("littleorm: UpdateStruct column %q is not a field of %s", column, value.Type())
+		}
+	}
+	data := make(map[string]interface{})
+	for _, column := range m.updateColumns() {
+		if options.omit[column] || (options.only != nil && !options.only[column]) {
+			continue
+		}
+		field := value.Field(m.index[column])
+		if options.skipZero && field.IsZero() {
+			continue
+		}
+		data[column] = field.Interface()
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("littleorm: UpdateStruct has no columns to update in %s", value.Type())
+	}
+	if len(ctx.wheres) == 0 {
+		pk, ok := fieldByTag(value, "id")
+		if !ok || pk.IsZero() {
+			return nil, fmt.Errorf("%w: UpdateStruct without conditions needs the id of %s", ErrNoPrimaryKey, value.Type())
+		}
+		ctx.Where("id=?", pk.Interface())
+	}
+	return data, nil
+}
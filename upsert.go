@@ -0,0 +1,72 @@
+package littleorm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrUpsertConflict = errors.New("littleorm: upsert needs conflict keys")
+
+// 指定`Upsert`冲突时判断的唯一键，`postgres`和`SQLite`必须指定，`MySQL`按表上所有的唯一索引判断，指定了也会忽略
+func (ctx *Context) OnConflict(keys ...string) *Context {
+	ctx.conflict = keys
+	return ctx
+}
+
+// 插入一条记录，唯一键冲突时更新`updateCols`指定的字段，一条语句完成，不用先查再判断
+// `updateCols`为空时更新`data`中除了唯一键以外的所有字段，没有需要更新的字段时冲突就什么都不做
+// `MySQL`用`on duplicate key update`，其他数据库用`on conflict (...) do update`
+// eg: db.Acquire().Name("stock").OnConflict("sku").Upsert(map[string]interface{}{"sku": "a1", "qty": 10}, []string{"qty"})
+func (ctx *Context) Upsert(data map[string]interface{}, updateCols []string) (sql.Result, error) {
+	ctx.validate(nil, data)
+	var (
+		fields = make([]string, 0, len(data))
+		params = make([]interface{}, 0, len(data))
+		places = make([]string, 0, len(data))
+	)
+	for k := range data {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		params = append(params, data[field])
+		places = append(places, ParamMarker)
+	}
+	if len(updateCols) == 0 {
+		for _, field := range fields {
+			if !containsString(ctx.conflict, field) {
+				updateCols = append(updateCols, field)
+			}
+		}
+	}
+
+	query := fmt.Sprintf("insert into %s (%s) values (%s)", ctx.name, sqljoin(fields, SeqComma), sqljoin(places, SeqComma))
+	if ctx.db.dialect.Name() == "mysql" {
+		sets := make([]string, len(updateCols))
+		for i, column := range updateCols {
+			sets[i] = fmt.Sprintf("%s=values(%s)", column, column)
+		}
+		if len(sets) == 0 && len(fields) > 0 {
+			// 没有需要更新的字段，用一个不改变数据的赋值代替，`insert ignore`会把其他错误也忽略掉
+			sets = append(sets, fmt.Sprintf("%s=%s", fields[0], fields[0]))
+		}
+		query += " on duplicate key update " + sqljoin(sets, SeqComma)
+		return ctx.exec(query, params...)
+	}
+	if len(ctx.conflict) == 0 && ctx.err == nil {
+		ctx.err = fmt.Errorf("%w: use OnConflict on %s", ErrUpsertConflict, ctx.db.dialect.Name())
+	}
+	query += fmt.Sprintf(" on conflict (%s) do ", sqljoin(ctx.conflict, SeqComma))
+	if len(updateCols) == 0 {
+		query += "nothing"
+	} else {
+		sets := make([]string, len(updateCols))
+		for i, column := range updateCols {
+			sets[i] = fmt.Sprintf("%s=excluded.%s", column, column)
+		}
+		query += "update set " + sqljoin(sets, SeqComma)
+	}
+	return ctx.exec(query, params...)
+}
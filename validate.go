@@ -0,0 +1,55 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrValidation = errors.New("littleorm: validation failed")
+
+// 写入前的校验，写入的结构体实现了这个接口的话会先调用`Validate`
+type Validator interface {
+	Validate() error
+}
+
+// 校验`map`写入的数据，更新时只有需要更新的字段
+type ValidateFunc func(data map[string]interface{}) error
+
+// 给表`table`注册校验函数，`Insert`和`UpdateMap`写入前调用，可以注册多个，按注册的顺序执行
+// 校验失败返回的错误同时包含`ErrValidation`和校验函数返回的错误，不会执行`SQL`
+func (db *DB) Validate(table string, fn ValidateFunc) *DB {
+	db.validateMu.Lock()
+	defer db.validateMu.Unlock()
+	if db.validators == nil {
+		db.validators = make(map[string][]ValidateFunc)
+	}
+	db.validators[table] = append(db.validators[table], fn)
+	return db
+}
+
+// 写入前校验，`value`是写入的结构体，`map`写入时为`nil`，`data`是写入的字段
+// 校验失败时记录到`ctx.err`中，执行时直接返回
+func (ctx *Context) validate(value interface{}, data map[string]interface{}) {
+	if ctx.err != nil {
+		return
+	}
+	if err := ctx.db.checkConstraints(ctx.name, data); err != nil {
+		ctx.err = fmt.Errorf("%w: %w", ErrValidation, err)
+		return
+	}
+	if v, ok := value.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			ctx.err = fmt.Errorf("%w: %w", ErrValidation, err)
+			return
+		}
+	}
+	ctx.db.validateMu.RLock()
+	validators := ctx.db.validators[ctx.name]
+	ctx.db.validateMu.RUnlock()
+	for _, fn := range validators {
+		if err := fn(data); err != nil {
+			ctx.err = fmt.Errorf("%w: %w", ErrValidation, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package littleorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidValues = errors.New("littleorm: invalid values table")
+
+// 由一组数据构成的内联派生表，可以直接关联查询，数据不多的时候不用建临时表
+// eg: littleorm.Values([]string{"id", "score"}, []interface{}{1, 90}, []interface{}{2, 80})
+type ValuesTable struct {
+	columns []string
+	rows    [][]interface{}
+	types   []string //postgres的字段类型，见`Types`
+}
+
+// 创建内联派生表，每一行的值的个数必须和字段个数一样
+func Values(columns []string, rows ...[]interface{}) *ValuesTable {
+	return &ValuesTable{columns: columns, rows: rows}
+}
+
+// 指定字段的类型，只对postgres有效，按字段的顺序，空字符串表示不指定
+// postgres的参数没有类型，和表中的字段比较时会报`operator does not exist: text = integer`，所以生成`SQL`时加上类型转换
+// 不指定的话按第一行的值推断：整数是`bigint`，浮点数是`double precision`，还有`boolean`、`text`、`bytea`、`timestamptz`，推断不出来(比如`nil`)的不转换
+// eg: littleorm.Values([]string{"id", "score"}, rows...).Types("int", "numeric")
+func (v *ValuesTable) Types(types ...string) *ValuesTable {
+	v.types = types
+	return v
+}
+
+// 生成派生表的`SQL`和参数，根据驱动生成不同的语法：
+// mysql: (values row(?,?), row(?,?)) as alias (a,b)，需要`MySQL 8.0.19`以上
+// postgres: (values (?::bigint,?::text), (?::bigint,?::text)) as alias (a,b)，类型见`Types`
+// 其他: (select ? as a, ? as b union all select ?, ?) as alias
+func (v *ValuesTable) SQL(driverName, alias string) (string, []interface{}, error) {
+	if len(v.columns) == 0 || len(v.rows) == 0 {
+		return "", nil, fmt.Errorf("%w: no columns or rows", ErrInvalidValues)
+	}
+	places := make([]string, len(v.columns))
+	for i := range places {
+		places[i] = ParamMarker
+	}
+	tuple := sqljoin(places, SeqComma)
+	args := make([]interface{}, 0, len(v.columns)*len(v.rows))
+	for i, row := range v.rows {
+		if len(row) != len(v.columns) {
+			return "", nil, fmt.Errorf("%w: row %d has %d values but %d columns", ErrInvalidValues, i, len(row), len(v.columns))
+		}
+		args = append(args, row...)
+	}
+
+	var buf strings.Builder
+	switch dialect := lintDialect(driverName); dialect {
+	case "mysql", "postgres":
+		prefix := "("
+		if dialect == "mysql" {
+			prefix = "row("
+		} else {
+			tuple = v.typedTuple()
+		}
+		buf.WriteString("(values ")
+		for i := range v.rows {
+			if i > 0 {
+				buf.WriteString(SeqComma)
+			}
+			buf.WriteString(prefix)
+			buf.WriteString(tuple)
+			buf.WriteByte(')')
+		}
+		fmt.Fprintf(&buf, ") as %s (%s)", alias, sqljoin(v.columns, SeqComma))
+	default:
+		named := make([]string, len(v.columns))
+		for i, column := range v.columns {
+			named[i] = ParamMarker + " as " + column
+		}
+		buf.WriteString("(select ")
+		buf.WriteString(sqljoin(named, SeqComma))
+		for i := 1; i < len(v.rows); i++ {
+			buf.WriteString(" union all select ")
+			buf.WriteString(tuple)
+		}
+		fmt.Fprintf(&buf, ") as %s", alias)
+	}
+	return buf.String(), args, nil
+}
+
+// 关联内联派生表，`on`是关联条件
+// eg: db.Acquire().Name("user").JoinValues(littleorm.Values([]string{"id", "score"}, rows...), "v", "v.id = user.id").
+//
+//	What([]string{"user.*", "v.score"}).FindMany(&users)
+func (ctx *Context) JoinValues(v *ValuesTable, alias, on string) *Context {
+	table, args, err := v.SQL(ctx.db.dialect.Name(), alias)
+	if err != nil {
+		if ctx.err == nil {
+			ctx.err = err
+		}
+		return ctx
+	}
+	ctx.joins = append(ctx.joins, "join "+table+" on "+on)
+	ctx.joinArgs = append(ctx.joinArgs, args...)
+	return ctx
+}
+
+// postgres带类型转换的一行参数
+func (v *ValuesTable) typedTuple() string {
+	places := make([]string, len(v.columns))
+	for i := range places {
+		places[i] = ParamMarker
+		typ := ""
+		if i < len(v.types) {
+			typ = v.types[i]
+		} else {
+			typ = postgresType(v.rows[0][i])
+		}
+		if typ != "" {
+			places[i] += "::" + typ
+		}
+	}
+	return sqljoin(places, SeqComma)
+}
+
+// 按`Go`的类型推断postgres的类型
+func postgresType(value interface{}) string {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "bigint"
+	case float32, float64:
+		return "double precision"
+	case bool:
+		return "boolean"
+	case string:
+		return "text"
+	case []byte:
+		return "bytea"
+	case time.Time:
+		return "timestamptz"
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package littleorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// 预先建立`n`个连接放进连接池，上线以后第一波请求不用再花时间建连接、握手和认证
+// 传了`queries`时在每个连接上预编译一遍，提前发现语句错误，服务端也会缓存解析结果，占位符统一用`?`
+// `n`超过`SetMaxOpenConns`时按上限建立，超过`SetMaxIdleConns`(默认2个)的连接放回时会被关掉，需要先调大
+// eg:
+//
+//	db.SetMaxIdleConns(20)
+//	err := db.Warmup(ctx, 20, "select * from user where id=?")
+func (db *DB) Warmup(ctx context.Context, n int, queries ...string) error {
+	if max := db.Pool().Stats().MaxOpenConnections; max > 0 && n > max {
+		n = max
+	}
+	// 同时拿着`n`个连接，否则放回去的连接会被下一次拿出来，建不了新连接
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn, err := db.Pool().Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("littleorm: warmup connection %d: %w", i, err)
+		}
+		conns = append(conns, conn)
+		if err = conn.PingContext(ctx); err != nil {
+			return fmt.Errorf("littleorm: warmup connection %d: %w", i, err)
+		}
+		for _, query := range queries {
+			stmt, err := conn.PrepareContext(ctx, bindQuery(db.dialect, query))
+			if err != nil {
+				return fmt.Errorf("littleorm: warmup prepare %q: %w", query, err)
+			}
+			stmt.Close()
+		}
+	}
+	db.logInfo("littleorm warmup", LogField{"connections", len(conns)}, LogField{"queries", len(queries)})
+	return nil
+}
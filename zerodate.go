@@ -0,0 +1,177 @@
+package littleorm
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// mysql的零值日期`0000-00-00 00:00:00`的处理方式
+type ZeroDateMode int
+
+const (
+	ZeroDateAsIs   ZeroDateMode = iota //不处理，没有开启`parseTime`时扫描到`time.Time`会报错
+	ZeroDateAsZero                     //扫描成`time.Time{}`，写入`time.Time{}`时写成`0000-00-00 00:00:00`
+	ZeroDateAsNull                     //扫描成`NULL`，写入`time.Time{}`时写成`NULL`
+)
+
+// 写入零值日期时使用的值
+const zeroDateText = "0000-00-00 00:00:00"
+
+// 没有开启`parseTime`时解析日期的格式，和mysql驱动一样按`UTC`解析
+var dateLayouts = []string{"2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+var (
+	timePtrType  = reflect.TypeOf((*time.Time)(nil))
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// 设置零值日期的处理方式，用于到处都是`0000-00-00`的老表，只适合mysql
+// 查询时日期字段的零值日期和月、日为`00`的非法日期按`mode`转换，不会再返回驱动的解析错误，和`Coerce`一样逐行扫描
+// 结构体中`time.Time`、`*time.Time`、`sql.NullTime`类型的字段都可以，`ZeroDateAsNull`扫描到`time.Time`时是`time.Time{}`
+// 写入时把参数中的`time.Time{}`按`mode`写成零值日期或者`NULL`，条件中的参数也一样
+// `Coerce`中也可以单独给一次查询指定，eg: db.ZeroDates(littleorm.ZeroDateAsNull)
+func (db *DB) ZeroDates(mode ZeroDateMode) *DB {
+	db.zeroDates = mode
+	return db
+}
+
+// `DB`设置了`ZeroDates`时查询使用带零值日期处理的类型转换，`Coerce`中单独指定了的不覆盖
+func (ctx *Context) applyZeroDates() {
+	mode := ctx.db.zeroDates
+	switch {
+	case mode == ZeroDateAsIs:
+	case ctx.coerce == nil:
+		ctx.coerce = &Coercion{ZeroDates: mode}
+	case ctx.coerce.ZeroDates == ZeroDateAsIs:
+		ctx.coerce.ZeroDates = mode
+	}
+}
+
+// 是否只是`DB.ZeroDates`加上的类型转换，这种转换所有查询都一样，可以用查询结果缓存
+func (ctx *Context) implicitCoerce() bool {
+	return ctx.coerce != nil && *ctx.coerce == (Coercion{ZeroDates: ctx.db.zeroDates})
+}
+
+// 写入的参数中的`time.Time{}`按`ZeroDates`转换，不修改原来的参数
+func (db *DB) zeroDateArgs(args []interface{}) []interface{} {
+	if db.zeroDates == ZeroDateAsIs {
+		return args
+	}
+	var converted []interface{}
+	for i, arg := range args {
+		if !zeroTime(arg) {
+			continue
+		}
+		if converted == nil {
+			converted = append([]interface{}(nil), args...)
+		}
+		converted[i] = nil
+		if db.zeroDates == ZeroDateAsZero {
+			converted[i] = zeroDateText
+		}
+	}
+	if converted == nil {
+		return args
+	}
+	return converted
+}
+
+// 参数是否是`time.Time{}`或者指向`time.Time{}`的指针
+func zeroTime(arg interface{}) bool {
+	switch t := arg.(type) {
+	case time.Time:
+		return t.IsZero()
+	case *time.Time:
+		return t != nil && t.IsZero()
+	}
+	return false
+}
+
+// 是否是零值日期，`parseTime`开启时驱动返回`time.Time{}`，没有开启时是文本，月、日为`00`的非法日期也算
+func isZeroDate(src interface{}) bool {
+	var text string
+	switch v := src.(type) {
+	case time.Time:
+		return v.IsZero()
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return false
+	}
+	if len(text) < len("2006-01-02") {
+		return false
+	}
+	return strings.HasPrefix(text, "0000-00-00") || text[5:7] == "00" || text[8:10] == "00"
+}
+
+// 解析文本格式的日期，零值日期返回`time.Time{}`
+func parseDate(text string) (t time.Time, err error) {
+	if isZeroDate(text) {
+		return
+	}
+	for _, layout := range dateLayouts {
+		if t, err = time.ParseInLocation(layout, text, time.UTC); err == nil {
+			return
+		}
+	}
+	return t, fmt.Errorf("%w: %q is not a date", ErrCoercion, text)
+}
+
+// 数据库类型是否是日期
+func isDateType(name string) bool {
+	return strings.Contains(name, "DATE") || strings.Contains(name, "TIMESTAMP")
+}
+
+// 是否是可以处理零值日期的字段类型
+func isTimeType(t reflect.Type) bool {
+	return t == timeType || t == timePtrType || t == nullTimeType
+}
+
+// 扫描到结构体中的日期字段，处理零值日期
+type zeroDate struct {
+	mode ZeroDateMode
+	dest reflect.Value // `time.Time`、`*time.Time`或者`sql.NullTime`类型的字段
+}
+
+func (s *zeroDate) Scan(src interface{}) (err error) {
+	var (
+		t     time.Time
+		valid = true
+	)
+	switch v := src.(type) {
+	case nil:
+		valid = false
+	case time.Time:
+		t = v
+	case []byte:
+		t, err = parseDate(string(v))
+	case string:
+		t, err = parseDate(v)
+	default:
+		err = fmt.Errorf("%w: cannot scan %T into %s", ErrCoercion, src, s.dest.Type())
+	}
+	if err != nil {
+		return err
+	}
+	if valid && t.IsZero() && s.mode == ZeroDateAsNull {
+		valid = false
+	}
+	switch s.dest.Type() {
+	case timeType:
+		s.dest.Set(reflect.ValueOf(t))
+	case timePtrType:
+		if !valid {
+			s.dest.Set(reflect.Zero(timePtrType))
+			break
+		}
+		s.dest.Set(reflect.ValueOf(&t))
+	default:
+		s.dest.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: valid}))
+	}
+	return nil
+}